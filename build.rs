@@ -0,0 +1,43 @@
+use std::env;
+use std::process::Command;
+use std::time::{SystemTime, UNIX_EPOCH};
+
+// Embed the git commit and build date so --version can identify the exact
+// build that wrote a state or plan file
+fn main() {
+    let commit = Command::new("git")
+        .args(["rev-parse", "--short=12", "HEAD"])
+        .output()
+        .ok()
+        .filter(|output| output.status.success())
+        .map(|output| String::from_utf8_lossy(&output.stdout).trim().to_string())
+        .unwrap_or_else(|| "unknown".to_string());
+
+    // Honour SOURCE_DATE_EPOCH so reproducible builds get a stable date
+    let epoch = env::var("SOURCE_DATE_EPOCH")
+        .ok()
+        .and_then(|secs| secs.parse::<u64>().ok())
+        .unwrap_or_else(|| {
+            SystemTime::now().duration_since(UNIX_EPOCH).map(|d| d.as_secs()).unwrap_or(0)
+        });
+
+    println!("cargo:rustc-env=GITHUB_GRID_COMMIT={}", commit);
+    println!("cargo:rustc-env=GITHUB_GRID_BUILD_DATE={}", civil_date(epoch / 86_400));
+    println!("cargo:rerun-if-changed=.git/HEAD");
+    println!("cargo:rerun-if-changed=.git/refs");
+    println!("cargo:rerun-if-env-changed=SOURCE_DATE_EPOCH");
+}
+
+// Days since 1970-01-01 to YYYY-MM-DD (Howard Hinnant's civil_from_days)
+fn civil_date(days: u64) -> String {
+    let z = days as i64 + 719_468;
+    let era = z.div_euclid(146_097);
+    let doe = z - era * 146_097;
+    let yoe = (doe - doe / 1_460 + doe / 36_524 - doe / 146_096) / 365;
+    let doy = doe - (365 * yoe + yoe / 4 - yoe / 100);
+    let mp = (5 * doy + 2) / 153;
+    let day = doy - (153 * mp + 2) / 5 + 1;
+    let month = if mp < 10 { mp + 3 } else { mp - 9 };
+    let year = yoe + era * 400 + if month <= 2 { 1 } else { 0 };
+    format!("{:04}-{:02}-{:02}", year, month, day)
+}
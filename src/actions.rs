@@ -0,0 +1,106 @@
+use std::fs;
+use std::path::{Path, PathBuf};
+use crate::error::{GitHubGridError, Result};
+
+pub const WORKFLOW_PATH: &str = ".github/workflows/github-grid.yml";
+pub const DEFAULT_SOURCE: &str = "https://github.com/nijaru/github-grid";
+// Off the hour, when GitHub's scheduler is least congested
+pub const DEFAULT_CRON: &str = "17 * * * *";
+
+// Scheduled GitHub Actions workflow for the grid repository itself: each run
+// checks the repo out with a push-capable token and runs a --today top-up
+#[derive(Debug, Clone)]
+pub struct WorkflowSpec {
+    pub cron: String,
+    // Git URL github-grid is installed from with `cargo install --git`
+    pub source: String,
+    pub pattern: Option<String>,
+}
+
+impl WorkflowSpec {
+    pub fn render(&self) -> String {
+        let pattern = self.pattern
+            .as_deref()
+            .map(|pattern| format!(" --pattern {}", pattern))
+            .unwrap_or_default();
+
+        format!(
+            r#"# Generated by `github-grid init-actions`
+#
+# Repository settings this workflow expects:
+#   Variables  GRID_AUTHOR_NAME, GRID_AUTHOR_EMAIL  identity the commits are
+#              attributed to (the email must be verified on your account for
+#              commits to count on your graph)
+#   Variable   GRID_SEED (optional)  seed each day's plan is drawn from;
+#              defaults to the repository id so runs agree on a day
+#   Secret     GRID_TOKEN (optional)  token with contents:write, used instead
+#              of the built-in GITHUB_TOKEN to push
+name: github-grid
+
+on:
+  schedule:
+    - cron: "{cron}"
+  workflow_dispatch:
+
+permissions:
+  contents: write
+
+# Never let two top-ups race on the same branch
+concurrency:
+  group: github-grid
+  cancel-in-progress: false
+
+jobs:
+  top-up:
+    runs-on: ubuntu-latest
+    timeout-minutes: 20
+    steps:
+      - uses: actions/checkout@v4
+        with:
+          ref: main
+          fetch-depth: 0
+          # Persisted as the push credential for origin
+          token: ${{{{ secrets.GRID_TOKEN || github.token }}}}
+
+      - name: Cache github-grid
+        id: cache
+        uses: actions/cache@v4
+        with:
+          path: ~/.cargo/bin/github-grid
+          key: github-grid-${{{{ runner.os }}}}-${{{{ hashFiles('.github/workflows/github-grid.yml') }}}}
+
+      - name: Install github-grid
+        if: steps.cache.outputs.cache-hit != 'true'
+        run: cargo install --git {source} --locked
+
+      - name: Top up today's commits
+        env:
+          GITHUB_GRID_NAME: ${{{{ vars.GRID_AUTHOR_NAME }}}}
+          GITHUB_GRID_EMAIL: ${{{{ vars.GRID_AUTHOR_EMAIL }}}}
+          GITHUB_GRID_SEED: ${{{{ vars.GRID_SEED || github.repository_id }}}}
+          GIT_TERMINAL_PROMPT: "0"
+        run: github-grid --repo . --today --yes --json{pattern}
+"#,
+            cron = self.cron,
+            source = self.source,
+            pattern = pattern,
+        )
+    }
+
+    // Write the workflow into the repository's working tree
+    pub fn write(&self, repo_dir: &Path, force: bool) -> Result<PathBuf> {
+        let path = repo_dir.join(WORKFLOW_PATH);
+        if path.exists() && !force {
+            return Err(GitHubGridError::Config(format!(
+                "{} already exists; use --force to overwrite it",
+                path.display()
+            )));
+        }
+
+        if let Some(parent) = path.parent() {
+            fs::create_dir_all(parent)?;
+        }
+        fs::write(&path, self.render())?;
+        Ok(path)
+    }
+}
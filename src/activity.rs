@@ -0,0 +1,143 @@
+use chrono::{DateTime, FixedOffset};
+use rand::Rng;
+use rand::seq::IndexedRandom;
+use crate::content;
+
+// The activity log's name and the line each generated commit adds to it,
+// both with {placeholders} filled in from the commit
+
+// The line as it has always read: "2024-03-05 14:22 +0100 Fix typo"
+pub const DEFAULT_TEMPLATE: &str = "{date} {time} {offset} {message}";
+
+// What a log's name can follow, so it can be split by year, month or day
+const PATH_PLACEHOLDERS: [&str; 4] = ["date", "year", "month", "day"];
+// What a line can hold: {n} counts the log's lines, and {word} is one of
+// WORDS, so lines vary by more than their date
+const LINE_PLACEHOLDERS: [&str; 9] = ["date", "time", "offset", "year", "month", "day", "message", "n", "word"];
+const WORDS: [&str; 24] = [
+    "tidy", "refine", "polish", "trim", "rework", "sort", "rename", "align",
+    "cache", "batch", "index", "retry", "parse", "format", "merge", "split",
+    "notes", "config", "docs", "tests", "cleanup", "review", "followup", "sketch",
+];
+
+// The log a commit made at `date` adds its line to, relative to the grid
+// directory
+pub fn log_path(pattern: &str, date: DateTime<FixedOffset>) -> String {
+    fill(pattern, |name| date_value(name, date))
+}
+
+// A commit's line, without the newline; `n` is the line it'll be in its
+// log, counting from 1
+pub fn log_line(template: &str, date: DateTime<FixedOffset>, message: &str, n: usize, rng: &mut impl Rng) -> String {
+    fill(template, |name| match name {
+        "message" => Some(message.to_string()),
+        "n" => Some(n.to_string()),
+        "word" => WORDS.choose(rng).map(|word| word.to_string()),
+        _ => date_value(name, date),
+    })
+}
+
+// Why `pattern` can't name the activity log, or None
+pub fn path_problem(what: &str, pattern: &str) -> Option<String> {
+    content::path_problem(what, pattern).or_else(|| placeholder_problem(what, pattern, &PATH_PLACEHOLDERS))
+}
+
+// Why `template` can't lay out its lines, or None
+pub fn template_problem(what: &str, template: &str) -> Option<String> {
+    if template.contains('\n') {
+        return Some(format!("{} '{}': expected a single line", what, template.escape_default()));
+    }
+    placeholder_problem(what, template, &LINE_PLACEHOLDERS)
+}
+
+fn date_value(name: &str, date: DateTime<FixedOffset>) -> Option<String> {
+    let format = match name {
+        "date" => "%Y-%m-%d",
+        "time" => "%H:%M",
+        "offset" => "%z",
+        "year" => "%Y",
+        "month" => "%m",
+        "day" => "%d",
+        _ => return None,
+    };
+    Some(date.format(format).to_string())
+}
+
+// `template` with each {name} `value` knows replaced; anything else is kept
+// as written
+fn fill(template: &str, mut value: impl FnMut(&str) -> Option<String>) -> String {
+    let mut filled = String::with_capacity(template.len());
+    let mut rest = template;
+    while let Some(open) = rest.find('{') {
+        filled.push_str(&rest[..open]);
+        let after = &rest[open + 1..];
+        match after.find('}').and_then(|close| Some((close, value(&after[..close])?))) {
+            Some((close, text)) => {
+                filled.push_str(&text);
+                rest = &after[close + 1..];
+            }
+            None => {
+                filled.push('{');
+                rest = after;
+            }
+        }
+    }
+    filled.push_str(rest);
+    filled
+}
+
+// Braces only ever hold placeholders, so a misspelled one is caught here
+// rather than written into every line
+fn placeholder_problem(what: &str, template: &str, names: &[&str]) -> Option<String> {
+    let unknown = template.split('{').skip(1)
+        .map(|rest| rest.split_once('}').map_or(rest, |(name, _)| name))
+        .find(|name| !names.contains(name))?;
+    let expected: Vec<String> = names.iter().map(|name| format!("{{{}}}", name)).collect();
+    Some(format!("{} '{}': unknown placeholder {{{}}}, expected {}", what, template, unknown, expected.join(", ")))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    use rand::SeedableRng;
+    use rand_chacha::ChaCha8Rng;
+
+    fn date() -> DateTime<FixedOffset> {
+        DateTime::parse_from_rfc3339("2024-03-05T14:22:00+01:00").unwrap()
+    }
+
+    fn line(template: &str, n: usize, seed: u64) -> String {
+        log_line(template, date(), "Fix typo", n, &mut ChaCha8Rng::seed_from_u64(seed))
+    }
+
+    #[test]
+    fn default_template_keeps_the_old_line() {
+        assert_eq!(line(DEFAULT_TEMPLATE, 1, 0), "2024-03-05 14:22 +0100 Fix typo");
+    }
+
+    #[test]
+    fn lines_count_and_draw_words() {
+        assert_eq!(line("{n}. {message}", 12, 0), "12. Fix typo");
+        let words: std::collections::HashSet<String> = (0..20).map(|seed| line("{word}", 1, seed)).collect();
+        assert!(words.len() > 1 && words.iter().all(|word| WORDS.contains(&word.as_str())));
+        assert_eq!(line("{word}", 1, 7), line("{word}", 1, 7));
+    }
+
+    #[test]
+    fn log_names_follow_the_commit_date() {
+        assert_eq!(log_path("log/{year}/{month}.log", date()), "log/2024/03.log");
+        assert_eq!(log_path("{date}.log", date()), "2024-03-05.log");
+        assert_eq!(log_path("activity.log", date()), "activity.log");
+    }
+
+    #[test]
+    fn unknown_placeholders_are_refused() {
+        assert!(template_problem("activity_template", "- {message} ({day}/{month})").is_none());
+        assert!(template_problem("activity_template", "{mesage}").unwrap().contains("{mesage}"));
+        assert!(template_problem("activity_template", "{date}\n{message}").is_some());
+        // A log's name can't vary by the time of day
+        assert!(path_problem("activity_log_file", "{year}/{time}.log").is_some());
+        assert!(path_problem("activity_log_file", "{year}.log").is_none());
+    }
+}
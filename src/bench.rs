@@ -0,0 +1,95 @@
+use chrono::NaiveDate;
+use git2::Repository;
+use std::fs;
+use std::path::{Path, PathBuf};
+use std::time::{Duration, Instant};
+use crate::error::Result;
+use crate::git_ops::GitOperations;
+use crate::output::{green, yellow};
+use crate::plan::{Plan, PlanOptions, Planner, Strategy};
+
+// A full year of commits should be planned and written in under this long
+pub const YEAR_TARGET: Duration = Duration::from_secs(60);
+
+// Seed every benchmark plans with, so runs compare the same work
+const BENCH_SEED: u64 = 0;
+
+// Throughput of one phase of a run
+#[derive(Debug, Clone, Copy)]
+pub struct Phase {
+    pub commits: usize,
+    pub elapsed: Duration,
+}
+
+impl Phase {
+    pub fn per_second(&self) -> f64 {
+        self.commits as f64 / self.elapsed.as_secs_f64().max(f64::EPSILON)
+    }
+}
+
+#[derive(Debug, Clone, Copy)]
+pub struct BenchReport {
+    pub days: i64,
+    pub planning: Phase,
+    // Writing the planned commits with libgit2, the only commit backend
+    pub committing: Phase,
+}
+
+impl BenchReport {
+    // Time the run would take for 365 days at the measured rates
+    pub fn projected_year(&self) -> Duration {
+        let total = self.planning.elapsed + self.committing.elapsed;
+        total.mul_f64(365.0 / self.days.max(1) as f64)
+    }
+    
+    // Both phases' rates, and the projected year against YEAR_TARGET
+    pub fn print(&self) {
+        println!("Planning:   {:>7} commits in {:>8.2?} ({:.0} commits/s)",
+            self.planning.commits, self.planning.elapsed, self.planning.per_second());
+        println!("Committing: {:>7} commits in {:>8.2?} ({:.0} commits/s, libgit2)",
+            self.committing.commits, self.committing.elapsed, self.committing.per_second());
+        
+        let year = self.projected_year();
+        if year <= YEAR_TARGET {
+            println!("{}", green(format!("✅ A full year takes about {:.1?} (target {:?})", year, YEAR_TARGET)));
+        } else {
+            println!("{}", yellow(format!("⚠️  A full year takes about {:.1?}, over the {:?} target", year, YEAR_TARGET)));
+        }
+    }
+}
+
+// Plan `days` of `pattern` ending on `end` and write every commit into a
+// throwaway repository in the temp directory, timing both phases. Nothing is
+// pushed and the repository is removed afterwards
+pub fn run(pattern: &str, end: NaiveDate, days: u32) -> Result<BenchReport> {
+    let start = end - chrono::Duration::days(days.saturating_sub(1) as i64);
+    let timer = Instant::now();
+    let plan = Planner::new(PlanOptions {
+        seed: Some(BENCH_SEED),
+        ..PlanOptions::new(start, end, Strategy::Pattern(pattern.to_string()))
+    }).build()?;
+    let planning = Phase { commits: plan.len(), elapsed: timer.elapsed() };
+
+    let dir = scratch_dir();
+    let result = commit_all(&dir, &plan);
+    // Clean up even when committing failed
+    let _ = fs::remove_dir_all(&dir);
+    let committing = result?;
+
+    Ok(BenchReport { days: plan.days(), planning, committing })
+}
+
+fn commit_all(dir: &Path, plan: &Plan) -> Result<Phase> {
+    let mut git_ops = GitOperations::new(Repository::init(dir)?);
+    git_ops.set_identity(Some("GitHub Grid Bench".to_string()), Some("bench@example.com".to_string()));
+
+    let timer = Instant::now();
+    for commit in &plan.commits {
+        git_ops.create_commit(commit)?;
+    }
+    Ok(Phase { commits: plan.len(), elapsed: timer.elapsed() })
+}
+
+fn scratch_dir() -> PathBuf {
+    std::env::temp_dir().join(format!("github-grid-bench-{}", std::process::id()))
+}
@@ -0,0 +1,37 @@
+use signal_hook::consts::{SIGINT, SIGTERM};
+use std::sync::Arc;
+use std::sync::atomic::{AtomicBool, Ordering};
+use crate::error::Result;
+
+// Exit statuses used when a second signal forces an immediate exit
+const SIGINT_EXIT_CODE: i32 = 130;
+const SIGTERM_EXIT_CODE: i32 = 143;
+
+// Cancellation flag shared between the signal handler, the run loop and any
+// running git child process. Atomic, so setting it from the handler is race-free
+#[derive(Clone, Default)]
+pub struct CancellationToken {
+    cancelled: Arc<AtomicBool>,
+}
+
+impl CancellationToken {
+    pub fn new() -> Self {
+        Self::default()
+    }
+    
+    // Token that is cancelled on the first SIGINT or SIGTERM, requesting a
+    // graceful shutdown. A second signal exits the process immediately
+    pub fn on_shutdown_signals() -> Result<Self> {
+        let token = Self::new();
+        for (signal, exit_code) in [(SIGINT, SIGINT_EXIT_CODE), (SIGTERM, SIGTERM_EXIT_CODE)] {
+            // Registered first so it only fires once the flag is already set
+            signal_hook::flag::register_conditional_shutdown(signal, exit_code, Arc::clone(&token.cancelled))?;
+            signal_hook::flag::register(signal, Arc::clone(&token.cancelled))?;
+        }
+        Ok(token)
+    }
+    
+    pub fn is_cancelled(&self) -> bool {
+        self.cancelled.load(Ordering::SeqCst)
+    }
+}
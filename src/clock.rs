@@ -0,0 +1,39 @@
+use chrono::{DateTime, Local, NaiveDate};
+
+// Source of "now" so callers can freeze time (tests) or drive scheduling
+// from something other than the wall clock
+pub trait Clock: Send + Sync {
+    fn now(&self) -> DateTime<Local>;
+
+    fn today(&self) -> NaiveDate {
+        self.now().date_naive()
+    }
+}
+
+// The real wall clock
+#[derive(Debug, Clone, Copy, Default)]
+pub struct SystemClock;
+
+impl Clock for SystemClock {
+    fn now(&self) -> DateTime<Local> {
+        Local::now()
+    }
+}
+
+// A clock frozen at a fixed instant
+#[derive(Debug, Clone, Copy)]
+pub struct FixedClock {
+    now: DateTime<Local>,
+}
+
+impl FixedClock {
+    pub fn new(now: DateTime<Local>) -> Self {
+        Self { now }
+    }
+}
+
+impl Clock for FixedClock {
+    fn now(&self) -> DateTime<Local> {
+        self.now
+    }
+}
@@ -0,0 +1,112 @@
+use chrono::{DateTime, FixedOffset, NaiveDate};
+use std::collections::BTreeMap;
+
+use github_grid::error::Result;
+use github_grid::plan::{Plan, PlanOutline, Planner};
+
+// Days a backfill leaves alone, taken out of each part of a streamed plan as
+// it's generated, the way skip_existing and skip_days take them out of a
+// whole one
+#[derive(Debug, Default)]
+pub struct Skips {
+    // Generated commits earlier runs made per day; those days are only
+    // topped up
+    pub existing: BTreeMap<NaiveDate, u32>,
+    // Days with real commits, and days with contributions on GitHub
+    pub active: BTreeMap<NaiveDate, u32>,
+    pub github_active: BTreeMap<NaiveDate, u32>,
+    // First day kept once the range is clipped to the default profile view
+    pub from: Option<NaiveDate>,
+}
+
+impl Skips {
+    // Take the skipped commits out of `part`. Returns how many went for
+    // existing, active and github_active
+    fn apply(&self, part: &mut Plan) -> [usize; 3] {
+        if let Some(from) = self.from {
+            part.commits.retain(|commit| commit.date.date_naive() >= from);
+        }
+        [part.skip_existing(&self.existing), part.skip_days(&self.active), part.skip_days(&self.github_active)]
+    }
+}
+
+// The commits a run makes: a plan held whole (--today, or one to edit or
+// save), or one generated a part at a time as it's applied so a decade-long
+// range never sits in memory at once
+pub enum RunPlan {
+    Whole(Plan),
+    Streamed(Planner, Skips),
+}
+
+impl RunPlan {
+    // The plan's parts in order; a streamed plan is generated again each time
+    pub fn parts(&self) -> Box<dyn Iterator<Item = Result<Plan>> + '_> {
+        match self {
+            RunPlan::Whole(plan) => Box::new(plan.years().map(Ok)),
+            RunPlan::Streamed(planner, skips) => Box::new(planner.stream().map(move |part| {
+                part.map(|mut part| {
+                    skips.apply(&mut part);
+                    part
+                })
+            })),
+        }
+    }
+
+    // The plan counted by day, a part at a time for a streamed one, with
+    // how many commits were skipped for existing, active and github_active
+    pub fn outline(&self) -> Result<(PlanOutline, [usize; 3])> {
+        match self {
+            RunPlan::Whole(plan) => Ok((plan.outline(), [0; 3])),
+            RunPlan::Streamed(planner, skips) => {
+                let options = planner.options();
+                let start = skips.from.map_or(options.start, |from| from.max(options.start));
+                let mut outline = PlanOutline::new(start, options.end, planner.seed());
+                let mut skipped = [0; 3];
+                for part in planner.stream() {
+                    let mut part = part?;
+                    for (total, count) in skipped.iter_mut().zip(skips.apply(&mut part)) {
+                        *total += count;
+                    }
+                    outline.add(&part);
+                }
+                Ok((outline, skipped))
+            }
+        }
+    }
+
+    // When the first commit is made; only generates up to the first part
+    // with any
+    pub fn first_commit(&self) -> Result<Option<DateTime<FixedOffset>>> {
+        for part in self.parts() {
+            if let Some(commit) = part?.commits.first() {
+                return Ok(Some(commit.date));
+            }
+        }
+        Ok(None)
+    }
+
+    // Leave out the days before `from`
+    pub fn clip(&mut self, from: NaiveDate) {
+        match self {
+            RunPlan::Whole(plan) => {
+                plan.commits.retain(|commit| commit.date.date_naive() >= from);
+                plan.start = from;
+            }
+            RunPlan::Streamed(_, skips) => skips.from = Some(from),
+        }
+    }
+
+    // The whole plan, generated all at once, e.g. to edit or save it
+    pub fn into_whole(self, outline: &PlanOutline) -> Result<Plan> {
+        match self {
+            RunPlan::Whole(plan) => Ok(plan),
+            streamed => {
+                let mut commits = Vec::new();
+                for part in streamed.parts() {
+                    commits.extend(part?.commits);
+                }
+                Ok(Plan { start: outline.start, end: outline.end, seed: outline.seed, commits })
+            }
+        }
+    }
+}
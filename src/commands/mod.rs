@@ -0,0 +1,4 @@
+// Run modes of the CLI with enough of their own to keep out of main.rs
+pub mod backfill;
+pub mod top_up;
+pub mod workspace;
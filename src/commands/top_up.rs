@@ -0,0 +1,82 @@
+use chrono::Weekday;
+use std::path::Path;
+
+use github_grid::clock::{Clock, SystemClock};
+use github_grid::config::Profile;
+use github_grid::daemon::{TopUpOptions, plan_top_up};
+use github_grid::error::Result;
+use github_grid::git_ops::GitOperations;
+use github_grid::holidays::HolidayCalendar;
+use github_grid::messages::MessagePool;
+use github_grid::output::green;
+use github_grid::plan::Plan;
+use github_grid::state::RunState;
+use github_grid::status;
+use github_grid::timezone::TimezoneSchedule;
+
+use crate::{Cli, JsonSummary};
+
+// What topping up to now plans with, for --today and the daemon alike.
+// Flags first, then the profile
+pub fn options(
+    git_ops: &GitOperations,
+    cli: &Cli,
+    profile: &Profile,
+    pattern: String,
+    timezones: TimezoneSchedule,
+    weekend: Vec<Weekday>,
+    holidays: HolidayCalendar,
+    messages: MessagePool,
+) -> Result<TopUpOptions> {
+    Ok(TopUpOptions {
+        pattern,
+        work_hours: profile.hours,
+        timezones,
+        weekend: Some(weekend),
+        holidays,
+        messages,
+        weekday_ranges: profile.weekdays.clone(),
+        curve: profile.curve,
+        big_days: profile.big_days,
+        skip_weekday_chance: cli.skip_weekday_chance.or(profile.skip_weekday_chance),
+        skip_weekend_chance: cli.skip_weekend_chance.or(profile.skip_weekend_chance),
+        day_correlation: profile.day_correlation,
+        commit_offset: profile.commit_offset,
+        graph_timezone: profile.github_timezone,
+        design_background: profile.design_background,
+        seed: seed(git_ops, cli, profile)?,
+    })
+}
+
+// --seed, the profile's, or the one kept in the run state. Each --today run
+// from a timer or service is a new process, and a new seed every hour would
+// lay out the rest of the day differently each time
+fn seed(git_ops: &GitOperations, cli: &Cli, profile: &Profile) -> Result<u64> {
+    if let Some(seed) = cli.seed.or(profile.seed) {
+        return Ok(seed);
+    }
+    let mut state = RunState::load(git_ops.repo())?;
+    if let Some(seed) = state.top_up_seed {
+        return Ok(seed);
+    }
+    let seed = rand::random();
+    state.top_up_seed = Some(seed);
+    state.save(git_ops.repo())?;
+    Ok(seed)
+}
+
+// --today: the commits due by now that the repository doesn't have yet.
+// None when there are none, which is reported here
+pub fn plan_today(git_ops: &mut GitOperations, options: &TopUpOptions, json: bool, repo_path: &Path) -> Result<Option<Plan>> {
+    let plan = plan_top_up(git_ops, options, SystemClock.now())?;
+    status!("Topping up: {} commits due by now (seed {})", plan.len(), plan.seed);
+    if plan.is_empty() {
+        if json {
+            JsonSummary::new("up_to_date", Some(&plan.outline()), repo_path).print();
+        } else {
+            println!("{}", green("✅ Already up to date"));
+        }
+        return Ok(None);
+    }
+    Ok(Some(plan))
+}
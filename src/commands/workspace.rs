@@ -0,0 +1,248 @@
+use chrono::Datelike;
+use git2::Repository;
+use std::collections::BTreeMap;
+use std::path::PathBuf;
+use std::time::Instant;
+
+use github_grid::cancel::CancellationToken;
+use github_grid::clock::{Clock, SystemClock};
+use github_grid::config::Profile;
+use github_grid::error::{GitHubGridError, Result};
+use github_grid::executor::{RunOutcome, RunReport, determine_date_range};
+use github_grid::git_ops::GitOperations;
+use github_grid::graph;
+use github_grid::holidays::HolidayCalendar;
+use github_grid::hooks::RunHooks;
+use github_grid::lock::RepoLock;
+use github_grid::messages::MessagePool;
+use github_grid::output::{green, red, yellow};
+use github_grid::patterns::PatternConfig;
+use github_grid::plan::{Plan, PlanOptions, Planner, Strategy};
+use github_grid::prompt::confirm;
+use github_grid::status;
+use github_grid::timezone::TimezoneSchedule;
+use github_grid::workspace::{self, WorkspaceRepo};
+
+use crate::{
+    CANCELLED_EXIT_CODE, Cli, GeneratedFiles, JsonSummary, PARTIAL_EXIT_CODE, apply_plan, check_checkout,
+    check_counts, committer_date, git_operations, prepare_origin,
+};
+
+// A repository of a workspace run, opened and locked
+struct Member {
+    path: PathBuf,
+    git_ops: GitOperations,
+    _lock: RepoLock,
+    plan: Plan,
+}
+
+// A profile with a workspace: one plan for the account, split over its
+// repositories by share, each topped up and applied on its own. Runs one
+// repository after another, or all at once with workspace_parallel
+pub fn run(cli: Cli, profile: Profile, workspace: Vec<WorkspaceRepo>, messages: MessagePool, deadline: Option<Instant>) -> Result<()> {
+    if profile.years.is_some() {
+        return Err(GitHubGridError::Config("Per-year settings aren't supported in workspace runs".to_string()));
+    }
+    let committer_date = committer_date(&cli, &profile)?;
+    let generated = GeneratedFiles::resolve(&cli, &profile)?;
+    let cancel = CancellationToken::on_shutdown_signals()?;
+    
+    // Every repository goes through the checks a single one does before
+    // anything is planned
+    let mut members = Vec::new();
+    for entry in &workspace {
+        status!("📂 {}", entry.repo.display());
+        let repo = Repository::open(&entry.repo)?;
+        let lock = RepoLock::acquire(&repo)?;
+        let mut git_ops = git_operations(repo, &cli, &profile, committer_date, &generated);
+        check_checkout(&git_ops, &cli, true)?;
+        check_counts(&git_ops, &cli)?;
+        if !prepare_origin(&mut git_ops, &cli, &cancel)? {
+            println!("Aborted, nothing was committed");
+            return Ok(());
+        }
+        members.push(Member { path: entry.repo.clone(), git_ops, _lock: lock, plan: Plan::default() });
+    }
+    
+    // The range covers whatever any repository still needs; each is only
+    // topped up to its share below
+    let mut ranges = Vec::new();
+    for member in &mut members {
+        ranges.push(determine_date_range(&mut member.git_ops, &SystemClock, cli.start.clone(), cli.end.clone())?);
+    }
+    let start_date = ranges.iter().map(|(start, _, _)| *start).min().unwrap_or_else(|| SystemClock.today());
+    let end_date = ranges.iter().map(|(_, end, _)| *end).max().unwrap_or_else(|| SystemClock.today());
+    let resume_seed = ranges.iter().find_map(|(_, _, seed)| *seed);
+    status!("🗂️  Workspace of {} repositories, {} to {}", members.len(), start_date, end_date);
+    
+    let pattern = cli.pattern.clone().or(profile.pattern.clone()).unwrap_or_else(|| "realistic".to_string());
+    let strategy = match cli.target_total.or(profile.target_total) {
+        Some(total) => {
+            let mut existing = 0;
+            for member in &members {
+                existing += member.git_ops.count_commits_in_year(start_date.year())?;
+            }
+            status!("🎯 Target: {} commits total for {} ({} existing)", total, start_date.year(), existing);
+            Strategy::Target { total, existing }
+        }
+        None => {
+            status!("Pattern: {}", pattern);
+            Strategy::Pattern(pattern)
+        }
+    };
+    let is_target = matches!(strategy, Strategy::Target { .. });
+    let weekend = profile.weekend.clone().unwrap_or_else(|| PatternConfig::default().weekend);
+    let mut holidays = HolidayCalendar::new(profile.holidays.as_deref())?;
+    if let Some(path) = &profile.holidays_file {
+        holidays.add_file(path)?;
+    }
+    let plan = Planner::new(PlanOptions {
+        seed: cli.seed.or(profile.seed).or(resume_seed),
+        work_hours: profile.hours,
+        timezones: TimezoneSchedule::new(profile.timezones.clone().unwrap_or_default())?,
+        weekend: Some(weekend.clone()),
+        holidays: Some(holidays),
+        messages: Some(messages),
+        weekday_ranges: profile.weekdays.clone(),
+        curve: profile.curve,
+        big_days: profile.big_days,
+        skip_weekday_chance: cli.skip_weekday_chance.or(profile.skip_weekday_chance),
+        skip_weekend_chance: cli.skip_weekend_chance.or(profile.skip_weekend_chance),
+        day_correlation: profile.day_correlation,
+        commit_offset: profile.commit_offset,
+        graph_timezone: profile.github_timezone,
+        design_background: profile.design_background,
+        ..PlanOptions::new(start_date, end_date, strategy)
+    }).build()?;
+    status!("Generated {} commits (seed {})", plan.len(), plan.seed);
+    
+    let lifecycles = profile.workspace_lifecycles.unwrap_or(false);
+    for (member, mut share) in members.iter_mut().zip(workspace::split(&plan, &workspace, lifecycles)?) {
+        // Targets already count what exists; patterns only top days up
+        if !is_target {
+            share.skip_existing(&member.git_ops.autogen_commits_per_day(start_date, end_date)?);
+        }
+        member.plan = share;
+    }
+    
+    if !cli.json {
+        let width = members.iter().map(|member| member.path.display().to_string().len()).max().unwrap_or(0);
+        println!("\n🗂️  Shares:");
+        for (member, entry) in members.iter().zip(&workspace) {
+            println!("  {:<width$}  {:>6} commits (share {})", member.path.display().to_string(), member.plan.len(), entry.share, width = width);
+        }
+        if lifecycles {
+            println!("\n🔄 Focus:");
+            for focus in workspace::lifecycles(&plan, &workspace) {
+                println!("  {} to {}  {}", focus.start.max(plan.start), focus.end.min(plan.end), members[focus.repo].path.display());
+            }
+        }
+        let mut combined = BTreeMap::new();
+        for (day, count) in members.iter().flat_map(|member| member.plan.per_day()) {
+            *combined.entry(day).or_insert(0) += count;
+        }
+        graph::show_summary(&combined, &weekend);
+    }
+    if cli.dry_run {
+        if cli.json {
+            let summaries: Vec<JsonSummary> = members.iter()
+                .map(|member| JsonSummary::new("dry_run", Some(&member.plan.outline()), &member.path))
+                .collect();
+            println!("{}", serde_json::to_string(&summaries).unwrap_or_default());
+        }
+        return Ok(());
+    }
+    if !cli.yes && !confirm("Create these commits?")? {
+        println!("Aborted, nothing was committed");
+        return Ok(());
+    }
+    
+    let hooks = RunHooks {
+        pre_run: profile.pre_run.clone(),
+        post_run: profile.post_run.clone(),
+        timeout: members.first().and_then(|member| member.git_ops.command_timeout()),
+    };
+    hooks.pre_run(&cancel)?;
+    
+    let maintenance = cli.maintenance || profile.maintenance.unwrap_or(false);
+    let apply = |member: &mut Member| -> Result<RunReport> {
+        status!("📦 {}: {} commits", member.path.display(), member.plan.len());
+        apply_plan(&mut member.git_ops, &member.plan.outline(), member.plan.years().map(Ok), false, maintenance, deadline, &cancel)
+    };
+    let reports: Vec<Result<RunReport>> = if profile.workspace_parallel.unwrap_or(false) {
+        std::thread::scope(|scope| {
+            let handles: Vec<_> = members.iter_mut().map(|member| scope.spawn(|| apply(member))).collect();
+            handles.into_iter()
+                .map(|handle| handle.join().unwrap_or_else(|_| Err(GitHubGridError::Repository("Run panicked".to_string()))))
+                .collect()
+        })
+    } else {
+        let mut reports = Vec::new();
+        for member in &mut members {
+            if cancel.is_cancelled() {
+                break;
+            }
+            reports.push(apply(member));
+        }
+        reports
+    };
+    
+    // Combined report
+    let mut summaries = Vec::new();
+    let mut failed = 0;
+    let mut outcome = RunOutcome::Complete;
+    if !cli.json {
+        println!("\n📊 Workspace:");
+    }
+    for (member, report) in members.iter().zip(&reports) {
+        match report {
+            Ok(report) => {
+                // Runs even after Ctrl+C, as for a single repository
+                hooks.post_run(&member.plan.outline(), report, &member.path.display().to_string(), &CancellationToken::new());
+                if report.outcome != RunOutcome::Complete {
+                    outcome = report.outcome;
+                }
+                if !cli.json {
+                    println!("  {} {}: {} of {} commits ({}){}", green("✅"), member.path.display(), report.created, member.plan.len(),
+                        report.outcome.as_str(), if report.pending_push { ", push pending" } else { "" });
+                }
+                summaries.push(JsonSummary {
+                    created: report.created,
+                    pending_push: report.pending_push,
+                    ..JsonSummary::new(report.outcome.as_str(), Some(&member.plan.outline()), &member.path)
+                });
+            }
+            Err(e) => {
+                failed += 1;
+                if !cli.json {
+                    println!("  {} {}: {}", red("❌"), member.path.display(), e);
+                }
+                summaries.push(JsonSummary::new("failed", Some(&member.plan.outline()), &member.path));
+            }
+        }
+    }
+    for member in &members[reports.len()..] {
+        outcome = RunOutcome::Cancelled;
+        if !cli.json {
+            println!("  {} {}: not started", yellow("🛑"), member.path.display());
+        }
+        summaries.push(JsonSummary::new("cancelled", Some(&member.plan.outline()), &member.path));
+    }
+    if cli.json {
+        println!("{}", serde_json::to_string(&summaries).unwrap_or_default());
+    } else {
+        let created: usize = reports.iter().flatten().map(|report| report.created).sum();
+        println!("  Total: {} commits created in {} repositories", created, members.len());
+    }
+    
+    if failed > 0 {
+        return Err(GitHubGridError::Repository(format!("{} of {} repositories failed", failed, members.len())));
+    }
+    // process::exit skips destructors, so release the locks explicitly
+    drop(members);
+    match outcome {
+        RunOutcome::Complete => Ok(()),
+        RunOutcome::Partial => std::process::exit(PARTIAL_EXIT_CODE),
+        RunOutcome::Cancelled => std::process::exit(CANCELLED_EXIT_CODE),
+    }
+}
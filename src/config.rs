@@ -0,0 +1,789 @@
+use chrono::Weekday;
+use serde::Deserialize;
+use std::collections::BTreeMap;
+use std::env;
+use std::fs;
+use std::path::{Path, PathBuf};
+use crate::activity;
+use crate::content::{self, ContentKind};
+use crate::error::{GitHubGridError, Result};
+use crate::holidays::HolidayCalendar;
+use crate::messages::MessageEntry;
+use crate::patterns::{ActivityCurve, WeekdayRanges};
+use crate::timezone::{CommitOffset, GraphTimezone, TimezonePeriod, TimezoneSchedule};
+use crate::workspace::{self, WorkspaceRepo};
+
+// Every config key can also be set as GITHUB_GRID_<KEY>, e.g.
+// GITHUB_GRID_TARGET_TOTAL=3000. Precedence: flag > env > file > default
+const ENV_PREFIX: &str = "GITHUB_GRID_";
+
+// User config, by default at ~/.config/github-grid/config.toml:
+//
+//   default_profile = "personal"
+//
+//   [profiles.personal]
+//   repo = "~/github/me-grid"
+//   pattern = "active"
+//
+//   [profiles.work-account]
+//   repo = "~/github/work-grid"
+//   name = "Jane Doe"
+//   email = "jane@company.example"
+//   token_env = "GH_TOKEN_WORK"
+//   target_total = 3000
+//   hours = [9, 18]
+//   timezones = ["2025-03-01..2025-03-14@+09:00"]
+//   weekend = ["fri", "sat"]
+//   holidays = "de"
+//   holidays_file = "~/.config/github-grid/days-off.txt"
+//   message_pack = "de"
+//   messages = ["Fix typo", { text = "Bump dependencies", weight = 4 }]
+//   message_daily_limit = 2
+//   curve = "developer"
+//   big_days = 0.01
+//   skip_weekday_chance = 0.3
+//   skip_weekend_chance = 0.9
+//   day_correlation = 0.5
+//   commit_offset = "+00:00"
+//   github_timezone = "-05:00"
+//   design_background = 0.3
+//   skip_active_days = true
+//   skip_github_active_days = true
+//   maintenance = true
+//   preflight = true
+//   pull_requests = 0.1
+//   issues = 0.05
+//   committer_jitter = "20m"
+//   real_committer_date = true
+//   activity_log = true
+//   activity_log_file = "log/{year}.log"
+//   activity_template = "- {date}: {message}"
+//   grid_dir = ".grid"
+//   content = ["go", "yaml", "markdown"]
+//   assets = true
+//   pre_run = "wg-quick up work"
+//   post_run = "curl -fsS https://dashboard.example/refresh"
+//
+//   [profiles.work-account.weekdays]
+//   mon = [0, 4]
+//   wed = [8, 20]
+//
+//   [profiles.work-account.years]
+//   2022 = { pattern = "casual" }
+//   2023 = { target_total = 1500 }
+//
+//   [profiles.projects]
+//   workspace = [{ repo = "~/src/api", share = 3 }, { repo = "~/src/web" }]
+//   workspace_parallel = true
+//   workspace_lifecycles = true
+//
+// Written by `config init`: every key, commented out, with what it does
+pub const TEMPLATE: &str = r#"# github-grid configuration
+#
+# Each profile holds the settings for one account or grid repository; the
+# flags of a run take precedence, then GITHUB_GRID_<KEY> environment
+# variables, then this file. Uncomment what you need.
+
+default_profile = "default"
+
+[profiles.default]
+# Grid repository (also settable with --repo)
+# repo = "~/github/me-grid"
+
+# Commit identity instead of user.name/user.email from git config
+# name = "Jane Doe"
+# email = "jane@users.noreply.github.com"
+
+# Environment variable holding this account's GitHub token, used as GH_TOKEN
+# for gh and git pushes (instead of the gh login)
+# token_env = "GH_TOKEN_WORK"
+
+# Activity pattern (see `github-grid patterns`), or a yearly commit total
+# pattern = "realistic"
+# target_total = 3000
+
+# Fixed seed, so every run plans the same commits for the same days
+# seed = 42
+
+# First and last hour of the day commits may land in
+# hours = [9, 18]
+
+# Periods committed from another UTC offset, START..END@OFFSET
+# timezones = ["2025-03-01..2025-03-14@+09:00"]
+
+# UTC offset commits are recorded with otherwise: "local" or e.g. "+00:00"
+# commit_offset = "local"
+
+# Timezone set on your GitHub account: "local" or e.g. "-05:00"
+# github_timezone = "local"
+
+# Days treated as the weekend
+# weekend = ["sat", "sun"]
+
+# Public holidays by country code ("none" for only your own), plus a file of
+# extra days off (2025-08-18 or 2025-08-18..2025-08-29 per line)
+# holidays = "de"
+# holidays_file = "~/.config/github-grid/days-off.txt"
+
+# Commit subjects: a bundled language or harvested pack, or your own list
+# message_pack = "de"
+# messages = ["Fix typo", { text = "Bump dependencies", weight = 4 }]
+# message_daily_limit = 2
+
+# Shape of the week and the day: "uniform" or "developer"
+# curve = "developer"
+
+# Chance per working day of an outlier day (0 to 1)
+# big_days = 0.01
+
+# Chance an ordinary weekday or weekend day is skipped (0 to 1), instead of
+# the pattern's; streaks, busy weeks and holidays still shift it
+# skip_weekday_chance = 0.3
+# skip_weekend_chance = 0.9
+
+# How much each day's count follows the day before (0 to below 1)
+# day_correlation = 0.5
+
+# Chance of a few light commits around initials/hearts designs (0 to 1)
+# design_background = 0.3
+
+# Leave days alone that have commits made by hand, in this repository or
+# anywhere on GitHub
+# skip_active_days = true
+# skip_github_active_days = true
+
+# Committer dates: up to this long after the author date, or the real time
+# of the run (only one of the two)
+# committer_jitter = "20m"
+# real_committer_date = true
+
+# Everything generated commits write goes under this directory
+# grid_dir = ".grid"
+
+# Each commit appends a line to .grid/activity.log instead of being empty;
+# activity_log_file picks another name in the grid directory, which can
+# follow the commit's {date}, {year}, {month} or {day}, and
+# activity_template how each line reads, from those and {time}, {offset},
+# {message}, {n} (the line's number) and {word} (a random word; default
+# "{date} {time} {offset} {message}")
+# activity_log = true
+# activity_log_file = "log/{year}.log"
+# activity_template = "- {date}: {message}"
+
+# Each commit makes a small change to Go, YAML or Markdown files in the
+# grid directory, one of the listed kinds at random
+# content = ["go", "yaml", "markdown"]
+# Now and then a tiny icon or test fixture instead (needs content)
+# assets = true
+
+# git gc --auto and commit-graph after each run
+# maintenance = true
+
+# Push a probe commit and check GitHub attributes it before each run
+# preflight = true
+
+# Chance per run of also opening and merging one trivial pull request in
+# the grid repository (0 to 1). It counts on the day it's opened
+# pull_requests = 0.1
+
+# Chance per run of opening an issue there, fixed a few days later by a
+# commit that references it; also counts on the day it's opened
+# issues = 0.05
+
+# Several repositories sharing the activity instead of `repo`, by share;
+# processed one after another unless workspace_parallel is set
+# workspace = [{ repo = "~/src/api", share = 3 }, { repo = "~/src/web" }]
+# workspace_parallel = true
+# Bursts of focus on one repository for a few weeks at a time, tapering off
+# as the next one ramps up, instead of steady shares
+# workspace_lifecycles = true
+
+# Shell commands run before generation and after the final push
+# pre_run = "wg-quick up work"
+# post_run = "curl -fsS https://dashboard.example/refresh"
+
+# Commit-count ranges for individual weekdays
+# [profiles.default.weekdays]
+# mon = [0, 4]
+# wed = [8, 20]
+
+# Pattern or target for individual years of a multi-year range
+# [profiles.default.years]
+# 2023 = { pattern = "casual" }
+# 2024 = { target_total = 2500 }
+"#;
+
+// GITHUB_GRID_CONFIG and GITHUB_GRID_PROFILE stand in for --config/--profile
+#[derive(Debug, Default, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct Config {
+    // Profile used when --profile is not given
+    pub default_profile: Option<String>,
+    #[serde(default)]
+    pub profiles: BTreeMap<String, Profile>,
+}
+
+// Settings for one account; anything left out falls back to the CLI defaults
+#[derive(Debug, Default, Clone, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct Profile {
+    pub repo: Option<PathBuf>,
+    // Commit identity, overriding user.name/user.email from git config
+    pub name: Option<String>,
+    pub email: Option<String>,
+    // Environment variable holding the account's GitHub token, e.g. for a
+    // second account run with --profiles; gh and git get it as GH_TOKEN
+    pub token_env: Option<String>,
+    #[serde(alias = "intensity")]
+    pub pattern: Option<String>,
+    pub target_total: Option<u32>,
+    // Fixed plan seed, e.g. to keep a scheduled top-up stable across runs
+    pub seed: Option<u64>,
+    // First and last hour of the day commits may land in
+    pub hours: Option<(u32, u32)>,
+    // Periods committed from another UTC offset, e.g. while travelling
+    pub timezones: Option<Vec<TimezonePeriod>>,
+    // Days treated as the weekend, e.g. ["fri", "sat"] (default Saturday/Sunday)
+    pub weekend: Option<Vec<Weekday>>,
+    // Bundled public holiday set by country code ("none" for additions only),
+    // plus a file of extra days off
+    pub holidays: Option<String>,
+    pub holidays_file: Option<PathBuf>,
+    // Language of the commit subjects ("de", ...) or the name of a pack in
+    // the messages directory
+    pub message_pack: Option<String>,
+    // Weighted commit subjects replacing the pack
+    pub messages: Option<Vec<MessageEntry>>,
+    // Most times one subject may appear in a day (never twice in a row)
+    pub message_daily_limit: Option<u32>,
+    // Shape of the week and the day: "uniform" or "developer"
+    pub curve: Option<ActivityCurve>,
+    // Chance per working day of an outlier day far above the usual counts
+    pub big_days: Option<f64>,
+    // Chances a weekday or weekend day is skipped, instead of the pattern's
+    pub skip_weekday_chance: Option<f64>,
+    pub skip_weekend_chance: Option<f64>,
+    // Share of each day's count carried over from the day before, for
+    // multi-day streaks of intensity
+    pub day_correlation: Option<f64>,
+    // Offset commits are recorded with outside timezone periods ("local" or
+    // an offset), e.g. "+00:00" so every machine writes the same timestamps
+    pub commit_offset: Option<CommitOffset>,
+    // Timezone set on the GitHub account ("local" or an offset), so commits
+    // are counted on the day they were planned for
+    pub github_timezone: Option<GraphTimezone>,
+    // Chance of a few light commits on each empty cell of a design
+    pub design_background: Option<f64>,
+    // Leave days alone that already have commits made by hand in the repo
+    pub skip_active_days: Option<bool>,
+    // Same for days with any contribution on the GitHub account, in any
+    // repository, public or private
+    pub skip_github_active_days: Option<bool>,
+    // Run git gc --auto and write the commit-graph after each run
+    pub maintenance: Option<bool>,
+    // Push a probe commit and check GitHub attributes it before each run
+    pub preflight: Option<bool>,
+    // Chance per run of opening and merging a trivial pull request, so not
+    // every contribution is a commit
+    pub pull_requests: Option<f64>,
+    // Chance per run of opening an issue, closed a few days later with a
+    // generated fix commit
+    pub issues: Option<f64>,
+    // Most the committer date may trail the author date, e.g. "20m"
+    pub committer_jitter: Option<String>,
+    // Leave committer dates at the real time of the run; only author dates
+    // are backdated
+    pub real_committer_date: Option<bool>,
+    // Append a line to activity.log with every generated commit
+    pub activity_log: Option<bool>,
+    // Another name for it, in the grid directory, and how its lines read;
+    // either turns it on
+    pub activity_log_file: Option<String>,
+    pub activity_template: Option<String>,
+    // Directory, relative to the repository root, everything generated
+    // commits write goes in (.grid by default)
+    pub grid_dir: Option<String>,
+    // Kinds of small change each generated commit makes, one at random
+    pub content: Option<Vec<ContentKind>>,
+    // Now and then a tiny binary asset instead of a content change
+    pub assets: Option<bool>,
+    // Commit-count ranges for individual weekdays
+    pub weekdays: Option<WeekdayRanges>,
+    // Pattern or target for individual years of a multi-year range, keyed
+    // by year ("2023")
+    pub years: Option<BTreeMap<String, YearProfile>>,
+    // Repositories sharing the activity instead of `repo`, each with its own
+    // plan; run one after another, or all at once with workspace_parallel
+    pub workspace: Option<Vec<WorkspaceRepo>>,
+    pub workspace_parallel: Option<bool>,
+    // Shift focus between the repositories every few weeks, like projects
+    // that get a burst of work and then wind down, instead of fixed shares
+    pub workspace_lifecycles: Option<bool>,
+    // Shell commands run before generation and after the final push
+    pub pre_run: Option<String>,
+    pub post_run: Option<String>,
+}
+
+// Settings for one year of the range; one of the two, like --pattern and
+// --target-total
+#[derive(Debug, Default, Clone, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct YearProfile {
+    #[serde(alias = "intensity")]
+    pub pattern: Option<String>,
+    pub target_total: Option<u32>,
+}
+
+impl Config {
+    pub fn default_path() -> PathBuf {
+        let base = env::var("XDG_CONFIG_HOME")
+            .map(PathBuf::from)
+            .unwrap_or_else(|_| PathBuf::from(home_dir()).join(".config"));
+        base.join("github-grid").join("config.toml")
+    }
+
+    // User message packs, e.g. ~/.config/github-grid/messages/team.toml
+    pub fn messages_dir() -> PathBuf {
+        Self::default_path().with_file_name("messages")
+    }
+
+    // An explicitly given path must exist; a missing default file just means
+    // no config
+    pub fn load(path: Option<&Path>) -> Result<Self> {
+        let mut config = match path {
+            Some(path) => Self::read(path)?,
+            None => {
+                let path = Self::default_path();
+                if path.exists() { Self::read(&path)? } else { Self::default() }
+            }
+        };
+        if let Some(name) = env_var("DEFAULT_PROFILE") {
+            config.default_profile = Some(name);
+        }
+        Ok(config)
+    }
+
+    // Record the grid repository for a profile, so runs find it without
+    // --repo. The file is edited as text to keep its comments, and the file,
+    // the profile and default_profile are created as needed. Returns the
+    // profile it was saved to, or None when that profile already names one
+    pub fn save_repo(path: Option<&Path>, profile: Option<&str>, repo: &Path) -> Result<Option<String>> {
+        let path = path.map(Path::to_path_buf).unwrap_or_else(Self::default_path);
+        let content = if path.exists() {
+            fs::read_to_string(&path).map_err(|e| {
+                GitHubGridError::Config(format!("Cannot read {}: {}", path.display(), e))
+            })?
+        } else {
+            String::new()
+        };
+        let config: Self = toml::from_str(&content)
+            .map_err(|e| GitHubGridError::Config(format!("{}: {}", path.display(), e)))?;
+
+        let name = profile.or(config.default_profile.as_deref()).unwrap_or("default").to_string();
+        if config.profiles.get(&name).is_some_and(|profile| profile.repo.is_some()) {
+            return Ok(None);
+        }
+
+        let header = format!("[profiles.{}]", name);
+        let setting = format!("repo = {}", toml::Value::String(repo.display().to_string()));
+        let mut lines: Vec<String> = content.lines().map(str::to_string).collect();
+        match lines.iter().position(|line| line.trim() == header) {
+            Some(index) => lines.insert(index + 1, setting),
+            None => {
+                if lines.last().is_some_and(|line| !line.trim().is_empty()) {
+                    lines.push(String::new());
+                }
+                lines.extend([header, setting]);
+            }
+        }
+        // Top-level keys have to come before the first table
+        if profile.is_none() && config.default_profile.is_none() {
+            lines.splice(0..0, [format!("default_profile = \"{}\"", name), String::new()]);
+        }
+
+        if let Some(dir) = path.parent() {
+            fs::create_dir_all(dir)?;
+        }
+        fs::write(&path, lines.join("\n") + "\n")?;
+        Ok(Some(name))
+    }
+
+    // Check a config file beyond what loading it does: besides parsing
+    // (which reports unknown keys with their line and column), every profile
+    // must hold usable values. Problems come back as "FILE:LINE: message"
+    pub fn validate(path: &Path) -> Result<Vec<String>> {
+        let content = fs::read_to_string(path).map_err(|e| {
+            GitHubGridError::Config(format!("Cannot read {}: {}", path.display(), e))
+        })?;
+        let config: Self = match toml::from_str(&content) {
+            Ok(config) => config,
+            Err(e) => return Ok(vec![format!("{}: {}", path.display(), e.to_string().trim_end())]),
+        };
+
+        let at = |line: Option<usize>| match line {
+            Some(line) => format!("{}:{}", path.display(), line),
+            None => path.display().to_string(),
+        };
+        let mut problems = Vec::new();
+        if let Some(name) = &config.default_profile {
+            if !config.profiles.contains_key(name) {
+                let line = content.lines().position(|line| line.trim_start().starts_with("default_profile"));
+                problems.push(format!("{}: default_profile '{}' is not defined", at(line.map(|line| line + 1)), name));
+            }
+        }
+        for (name, profile) in &config.profiles {
+            for (key, message) in profile.problems() {
+                problems.push(format!("{}: profile '{}': {}", at(locate(&content, name, key)), name, message));
+            }
+        }
+        Ok(problems)
+    }
+
+    fn read(path: &Path) -> Result<Self> {
+        let content = fs::read_to_string(path).map_err(|e| {
+            GitHubGridError::Config(format!("Cannot read {}: {}", path.display(), e))
+        })?;
+        toml::from_str(&content)
+            .map_err(|e| GitHubGridError::Config(format!("{}: {}", path.display(), e)))
+    }
+
+    // The named profile, else the default one, else an empty profile, with
+    // GITHUB_GRID_* overrides applied on top
+    pub fn profile(&self, name: Option<&str>) -> Result<Profile> {
+        let mut profile = match name.or(self.default_profile.as_deref()) {
+            Some(name) => self.named_profile(name)?,
+            None => Profile::default(),
+        };
+        profile.apply_env()?;
+        profile.repo = profile.repo.map(|repo| expand_home(&repo));
+        profile.holidays_file = profile.holidays_file.map(|path| expand_home(&path));
+        for member in profile.workspace.iter_mut().flatten() {
+            member.repo = expand_home(&member.repo);
+        }
+        Ok(profile)
+    }
+
+    fn named_profile(&self, name: &str) -> Result<Profile> {
+        self.profiles.get(name).cloned().ok_or_else(|| {
+            let known: Vec<&str> = self.profiles.keys().map(String::as_str).collect();
+            GitHubGridError::Config(format!(
+                "Unknown profile '{}' (available: {})",
+                name,
+                if known.is_empty() { "none".to_string() } else { known.join(", ") }
+            ))
+        })
+    }
+}
+
+impl Profile {
+    // Values that parse but that a run would reject, as (key, message)
+    pub fn problems(&self) -> Vec<(&'static str, String)> {
+        let mut problems = Vec::new();
+        if let Some((first, last)) = self.hours {
+            if first > last || last > 23 {
+                problems.push(("hours", format!("hours {}-{}: expected 0 <= start <= end <= 23", first, last)));
+            }
+        }
+        for (key, chance) in [
+            ("big_days", self.big_days),
+            ("skip_weekday_chance", self.skip_weekday_chance),
+            ("skip_weekend_chance", self.skip_weekend_chance),
+            ("design_background", self.design_background),
+            ("pull_requests", self.pull_requests),
+            ("issues", self.issues),
+        ] {
+            if let Some(chance) = chance.filter(|chance| !(0.0..=1.0).contains(chance)) {
+                problems.push((key, format!("{} {}: expected a probability between 0 and 1", key, chance)));
+            }
+        }
+        if let Some(correlation) = self.day_correlation.filter(|correlation| !(0.0..1.0).contains(correlation)) {
+            problems.push(("day_correlation", format!("day_correlation {}: expected at least 0 and below 1", correlation)));
+        }
+        if let Some(periods) = &self.timezones {
+            if let Err(e) = TimezoneSchedule::new(periods.clone()) {
+                problems.push(("timezones", message(e)));
+            }
+        }
+        if let Some(Err(e)) = self.weekdays.as_ref().map(WeekdayRanges::validate) {
+            problems.push(("weekdays", message(e)));
+        }
+        if let Err(e) = self.year_profiles() {
+            problems.push(("years", message(e)));
+        }
+        if let Err(e) = HolidayCalendar::new(self.holidays.as_deref()) {
+            problems.push(("holidays", message(e)));
+        }
+        if let Some(path) = &self.holidays_file {
+            if !expand_home(path).exists() {
+                problems.push(("holidays_file", format!("holidays_file {} does not exist", path.display())));
+            }
+        }
+        for problem in self.workspace.as_deref().map(workspace::problems).unwrap_or_default() {
+            problems.push(("workspace", problem));
+        }
+        if let Some(problem) = self.grid_dir.as_deref().and_then(|dir| content::path_problem("grid_dir", dir)) {
+            problems.push(("grid_dir", problem));
+        }
+        if let Some(problem) = self.activity_log_file.as_deref().and_then(|log| activity::path_problem("activity_log_file", log)) {
+            problems.push(("activity_log_file", problem));
+        }
+        if let Some(problem) = self.activity_template.as_deref().and_then(|template| activity::template_problem("activity_template", template)) {
+            problems.push(("activity_template", problem));
+        }
+        if self.assets == Some(true) && self.content.as_ref().is_none_or(Vec::is_empty) {
+            problems.push(("assets", "assets needs content: they are added in place of some of its changes".to_string()));
+        }
+        if let Some(jitter) = &self.committer_jitter {
+            if let Err(e) = humantime::parse_duration(jitter) {
+                problems.push(("committer_jitter", format!("committer_jitter '{}': {}", jitter, e)));
+            }
+            if self.real_committer_date == Some(true) {
+                problems.push(("committer_jitter", "committer_jitter and real_committer_date can't both be set".to_string()));
+            }
+        }
+        problems
+    }
+
+    // Year settings keyed by year number
+    pub fn year_profiles(&self) -> Result<BTreeMap<i32, YearProfile>> {
+        let mut years = BTreeMap::new();
+        for (key, year) in self.years.iter().flatten() {
+            let number = key.parse().map_err(|_| {
+                GitHubGridError::Config(format!("Invalid year '{}' in years (expected e.g. 2023)", key))
+            })?;
+            if year.pattern.is_some() == year.target_total.is_some() {
+                return Err(GitHubGridError::Config(format!(
+                    "Year {} needs either a pattern or a target_total", key
+                )));
+            }
+            years.insert(number, year.clone());
+        }
+        Ok(years)
+    }
+
+    fn apply_env(&mut self) -> Result<()> {
+        if let Some(repo) = env_var("REPO") {
+            self.repo = Some(PathBuf::from(repo));
+        }
+        if let Some(name) = env_var("NAME") {
+            self.name = Some(name);
+        }
+        if let Some(email) = env_var("EMAIL") {
+            self.email = Some(email);
+        }
+        if let Some(var) = env_var("TOKEN_ENV") {
+            self.token_env = Some(var);
+        }
+        if let Some(members) = env_var("WORKSPACE") {
+            self.workspace = Some(members.split(',').map(str::parse).collect::<Result<_>>()?);
+        }
+        if let Some(parallel) = env_var("WORKSPACE_PARALLEL") {
+            self.workspace_parallel = Some(parallel.parse().map_err(|_| invalid_env("WORKSPACE_PARALLEL", &parallel))?);
+        }
+        if let Some(lifecycles) = env_var("WORKSPACE_LIFECYCLES") {
+            self.workspace_lifecycles = Some(lifecycles.parse().map_err(|_| invalid_env("WORKSPACE_LIFECYCLES", &lifecycles))?);
+        }
+        if let Some(pattern) = env_var("PATTERN").or_else(|| env_var("INTENSITY")) {
+            self.pattern = Some(pattern);
+        }
+        if let Some(command) = env_var("PRE_RUN") {
+            self.pre_run = Some(command);
+        }
+        if let Some(command) = env_var("POST_RUN") {
+            self.post_run = Some(command);
+        }
+        if let Some(total) = env_var("TARGET_TOTAL") {
+            self.target_total = Some(total.parse().map_err(|_| invalid_env("TARGET_TOTAL", &total))?);
+        }
+        if let Some(seed) = env_var("SEED") {
+            self.seed = Some(seed.parse().map_err(|_| invalid_env("SEED", &seed))?);
+        }
+        if let Some(pack) = env_var("MESSAGE_PACK") {
+            self.message_pack = Some(pack);
+        }
+        if let Some(limit) = env_var("MESSAGE_DAILY_LIMIT") {
+            self.message_daily_limit = Some(limit.parse().map_err(|_| invalid_env("MESSAGE_DAILY_LIMIT", &limit))?);
+        }
+        if let Some(curve) = env_var("CURVE") {
+            self.curve = Some(curve.parse()?);
+        }
+        if let Some(chance) = env_var("BIG_DAYS") {
+            self.big_days = Some(chance.parse().map_err(|_| invalid_env("BIG_DAYS", &chance))?);
+        }
+        if let Some(chance) = env_var("SKIP_WEEKDAY_CHANCE") {
+            self.skip_weekday_chance = Some(chance.parse().map_err(|_| invalid_env("SKIP_WEEKDAY_CHANCE", &chance))?);
+        }
+        if let Some(chance) = env_var("SKIP_WEEKEND_CHANCE") {
+            self.skip_weekend_chance = Some(chance.parse().map_err(|_| invalid_env("SKIP_WEEKEND_CHANCE", &chance))?);
+        }
+        if let Some(correlation) = env_var("DAY_CORRELATION") {
+            self.day_correlation = Some(correlation.parse().map_err(|_| invalid_env("DAY_CORRELATION", &correlation))?);
+        }
+        if let Some(chance) = env_var("DESIGN_BACKGROUND") {
+            self.design_background = Some(chance.parse().map_err(|_| invalid_env("DESIGN_BACKGROUND", &chance))?);
+        }
+        if let Some(offset) = env_var("COMMIT_OFFSET") {
+            self.commit_offset = Some(offset.parse()?);
+        }
+        if let Some(timezone) = env_var("GITHUB_TIMEZONE") {
+            self.github_timezone = Some(timezone.parse()?);
+        }
+        if let Some(skip) = env_var("SKIP_ACTIVE_DAYS") {
+            self.skip_active_days = Some(skip.parse().map_err(|_| invalid_env("SKIP_ACTIVE_DAYS", &skip))?);
+        }
+        if let Some(skip) = env_var("SKIP_GITHUB_ACTIVE_DAYS") {
+            self.skip_github_active_days = Some(skip.parse().map_err(|_| invalid_env("SKIP_GITHUB_ACTIVE_DAYS", &skip))?);
+        }
+        if let Some(maintenance) = env_var("MAINTENANCE") {
+            self.maintenance = Some(maintenance.parse().map_err(|_| invalid_env("MAINTENANCE", &maintenance))?);
+        }
+        if let Some(jitter) = env_var("COMMITTER_JITTER") {
+            self.committer_jitter = Some(jitter);
+        }
+        if let Some(real) = env_var("REAL_COMMITTER_DATE") {
+            self.real_committer_date = Some(real.parse().map_err(|_| invalid_env("REAL_COMMITTER_DATE", &real))?);
+        }
+        if let Some(log) = env_var("ACTIVITY_LOG") {
+            self.activity_log = Some(log.parse().map_err(|_| invalid_env("ACTIVITY_LOG", &log))?);
+        }
+        if let Some(log) = env_var("ACTIVITY_LOG_FILE") {
+            self.activity_log_file = Some(log);
+        }
+        if let Some(template) = env_var("ACTIVITY_TEMPLATE") {
+            self.activity_template = Some(template);
+        }
+        if let Some(dir) = env_var("GRID_DIR") {
+            self.grid_dir = Some(dir);
+        }
+        // Comma-separated, e.g. "go,yaml,markdown"
+        if let Some(content) = env_var("CONTENT") {
+            self.content = Some(content.split(',').map(str::parse).collect::<Result<_>>()?);
+        }
+        if let Some(assets) = env_var("ASSETS") {
+            self.assets = Some(assets.parse().map_err(|_| invalid_env("ASSETS", &assets))?);
+        }
+        if let Some(preflight) = env_var("PREFLIGHT") {
+            self.preflight = Some(preflight.parse().map_err(|_| invalid_env("PREFLIGHT", &preflight))?);
+        }
+        if let Some(chance) = env_var("PULL_REQUESTS") {
+            self.pull_requests = Some(chance.parse().map_err(|_| invalid_env("PULL_REQUESTS", &chance))?);
+        }
+        if let Some(chance) = env_var("ISSUES") {
+            self.issues = Some(chance.parse().map_err(|_| invalid_env("ISSUES", &chance))?);
+        }
+        // "mon=0-4,wed=8-20"
+        if let Some(weekdays) = env_var("WEEKDAYS") {
+            self.weekdays = Some(parse_weekday_ranges(&weekdays).ok_or_else(|| invalid_env("WEEKDAYS", &weekdays))?);
+        }
+        if let Some(hours) = env_var("HOURS") {
+            self.hours = Some(parse_range(&hours).ok_or_else(|| invalid_env("HOURS", &hours))?);
+        }
+        // Comma-separated, e.g. "2025-03-01..2025-03-14@+09:00,2025-08-04..2025-08-22@-04:00"
+        if let Some(timezones) = env_var("TIMEZONES") {
+            self.timezones = Some(timezones.split(',').map(str::parse).collect::<Result<_>>()?);
+        }
+        if let Some(country) = env_var("HOLIDAYS") {
+            self.holidays = Some(country);
+        }
+        if let Some(path) = env_var("HOLIDAYS_FILE") {
+            self.holidays_file = Some(PathBuf::from(path));
+        }
+        if let Some(weekend) = env_var("WEEKEND") {
+            self.weekend = Some(parse_weekdays(&weekend).ok_or_else(|| invalid_env("WEEKEND", &weekend))?);
+        }
+        // "2023=casual,2024=2500": a pattern, or a target when it's a number
+        if let Some(years) = env_var("YEARS") {
+            self.years = Some(parse_years(&years).ok_or_else(|| invalid_env("YEARS", &years))?);
+        }
+        // One per line, since subjects can hold commas; all weigh the same
+        if let Some(messages) = env_var("MESSAGES") {
+            self.messages = Some(messages.lines()
+                .map(str::trim)
+                .filter(|line| !line.is_empty())
+                .map(|line| MessageEntry::Text(line.to_string()))
+                .collect());
+        }
+        Ok(())
+    }
+}
+
+// Line of `key` in the table of profile `name` or one of its sub-tables
+fn locate(content: &str, name: &str, key: &str) -> Option<usize> {
+    let table = format!("profiles.{}", name);
+    let mut in_profile = false;
+    for (index, line) in content.lines().enumerate() {
+        let line = line.trim();
+        if line.starts_with('[') {
+            let header = line.trim_start_matches('[').split(']').next().unwrap_or("").trim();
+            if header == format!("{}.{}", table, key) {
+                return Some(index + 1);
+            }
+            in_profile = header == table || header.starts_with(&format!("{}.", table));
+        } else if in_profile && line.strip_prefix(key).is_some_and(|rest| rest.trim_start().starts_with('=')) {
+            return Some(index + 1);
+        }
+    }
+    None
+}
+
+// An error's message without the "Configuration error:" prefix
+fn message(e: GitHubGridError) -> String {
+    match e {
+        GitHubGridError::Config(message) | GitHubGridError::Parse(message) => message,
+        other => other.to_string(),
+    }
+}
+
+// Unset and empty variables are both treated as "not configured"
+fn env_var(key: &str) -> Option<String> {
+    env::var(format!("{}{}", ENV_PREFIX, key)).ok().filter(|value| !value.is_empty())
+}
+
+fn invalid_env(key: &str, value: &str) -> GitHubGridError {
+    GitHubGridError::Config(format!("Invalid {}{}: '{}'", ENV_PREFIX, key, value))
+}
+
+// "9-18" -> (9, 18)
+fn parse_range(value: &str) -> Option<(u32, u32)> {
+    let (first, last) = value.split_once('-')?;
+    Some((first.trim().parse().ok()?, last.trim().parse().ok()?))
+}
+
+// "fri,sat" -> [Fri, Sat]
+fn parse_weekdays(value: &str) -> Option<Vec<Weekday>> {
+    value.split(',').map(|day| day.trim().parse().ok()).collect()
+}
+
+// "2023=casual,2024=2500" -> casual for 2023, 2500 commits for 2024
+fn parse_years(value: &str) -> Option<BTreeMap<String, YearProfile>> {
+    value.split(',').map(|entry| {
+        let (year, setting) = entry.split_once('=')?;
+        let setting = setting.trim();
+        let profile = match setting.parse() {
+            Ok(total) => YearProfile { target_total: Some(total), ..YearProfile::default() },
+            Err(_) if !setting.is_empty() => YearProfile { pattern: Some(setting.to_string()), ..YearProfile::default() },
+            Err(_) => return None,
+        };
+        Some((year.trim().to_string(), profile))
+    }).collect()
+}
+
+fn parse_weekday_ranges(value: &str) -> Option<WeekdayRanges> {
+    let mut ranges = WeekdayRanges::default();
+    for entry in value.split(',') {
+        let (day, range) = entry.split_once('=')?;
+        ranges.set(day.trim().parse().ok()?, parse_range(range)?);
+    }
+    Some(ranges)
+}
+
+fn home_dir() -> String {
+    env::var("HOME").unwrap_or_else(|_| ".".to_string())
+}
+
+fn expand_home(path: &Path) -> PathBuf {
+    match path.strip_prefix("~") {
+        Ok(rest) => PathBuf::from(home_dir()).join(rest),
+        Err(_) => path.to_path_buf(),
+    }
+}
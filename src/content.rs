@@ -0,0 +1,443 @@
+use rand::Rng;
+use rand::seq::IndexedRandom;
+use serde::Deserialize;
+use std::str::FromStr;
+use crate::error::{GitHubGridError, Result};
+
+// Everything generated commits write lives under this directory (unless
+// grid_dir names another), so it can't collide with a project's own files
+pub const GRID_DIR: &str = ".grid";
+
+// Each kind's files under the grid directory, in the order they appear as
+// the repository grows: the first one from the start, the next once the
+// newest has grown for a while
+const GO_FILES: [&str; 8] = [
+    "util.go",
+    "internal/config/config.go",
+    "internal/store/store.go",
+    "internal/server/server.go",
+    "internal/worker/worker.go",
+    "internal/cache/cache.go",
+    "internal/retry/retry.go",
+    "internal/metrics/metrics.go",
+];
+const YAML_FILES: [&str; 6] = [
+    "config.yaml",
+    "config/logging.yaml",
+    "config/cache.yaml",
+    "deploy/staging.yaml",
+    "deploy/production.yaml",
+    "deploy/values.yaml",
+];
+const MARKDOWN_FILES: [&str; 6] = [
+    "NOTES.md",
+    "docs/setup.md",
+    "docs/usage.md",
+    "docs/architecture.md",
+    "docs/faq.md",
+    "docs/roadmap.md",
+];
+// Lines the newest file has when the next one is as likely to be started as
+// it is to be edited again
+const GROWN_LINES: f64 = 80.0;
+// Older files are edited less: each one half as often as the one after it
+const OLDER_WEIGHT: f64 = 0.5;
+
+// Go: a small helper function added, or one of its defaults changed
+// Functions are "<prefix><noun>", with a body that only needs the prefix
+const GO_PREFIXES: [&str; 5] = ["isValid", "clamp", "default", "sum", "has"];
+const GO_NOUNS: [&str; 10] = [
+    "Retry", "Timeout", "Batch", "Worker", "Port", "Limit", "Offset", "Page", "Buffer", "Token",
+];
+
+// YAML: one key set, or changed to another value
+const YAML_KEYS: [&str; 10] = [
+    "timeout", "retries", "workers", "cache_ttl", "log_level", "max_connections", "batch_size", "debug",
+    "port", "region",
+];
+
+// Markdown: a bullet added to a section, or a new section
+const MARKDOWN_SECTIONS: [&str; 6] = ["Setup", "Usage", "Configuration", "Troubleshooting", "Ideas", "Todo"];
+const MARKDOWN_VERBS: [&str; 6] = ["Document", "Check", "Revisit", "Clarify", "Simplify", "Automate"];
+const MARKDOWN_TOPICS: [&str; 8] = [
+    "the release steps",
+    "error messages",
+    "the default timeout",
+    "log rotation",
+    "the cache settings",
+    "local setup",
+    "the retry logic",
+    "upgrade notes",
+];
+
+// Assets: now and then a tiny binary file is added instead of a text change,
+// a 16x16 icon or a test fixture, up to a fixed number so the repository
+// never bloats
+pub const ASSET_CHANCE: f64 = 0.02;
+pub const MAX_ASSETS: usize = 24;
+pub const MAX_ASSET_BYTES: usize = 1024;
+pub const ASSET_DIRS: [&str; 2] = ["assets", "testdata"];
+const ICON_SIZE: usize = 16;
+const FIXTURE_BYTES: std::ops::RangeInclusive<usize> = 32..=512;
+
+// A kind of small change a generated commit makes, in place of an empty
+// commit, so `git show` looks like real work. Each kind keeps to its own
+// files, which appear one after another as the range goes on
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Deserialize)]
+#[serde(try_from = "String")]
+pub enum ContentKind {
+    Go,
+    Yaml,
+    Markdown,
+}
+
+impl ContentKind {
+    pub const ALL: [ContentKind; 3] = [Self::Go, Self::Yaml, Self::Markdown];
+
+    pub fn name(&self) -> &'static str {
+        match self {
+            Self::Go => "go",
+            Self::Yaml => "yaml",
+            Self::Markdown => "markdown",
+        }
+    }
+
+    pub fn files(&self) -> &'static [&'static str] {
+        match self {
+            Self::Go => &GO_FILES,
+            Self::Yaml => &YAML_FILES,
+            Self::Markdown => &MARKDOWN_FILES,
+        }
+    }
+
+    // Index into `files()` of the file the next commit changes, given the
+    // line counts of those that exist. The repository grows as it goes: the
+    // newest file is usually the one edited, older ones more and more
+    // rarely, and the bigger the newest has grown the likelier the next file
+    // is started instead
+    pub fn next_file(&self, lines: &[Option<usize>], rng: &mut impl Rng) -> usize {
+        let missing = lines.iter().position(Option::is_none);
+        let Some(newest) = lines.iter().rposition(Option::is_some) else {
+            return 0;
+        };
+        if let Some(missing) = missing {
+            let grown = (lines[newest].unwrap_or(0) as f64 / GROWN_LINES).powi(2);
+            if rng.random_bool(grown / (1.0 + grown)) {
+                return missing;
+            }
+        }
+
+        let weights: Vec<(usize, f64)> = (0..=newest)
+            .filter(|&index| lines[index].is_some())
+            .map(|index| (index, OLDER_WEIGHT.powi((newest - index) as i32)))
+            .collect();
+        let mut draw = rng.random_range(0.0..weights.iter().map(|(_, weight)| weight).sum::<f64>());
+        for &(index, weight) in &weights {
+            if draw < weight {
+                return index;
+            }
+            draw -= weight;
+        }
+        newest
+    }
+
+    // The next version of `path`, from its current one (None when it doesn't
+    // exist yet). Always differs from `current`, so no commit is empty
+    pub fn change(&self, path: &str, current: Option<&str>, rng: &mut impl Rng) -> String {
+        let (dir, file) = path.rsplit_once('/').unwrap_or(("", path));
+        let stem = file.split('.').next().unwrap_or(file);
+        match self {
+            Self::Go => {
+                let package = dir.rsplit('/').next().filter(|package| !package.is_empty()).unwrap_or("main");
+                change_go(current.unwrap_or(&format!("package {}\n", package)), rng)
+            }
+            Self::Yaml => change_yaml(current.unwrap_or(&format!("# {} settings\n", title(stem))), rng),
+            Self::Markdown => change_markdown(current.unwrap_or(&format!("# {}\n", title(stem))), rng),
+        }
+    }
+}
+
+// Why a path can't hold generated files (the grid directory, or the
+// activity log inside it), or None: it has to stay inside the repository
+// and out of .git
+pub fn path_problem(what: &str, path: &str) -> Option<String> {
+    let components: Vec<&str> = path.trim_end_matches('/').split('/').collect();
+    if path.trim().is_empty() || path.starts_with('/') || path.contains('\\') {
+        return Some(format!("{} '{}': expected a relative path such as {}", what, path, GRID_DIR));
+    }
+    if components.iter().any(|component| component.is_empty() || *component == "." || *component == "..") {
+        return Some(format!("{} '{}': expected a path without empty, . or .. parts", what, path));
+    }
+    if components[0].eq_ignore_ascii_case(".git") {
+        return Some(format!("{} '{}': can't be inside .git", what, path));
+    }
+    None
+}
+
+// Whether a path, relative to the grid directory, is one of the assets
+// content commits add
+pub fn is_asset_file(path: &str) -> bool {
+    let icon = path.strip_prefix("assets/icon-").and_then(|rest| rest.strip_suffix(".png"));
+    let fixture = path.strip_prefix("testdata/fixture-").and_then(|rest| rest.strip_suffix(".bin"));
+    icon.or(fixture).is_some_and(|number| !number.is_empty() && number.bytes().all(|byte| byte.is_ascii_digit()))
+}
+
+// The next asset, as its path and bytes, given the paths of those already
+// there; None once there are MAX_ASSETS
+pub fn next_asset(existing: &[String], rng: &mut impl Rng) -> Option<(String, Vec<u8>)> {
+    if existing.len() >= MAX_ASSETS {
+        return None;
+    }
+    let (template, bytes) = match rng.random_bool(0.5) {
+        true => ("assets/icon-{}.png", icon(rng)),
+        false => ("testdata/fixture-{}.bin", fixture(rng)),
+    };
+    let path = (1..)
+        .map(|number| template.replace("{}", &number.to_string()))
+        .find(|path| !existing.contains(path))?;
+    debug_assert!(bytes.len() <= MAX_ASSET_BYTES);
+    Some((path, bytes))
+}
+
+// A mirrored two-color pattern like an identicon, as an uncompressed PNG
+fn icon(rng: &mut impl Rng) -> Vec<u8> {
+    let color: [u8; 3] = [rng.random_range(40..=200), rng.random_range(40..=200), rng.random_range(40..=200)];
+    let cells: Vec<bool> = (0..(ICON_SIZE / 4) * (ICON_SIZE / 2)).map(|_| rng.random_bool(0.5)).collect();
+    let mut pixels = Vec::with_capacity(ICON_SIZE * (1 + ICON_SIZE * 3));
+    for y in 0..ICON_SIZE {
+        // Each row starts with its filter type, 0 for none
+        pixels.push(0);
+        for x in 0..ICON_SIZE {
+            // Cells are 2x2 pixels; the right half mirrors the left
+            let column = x.min(ICON_SIZE - 1 - x) / 2;
+            let on = cells[(y / 2) * (ICON_SIZE / 4) + column];
+            pixels.extend(if on { color } else { [0xf0, 0xf0, 0xf0] });
+        }
+    }
+
+    let mut png = b"\x89PNG\r\n\x1a\n".to_vec();
+    let mut header = Vec::new();
+    header.extend((ICON_SIZE as u32).to_be_bytes());
+    header.extend((ICON_SIZE as u32).to_be_bytes());
+    // 8-bit RGB, default compression, filtering and no interlacing
+    header.extend([8, 2, 0, 0, 0]);
+    png_chunk(&mut png, b"IHDR", &header);
+    png_chunk(&mut png, b"IDAT", &zlib_stored(&pixels));
+    png_chunk(&mut png, b"IEND", &[]);
+    png
+}
+
+fn png_chunk(png: &mut Vec<u8>, kind: &[u8; 4], data: &[u8]) {
+    png.extend((data.len() as u32).to_be_bytes());
+    let start = png.len();
+    png.extend(kind);
+    png.extend(data);
+    let crc = crc32(&png[start..]);
+    png.extend(crc.to_be_bytes());
+}
+
+// A zlib stream of one uncompressed block; `data` is under 64 KiB here
+fn zlib_stored(data: &[u8]) -> Vec<u8> {
+    let mut stream = vec![0x78, 0x01, 0x01];
+    stream.extend((data.len() as u16).to_le_bytes());
+    stream.extend((!(data.len() as u16)).to_le_bytes());
+    stream.extend(data);
+    let (mut a, mut b) = (1u32, 0u32);
+    for &byte in data {
+        a = (a + byte as u32) % 65521;
+        b = (b + a) % 65521;
+    }
+    stream.extend(((b << 16) | a).to_be_bytes());
+    stream
+}
+
+fn crc32(data: &[u8]) -> u32 {
+    let mut crc = !0u32;
+    for &byte in data {
+        crc ^= byte as u32;
+        for _ in 0..8 {
+            crc = if crc & 1 == 1 { (crc >> 1) ^ 0xedb8_8320 } else { crc >> 1 };
+        }
+    }
+    !crc
+}
+
+// Random bytes behind a small header, like a recorded test input
+fn fixture(rng: &mut impl Rng) -> Vec<u8> {
+    let length = rng.random_range(FIXTURE_BYTES);
+    let mut bytes = b"GGFX\x00\x01".to_vec();
+    bytes.extend((length as u16).to_le_bytes());
+    bytes.extend((0..length).map(|_| rng.random::<u8>()));
+    bytes
+}
+
+// "NOTES" and "setup" as "Notes" and "Setup"
+fn title(stem: &str) -> String {
+    let lower = stem.to_lowercase();
+    let mut chars = lower.chars();
+    match chars.next() {
+        Some(first) => first.to_uppercase().chain(chars).collect(),
+        None => lower,
+    }
+}
+
+impl FromStr for ContentKind {
+    type Err = GitHubGridError;
+
+    fn from_str(name: &str) -> Result<Self> {
+        match name.trim().to_ascii_lowercase().as_str() {
+            "go" => Ok(Self::Go),
+            "yaml" | "yml" => Ok(Self::Yaml),
+            "markdown" | "md" => Ok(Self::Markdown),
+            _ => {
+                let known: Vec<&str> = Self::ALL.iter().map(ContentKind::name).collect();
+                Err(GitHubGridError::Config(format!(
+                    "Unknown content '{}' (expected one of {})", name, known.join(", ")
+                )))
+            }
+        }
+    }
+}
+
+impl TryFrom<String> for ContentKind {
+    type Error = GitHubGridError;
+
+    fn try_from(name: String) -> Result<Self> {
+        name.parse()
+    }
+}
+
+// Half the time a function returning a default gets a new value, once there
+// is one; otherwise a new function is added, numbered once every name is taken
+fn change_go(source: &str, rng: &mut impl Rng) -> String {
+    let defaults: Vec<usize> = source.match_indices("\treturn ").map(|(index, _)| index + "\treturn ".len())
+        .filter(|&index| source[index..].starts_with(|c: char| c.is_ascii_digit()))
+        .collect();
+    if let Some(&start) = defaults.choose(rng).filter(|_| rng.random_bool(0.5)) {
+        let end = start + source[start..].find(|c: char| !c.is_ascii_digit()).unwrap_or(source.len() - start);
+        let old: u32 = source[start..end].parse().unwrap_or(0);
+        let new = loop {
+            let new = rng.random_range(1..=100) * 5;
+            if new != old {
+                break new;
+            }
+        };
+        return format!("{}{}{}", &source[..start], new, &source[end..]);
+    }
+
+    let names: Vec<(&str, &str)> = GO_PREFIXES.iter()
+        .flat_map(|&prefix| GO_NOUNS.iter().map(move |&noun| (prefix, noun)))
+        .collect();
+    let free: Vec<(&str, &str)> = names.iter().copied()
+        .filter(|(prefix, noun)| !source.contains(&format!("func {}{}(", prefix, noun)))
+        .collect();
+    let (prefix, noun) = free.choose(rng).or_else(|| names.choose(rng)).copied().unwrap_or(names[0]);
+    let mut name = format!("{}{}", prefix, noun);
+    let mut number = 1;
+    while source.contains(&format!("func {}(", name)) {
+        number += 1;
+        name = format!("{}{}{}", prefix, noun, number);
+    }
+    let function = match prefix {
+        "isValid" => format!(
+            "// {} reports whether n is a usable {} value\nfunc {}(n int) bool {{\n\treturn n > 0 && n <= {}\n}}\n",
+            name, noun.to_lowercase(), name, rng.random_range(1..=64) * 16
+        ),
+        "clamp" => format!(
+            "// {} keeps n between lo and hi\nfunc {}(n, lo, hi int) int {{\n\tif n < lo {{\n\t\treturn lo\n\t}}\n\tif n > hi {{\n\t\treturn hi\n\t}}\n\treturn n\n}}\n",
+            name, name
+        ),
+        "default" => format!(
+            "// {} is used when no {} is configured\nfunc {}() int {{\n\treturn {}\n}}\n",
+            name, noun.to_lowercase(), name, rng.random_range(1..=100) * 5
+        ),
+        "sum" => format!(
+            "// {} adds up {} values\nfunc {}(values []int) int {{\n\ttotal := 0\n\tfor _, v := range values {{\n\t\ttotal += v\n\t}}\n\treturn total\n}}\n",
+            name, noun.to_lowercase(), name
+        ),
+        _ => format!(
+            "// {} reports whether name is one of items\nfunc {}(items []string, name string) bool {{\n\tfor _, item := range items {{\n\t\tif item == name {{\n\t\t\treturn true\n\t\t}}\n\t}}\n\treturn false\n}}\n",
+            name, name
+        ),
+    };
+    format!("{}\n{}", source, function)
+}
+
+fn yaml_value(key: &str, rng: &mut impl Rng) -> String {
+    match key {
+        "timeout" => format!("{}s", rng.random_range(1..=12) * 5),
+        "retries" => rng.random_range(0..=5).to_string(),
+        "workers" => rng.random_range(1..=16).to_string(),
+        "cache_ttl" => format!("{}m", rng.random_range(1..=12) * 5),
+        "log_level" => ["debug", "info", "warn", "error"].choose(rng).copied().unwrap_or("info").to_string(),
+        "max_connections" => (rng.random_range(1..=20) * 10).to_string(),
+        "batch_size" => (1 << rng.random_range(4..=10)).to_string(),
+        "debug" => rng.random_bool(0.5).to_string(),
+        "port" => rng.random_range(8000..=8099).to_string(),
+        _ => ["us-east-1", "us-west-2", "eu-west-1", "eu-central-1", "ap-southeast-1"]
+            .choose(rng).copied().unwrap_or("us-east-1").to_string(),
+    }
+}
+
+// A key set to a new value: changed in place when it's there, else added
+// at the end
+fn change_yaml(source: &str, rng: &mut impl Rng) -> String {
+    let key = YAML_KEYS.choose(rng).copied().unwrap_or(YAML_KEYS[0]);
+    let prefix = format!("{}: ", key);
+    let mut lines: Vec<String> = source.lines().map(str::to_string).collect();
+    match lines.iter().position(|line| line.starts_with(&prefix)) {
+        Some(index) => {
+            let old = lines[index][prefix.len()..].to_string();
+            // Every key has a few values, so a different one comes up soon
+            let value = loop {
+                let value = yaml_value(key, rng);
+                if value != old {
+                    break value;
+                }
+            };
+            lines[index] = format!("{}{}", prefix, value);
+        }
+        None => lines.push(format!("{}{}", prefix, yaml_value(key, rng))),
+    }
+    lines.join("\n") + "\n"
+}
+
+// A bullet at the end of a section, which is started at the end of the file
+// when it isn't there yet
+fn change_markdown(source: &str, rng: &mut impl Rng) -> String {
+    let section = MARKDOWN_SECTIONS.choose(rng).copied().unwrap_or(MARKDOWN_SECTIONS[0]);
+    let mut lines: Vec<String> = source.lines().map(str::to_string).collect();
+    let heading = format!("## {}", section);
+    let start = lines.iter().position(|line| *line == heading);
+    let end = start.map(|start| {
+        lines[start + 1..].iter().position(|line| line.starts_with("## ")).map_or(lines.len(), |offset| start + 1 + offset)
+    });
+
+    // A bullet the section doesn't have yet, while there are any
+    let bullets: Vec<String> = MARKDOWN_VERBS.iter()
+        .flat_map(|verb| MARKDOWN_TOPICS.iter().map(move |topic| format!("- {} {}", verb, topic)))
+        .collect();
+    let section_lines = match (start, end) {
+        (Some(start), Some(end)) => &lines[start..end],
+        _ => &[],
+    };
+    let fresh: Vec<&String> = bullets.iter().filter(|bullet| !section_lines.contains(bullet)).collect();
+    let bullet = fresh.choose(rng).copied().or_else(|| bullets.choose(rng)).cloned().unwrap_or_default();
+
+    match (start, end) {
+        (Some(start), Some(end)) => {
+            // After the section's last non-blank line
+            let at = (start + 1..end).rev().find(|&index| !lines[index].trim().is_empty()).map_or(start + 1, |index| index + 1);
+            if at == start + 1 {
+                lines.insert(at, String::new());
+                lines.insert(at + 1, bullet);
+            } else {
+                lines.insert(at, bullet);
+            }
+        }
+        _ => {
+            lines.extend([String::new(), heading, String::new(), bullet]);
+        }
+    }
+    lines.join("\n") + "\n"
+}
@@ -0,0 +1,463 @@
+use chrono::{DateTime, Local, NaiveDate, Weekday};
+use rand::{Rng, SeedableRng};
+use rand_chacha::ChaCha8Rng;
+use serde::{Deserialize, Serialize};
+use std::io::{BufRead, BufReader, Read, Write};
+use std::net::{SocketAddr, TcpListener, TcpStream};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::{Arc, Mutex};
+use std::thread;
+use std::time::{Duration, Instant};
+use crate::cancel::CancellationToken;
+use crate::clock::{Clock, SystemClock};
+use crate::error::{GitHubGridError, Result};
+use crate::executor::{Executor, RunOutcome, RunReport};
+use crate::git_ops::GitOperations;
+use crate::github::GitHubClient;
+use crate::holidays::HolidayCalendar;
+use crate::issues;
+use crate::lock::RepoLock;
+use crate::messages::MessagePool;
+use crate::metrics::Metrics;
+use crate::output::yellow;
+use crate::patterns::{ActivityCurve, WeekdayRanges};
+use crate::plan::{Plan, PlanOptions, Planner, Strategy};
+use crate::pulls;
+use crate::schedule::Schedule;
+use crate::state::RunState;
+use crate::timezone::{CommitOffset, GraphTimezone, TimezoneSchedule};
+use crate::status;
+
+// How often the sleep between cycles checks for shutdown
+const WAKE_CHECK: Duration = Duration::from_secs(1);
+// Errors kept for /status until a cycle succeeds
+const MAX_PENDING_ERRORS: usize = 10;
+const HTTP_TIMEOUT: Duration = Duration::from_secs(5);
+
+// Address `status` queries when none is given; also the suggested --listen
+pub const DEFAULT_LISTEN: &str = "127.0.0.1:9464";
+
+// What /status reports; `github-grid status` reads it back
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct DaemonStatus {
+    pub started: Option<DateTime<Local>>,
+    pub last_run: Option<DateTime<Local>>,
+    pub last_push: Option<DateTime<Local>>,
+    pub next_run: Option<DateTime<Local>>,
+    // Commits exist locally that are waiting for a push
+    pub pending_push: bool,
+    // Scheduled cycles are skipped until resumed
+    #[serde(default)]
+    pub paused: bool,
+    // Failures since the last successful cycle, oldest first
+    pub errors: Vec<String>,
+}
+
+#[derive(Debug, Clone)]
+pub struct DaemonOptions {
+    // When top-up cycles run: a fixed interval or a cron expression
+    pub schedule: Schedule,
+    // Random extra delay of up to this much before each scheduled cycle, so
+    // sessions don't start on the exact same minute every time
+    pub jitter: Option<Duration>,
+    // Address for the HTTP endpoints (metrics, status, plan and control);
+    // no server when unset
+    pub listen: Option<SocketAddr>,
+    // Chance after each cycle that created commits of also opening and
+    // merging a trivial pull request (0 for never)
+    pub pull_requests: f64,
+    // Same for opening an issue; due ones are closed on every such cycle
+    pub issues: f64,
+    pub top_up: TopUpOptions,
+}
+
+// How top-up plans are generated, by the daemon and by --today runs
+#[derive(Debug, Clone)]
+pub struct TopUpOptions {
+    pub pattern: String,
+    pub work_hours: Option<(u32, u32)>,
+    pub timezones: TimezoneSchedule,
+    pub weekend: Option<Vec<Weekday>>,
+    pub holidays: HolidayCalendar,
+    pub messages: MessagePool,
+    pub weekday_ranges: Option<WeekdayRanges>,
+    pub curve: Option<ActivityCurve>,
+    pub big_days: Option<f64>,
+    pub skip_weekday_chance: Option<f64>,
+    pub skip_weekend_chance: Option<f64>,
+    pub day_correlation: Option<f64>,
+    pub commit_offset: Option<CommitOffset>,
+    pub graph_timezone: Option<GraphTimezone>,
+    pub design_background: Option<f64>,
+    // Kept fixed (for the daemon's lifetime, or in the config) so each day is
+    // planned the same way every time and only the part that has already
+    // passed gets committed
+    pub seed: u64,
+}
+
+// What the control endpoints ask of the run loop
+#[derive(Default)]
+struct Control {
+    paused: AtomicBool,
+    // Run a cycle now, ahead of (and even while paused) the schedule
+    top_up: AtomicBool,
+}
+
+// State the HTTP thread reads and changes
+struct Shared {
+    metrics: Arc<Metrics>,
+    status: Arc<Mutex<DaemonStatus>>,
+    control: Arc<Control>,
+    // Today's whole plan as of the last cycle, done and still due
+    plan: Arc<Mutex<Option<Plan>>>,
+}
+
+// Long-running mode: every interval, commit whatever part of today's (and any
+// missed days') plan is now in the past, push, and export metrics
+pub struct Daemon {
+    git_ops: GitOperations,
+    options: DaemonOptions,
+    metrics: Arc<Metrics>,
+    status: Arc<Mutex<DaemonStatus>>,
+    control: Arc<Control>,
+    plan: Arc<Mutex<Option<Plan>>>,
+    cancel: CancellationToken,
+    clock: Arc<dyn Clock>,
+}
+
+impl Daemon {
+    pub fn new(git_ops: GitOperations, options: DaemonOptions) -> Self {
+        Self {
+            git_ops,
+            options,
+            metrics: Arc::new(Metrics::default()),
+            status: Arc::new(Mutex::new(DaemonStatus::default())),
+            control: Arc::new(Control::default()),
+            plan: Arc::new(Mutex::new(None)),
+            cancel: CancellationToken::new(),
+            clock: Arc::new(SystemClock),
+        }
+    }
+
+    pub fn with_clock(mut self, clock: Arc<dyn Clock>) -> Self {
+        self.clock = clock;
+        self
+    }
+
+    // Stops after the current cycle once cancelled
+    pub fn with_cancellation(mut self, cancel: CancellationToken) -> Self {
+        self.cancel = cancel;
+        self
+    }
+
+    pub fn metrics(&self) -> Arc<Metrics> {
+        Arc::clone(&self.metrics)
+    }
+
+    pub fn run(&mut self) -> Result<()> {
+        self.update_status(|status| status.started = Some(self.clock.now()));
+
+        if let Some(addr) = self.options.listen {
+            let listener = TcpListener::bind(addr)?;
+            let local = listener.local_addr()?;
+            status!("📈 Metrics on http://{}/metrics, status on http://{}/status", local, local);
+            let shared = Shared {
+                metrics: self.metrics(),
+                status: Arc::clone(&self.status),
+                control: Arc::clone(&self.control),
+                plan: Arc::clone(&self.plan),
+            };
+            thread::spawn(move || serve(listener, shared));
+        }
+
+        status!("🕒 Daemon started: topping up {}", self.options.schedule);
+
+        // An interval starts with a cycle right away; a cron schedule waits for
+        // its first slot so sessions only ever happen when the user asked
+        if let Schedule::Cron(_) = self.options.schedule {
+            self.wait_for_next_cycle()?;
+        }
+
+        while !self.cancel.is_cancelled() {
+            let requested = self.control.top_up.swap(false, Ordering::SeqCst);
+            if self.control.paused.load(Ordering::SeqCst) && !requested {
+                status!("⏸️  Paused, skipping this cycle");
+                self.wait_for_next_cycle()?;
+                continue;
+            }
+
+            let result = self.top_up();
+            let now = self.clock.now();
+            match result {
+                Ok(report) => {
+                    self.metrics.record_run(&report);
+                    self.update_status(|status| {
+                        status.last_run = Some(now);
+                        if report.pushes > 0 {
+                            status.last_push = Some(now);
+                        }
+                        status.pending_push = report.pending_push;
+                        status.errors.clear();
+                    });
+                    if report.created > 0 {
+                        status!("➕ Created {} commits", report.created);
+                    }
+                    // Other contribution types only follow a session that
+                    // created and pushed everything
+                    // Drawn from the seed and the session's time, so --seed
+                    // reproduces them
+                    let clean = report.created > 0 && report.outcome == RunOutcome::Complete && !report.pending_push;
+                    let mut rng = ChaCha8Rng::seed_from_u64(self.options.top_up.seed ^ pulls::ROLL_SALT ^ now.timestamp() as u64);
+                    if clean && rng.random_bool(self.options.pull_requests) {
+                        if let Err(e) = self.open_pull_request(&mut rng) {
+                            println!("{}", yellow(format!("⚠️  Pull request failed: {}", e)));
+                        }
+                    }
+                    if clean && self.options.issues > 0.0 {
+                        if let Err(e) = self.open_and_close_issues(&mut rng) {
+                            println!("{}", yellow(format!("⚠️  Issues failed: {}", e)));
+                        }
+                    }
+                    if report.outcome == RunOutcome::Cancelled {
+                        break;
+                    }
+                }
+                Err(GitHubGridError::Cancelled) => break,
+                Err(e) => {
+                    self.metrics.record_failure();
+                    self.update_status(|status| {
+                        status.last_run = Some(now);
+                        status.errors.push(format!("{}: {}", now.format("%Y-%m-%d %H:%M:%S"), e));
+                        if status.errors.len() > MAX_PENDING_ERRORS {
+                            status.errors.remove(0);
+                        }
+                    });
+                    println!("{}", yellow(format!("⚠️  Top-up failed, retrying next cycle: {}", e)));
+                }
+            }
+
+            if let Err(e) = self.update_days_behind() {
+                println!("{}", yellow(format!("⚠️  Could not read repository history: {}", e)));
+            }
+            self.wait_for_next_cycle()?;
+        }
+
+        status!("🛑 Daemon stopped");
+        Ok(())
+    }
+
+    // One cycle: commit and push whatever is due by now
+    fn top_up(&mut self) -> Result<RunReport> {
+        let _lock = RepoLock::acquire(self.git_ops.repo())?;
+
+        let now = self.clock.now();
+        let today = Planner::new(top_up_options(&self.options.top_up, now.date_naive(), now.date_naive())).build()?;
+        if let Ok(mut plan) = self.plan.lock() {
+            *plan = Some(today);
+        }
+        let plan = plan_top_up(&mut self.git_ops, &self.options.top_up, now)?;
+
+        // Nothing due and nothing queued: skip the executor and its output
+        if plan.is_empty() && !RunState::load(self.git_ops.repo())?.pending_push {
+            return Ok(RunReport {
+                outcome: RunOutcome::Complete,
+                created: 0,
+                pending_push: false,
+                pushes: 0,
+                push_retries: 0,
+            });
+        }
+
+        Executor::new(&mut self.git_ops)
+            .with_clock(Arc::clone(&self.clock))
+            .with_cancellation(self.cancel.clone())
+            .execute(&plan)
+    }
+
+    fn open_pull_request(&mut self, rng: &mut impl Rng) -> Result<u64> {
+        let _lock = RepoLock::acquire(self.git_ops.repo())?;
+        let github = GitHubClient::new()?;
+        pulls::open_and_merge(&mut self.git_ops, &github, rng)
+    }
+
+    fn open_and_close_issues(&mut self, rng: &mut impl Rng) -> Result<(usize, bool)> {
+        let _lock = RepoLock::acquire(self.git_ops.repo())?;
+        let github = GitHubClient::new()?;
+        issues::open_and_close(&mut self.git_ops, &github, self.options.issues, rng)
+    }
+
+    fn update_days_behind(&mut self) -> Result<()> {
+        let today = self.clock.today();
+        let days = match self.git_ops.get_latest_autogen_commit()? {
+            Some(latest) => (today - latest.date_naive()).num_days().max(0),
+            None => 0,
+        };
+        self.metrics.set_days_behind(days);
+        Ok(())
+    }
+
+    fn update_status(&self, update: impl FnOnce(&mut DaemonStatus)) {
+        if let Ok(mut status) = self.status.lock() {
+            update(&mut status);
+        }
+    }
+
+    fn wait_for_next_cycle(&self) -> Result<()> {
+        let now = self.clock.now();
+        let next = self.options.schedule.next_after(now).ok_or_else(|| {
+            GitHubGridError::Config(format!("Schedule {} never fires again", self.options.schedule))
+        })?;
+        let jitter = self.options.jitter
+            .filter(|jitter| !jitter.is_zero())
+            .map(|jitter| Duration::from_millis(rand::random_range(0..=jitter.as_millis() as u64)))
+            .unwrap_or_default();
+        let next = next + chrono::Duration::from_std(jitter).unwrap_or_default();
+
+        self.update_status(|status| status.next_run = Some(next));
+        status!("💤 Next cycle at {}", next.format("%Y-%m-%d %H:%M:%S"));
+        self.sleep((next - now).to_std().unwrap_or_default());
+        Ok(())
+    }
+
+    // Ends early on shutdown or a requested top-up
+    fn sleep(&self, duration: Duration) {
+        let until = Instant::now() + duration;
+        while !self.cancel.is_cancelled() && !self.control.top_up.load(Ordering::SeqCst) {
+            let now = Instant::now();
+            if now >= until {
+                break;
+            }
+            thread::sleep(WAKE_CHECK.min(until - now));
+        }
+    }
+}
+
+// Plan the commits that fall after the newest generated commit and no later
+// than `now`: the part of today (and any missed days) that has already passed.
+// Planning with the same seed each time keeps a day's schedule stable
+pub fn plan_top_up(git_ops: &mut GitOperations, options: &TopUpOptions, now: DateTime<Local>) -> Result<Plan> {
+    let today = now.date_naive();
+    let latest = git_ops.get_latest_autogen_commit()?;
+    let start = latest.map(|latest| latest.date_naive()).unwrap_or(today);
+
+    let mut plan = Planner::new(top_up_options(options, start, today)).build()?;
+    plan.commits.retain(|commit| {
+        commit.date <= now && latest.is_none_or(|latest| commit.date > latest)
+    });
+    Ok(plan)
+}
+
+fn top_up_options(options: &TopUpOptions, start: NaiveDate, end: NaiveDate) -> PlanOptions {
+    PlanOptions {
+        seed: Some(options.seed),
+        work_hours: options.work_hours,
+        timezones: options.timezones.clone(),
+        weekend: options.weekend.clone(),
+        holidays: Some(options.holidays.clone()),
+        messages: Some(options.messages.clone()),
+        weekday_ranges: options.weekday_ranges.clone(),
+        curve: options.curve,
+        big_days: options.big_days,
+        skip_weekday_chance: options.skip_weekday_chance,
+        skip_weekend_chance: options.skip_weekend_chance,
+        day_correlation: options.day_correlation,
+        commit_offset: options.commit_offset,
+        graph_timezone: options.graph_timezone,
+        design_background: options.design_background,
+        ..PlanOptions::new(start, end, Strategy::Pattern(options.pattern.clone()))
+    }
+}
+
+// Ask a running daemon for its status over HTTP
+pub fn fetch_status(addr: SocketAddr) -> Result<DaemonStatus> {
+    let body = request(addr, "GET", "/status")?;
+    serde_json::from_str(&body).map_err(|e| GitHubGridError::Parse(format!("Invalid status from {}: {}", addr, e)))
+}
+
+// Today's plan as the daemon last made it
+pub fn fetch_plan(addr: SocketAddr) -> Result<Plan> {
+    let body = request(addr, "GET", "/plan")?;
+    serde_json::from_str(&body).map_err(|e| GitHubGridError::Parse(format!("Invalid plan from {}: {}", addr, e)))
+}
+
+// Send a control request: "top-up", "pause" or "resume"
+pub fn control(addr: SocketAddr, action: &str) -> Result<()> {
+    request(addr, "POST", &format!("/{}", action)).map(|_| ())
+}
+
+// One HTTP request to a running daemon; the body of a 2xx response
+fn request(addr: SocketAddr, method: &str, path: &str) -> Result<String> {
+    let mut stream = TcpStream::connect_timeout(&addr, HTTP_TIMEOUT).map_err(|e| {
+        GitHubGridError::Network(format!("No daemon listening on {}: {}", addr, e))
+    })?;
+    stream.set_read_timeout(Some(HTTP_TIMEOUT))?;
+    write!(stream, "{} {} HTTP/1.1\r\nHost: {}\r\nContent-Length: 0\r\nConnection: close\r\n\r\n", method, path, addr)?;
+
+    let mut response = String::new();
+    stream.read_to_string(&mut response)?;
+    let (head, body) = response.split_once("\r\n\r\n").unwrap_or((response.as_str(), ""));
+    if !head.starts_with("HTTP/1.1 2") {
+        return Err(GitHubGridError::Network(format!(
+            "Unexpected response from {}: {}", addr, head.lines().next().unwrap_or("")
+        )));
+    }
+    Ok(body.to_string())
+}
+
+fn serve(listener: TcpListener, shared: Shared) {
+    for stream in listener.incoming().flatten() {
+        // A misbehaving client only loses its own response
+        let _ = respond(stream, &shared);
+    }
+}
+
+fn respond(mut stream: TcpStream, shared: &Shared) -> std::io::Result<()> {
+    stream.set_read_timeout(Some(HTTP_TIMEOUT))?;
+
+    let mut reader = BufReader::new(&stream);
+    let mut request_line = String::new();
+    reader.read_line(&mut request_line)?;
+    // Drain the headers so the client sees a clean close
+    let mut header = String::new();
+    while reader.read_line(&mut header)? > 0 && !header.trim_end().is_empty() {
+        header.clear();
+    }
+
+    let mut parts = request_line.split_whitespace();
+    let (method, path) = (parts.next().unwrap_or(""), parts.next().unwrap_or(""));
+    let (status, content_type, body) = match (method, path) {
+        ("GET", "/metrics") => ("200 OK", "text/plain; version=0.0.4", shared.metrics.render()),
+        ("GET", "/status") => {
+            let mut snapshot = shared.status.lock().map(|status| status.clone()).unwrap_or_default();
+            snapshot.paused = shared.control.paused.load(Ordering::SeqCst);
+            ("200 OK", "application/json", serde_json::to_string_pretty(&snapshot).unwrap_or_default())
+        }
+        ("GET", "/plan") => match shared.plan.lock().ok().and_then(|plan| plan.clone()) {
+            Some(plan) => ("200 OK", "application/json", serde_json::to_string_pretty(&plan).unwrap_or_default()),
+            None => ("404 Not Found", "text/plain", "no cycle has run yet\n".to_string()),
+        },
+        ("POST", "/top-up") => {
+            shared.control.top_up.store(true, Ordering::SeqCst);
+            ("202 Accepted", "text/plain", "top-up requested\n".to_string())
+        }
+        ("POST", "/pause") => {
+            shared.control.paused.store(true, Ordering::SeqCst);
+            ("200 OK", "text/plain", "paused\n".to_string())
+        }
+        ("POST", "/resume") => {
+            shared.control.paused.store(false, Ordering::SeqCst);
+            ("200 OK", "text/plain", "resumed\n".to_string())
+        }
+        ("GET", _) | ("POST", _) => ("404 Not Found", "text/plain", "not found\n".to_string()),
+        _ => ("405 Method Not Allowed", "text/plain", "method not allowed\n".to_string()),
+    };
+
+    write!(
+        stream,
+        "HTTP/1.1 {}\r\nContent-Type: {}\r\nContent-Length: {}\r\nConnection: close\r\n\r\n{}",
+        status,
+        content_type,
+        body.len(),
+        body
+    )
+}
@@ -0,0 +1,191 @@
+use std::ops::Range;
+use crate::error::{GitHubGridError, Result};
+use crate::grid::{Grid, ROWS};
+
+// Patterns drawn onto the contribution graph instead of simulating a
+// developer. Every day in the range is a cell; a design gives each cell a
+// level from 0 (left empty) to 1 (darkest)
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum Design {
+    // Light to dark from the first column to the last
+    Gradient,
+    // Columns rising and falling in bands, a few times across the range
+    Wave,
+    Checkerboard,
+    // Diagonal bands running up to the right
+    Stripes,
+    // A row of hearts
+    Hearts,
+    // Letters and digits centered on the graph, one bit per row per column
+    Initials { text: String, columns: Vec<u8> },
+}
+
+// Weeks from one crest of the wave to the next
+const WAVE_PERIOD: f64 = 13.0;
+
+// Stripe width along a row, and the gap before the next one
+const STRIPE_WIDTH: u32 = 3;
+
+// Lightest level a design uses for a cell it fills, so the light end of a
+// gradient still shows up
+const MIN_LEVEL: f64 = 0.1;
+
+const HEART: [&str; 7] = [
+    ".##.##.",
+    "#######",
+    "#######",
+    "#######",
+    ".#####.",
+    "..###..",
+    "...#...",
+];
+
+// Columns between two hearts
+const HEART_GAP: u32 = 2;
+
+// 5x7 glyphs for initials
+const FONT: &[(char, [&str; 7])] = &[
+    ('A', [".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"]),
+    ('B', ["####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."]),
+    ('C', [".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."]),
+    ('D', ["####.", "#...#", "#...#", "#...#", "#...#", "#...#", "####."]),
+    ('E', ["#####", "#....", "#....", "####.", "#....", "#....", "#####"]),
+    ('F', ["#####", "#....", "#....", "####.", "#....", "#....", "#...."]),
+    ('G', [".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".###."]),
+    ('H', ["#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"]),
+    ('I', [".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."]),
+    ('J', ["..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."]),
+    ('K', ["#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"]),
+    ('L', ["#....", "#....", "#....", "#....", "#....", "#....", "#####"]),
+    ('M', ["#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"]),
+    ('N', ["#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"]),
+    ('O', [".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."]),
+    ('P', ["####.", "#...#", "#...#", "####.", "#....", "#....", "#...."]),
+    ('Q', [".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"]),
+    ('R', ["####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"]),
+    ('S', [".####", "#....", "#....", ".###.", "....#", "....#", "####."]),
+    ('T', ["#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."]),
+    ('U', ["#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."]),
+    ('V', ["#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."]),
+    ('W', ["#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."]),
+    ('X', ["#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"]),
+    ('Y', ["#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."]),
+    ('Z', ["#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"]),
+    ('0', [".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."]),
+    ('1', ["..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."]),
+    ('2', [".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"]),
+    ('3', ["#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."]),
+    ('4', ["...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."]),
+    ('5', ["#####", "#....", "####.", "....#", "....#", "#...#", ".###."]),
+    ('6', ["..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."]),
+    ('7', ["#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."]),
+    ('8', [".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."]),
+    ('9', [".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."]),
+];
+
+// Empty columns for a space in initials
+const SPACE_WIDTH: usize = 3;
+
+impl Design {
+    // Design behind a pattern name; "initials:JD" draws the given letters
+    pub fn named(name: &str) -> Result<Option<Self>> {
+        let design = match name {
+            "gradient" => Self::Gradient,
+            "wave" => Self::Wave,
+            "checkerboard" => Self::Checkerboard,
+            "stripes" => Self::Stripes,
+            "heart" | "hearts" => Self::Hearts,
+            _ => match name.strip_prefix("initials:") {
+                Some(text) => Self::initials(text)?,
+                None => return Ok(None),
+            },
+        };
+        Ok(Some(design))
+    }
+
+    fn initials(text: &str) -> Result<Self> {
+        let text = text.trim().to_ascii_uppercase();
+        if text.is_empty() {
+            return Err(GitHubGridError::Config("No letters given for initials (e.g. initials:JD)".to_string()));
+        }
+
+        let mut columns = Vec::new();
+        for (index, c) in text.chars().enumerate() {
+            if index > 0 {
+                columns.push(0);
+            }
+            if c == ' ' {
+                columns.extend([0; SPACE_WIDTH]);
+                continue;
+            }
+            let (_, glyph) = FONT.iter().find(|(glyph_char, _)| *glyph_char == c).ok_or_else(|| {
+                GitHubGridError::Config(format!("Initials can only use letters, digits and spaces, not '{}'", c))
+            })?;
+            for x in 0..5 {
+                let bits = glyph.iter().enumerate()
+                    .filter(|(_, row)| row.as_bytes()[x] == b'#')
+                    .fold(0u8, |bits, (y, _)| bits | 1 << y);
+                columns.push(bits);
+            }
+        }
+        Ok(Self::Initials { text, columns })
+    }
+
+    // Columns a fixed-size design takes up, centered on the graph's full
+    // columns; past the last one when it doesn't fit
+    pub fn span(&self, grid: &Grid) -> Option<Range<u32>> {
+        let full = grid.full_columns();
+        let width = match self {
+            Self::Initials { columns, .. } => columns.len() as u32,
+            Self::Hearts => Self::hearts_width(full.len() as u32),
+            _ => return None,
+        };
+        let margin = (full.len() as u32).saturating_sub(width) / 2;
+        Some(full.start + margin..full.start + margin + width)
+    }
+
+    // As many whole hearts as fit, with gaps between them
+    fn hearts_width(columns: u32) -> u32 {
+        let period = HEART[0].len() as u32 + HEART_GAP;
+        let count = ((columns + HEART_GAP) / period).max(1);
+        count * period - HEART_GAP
+    }
+
+    // Level of the cell at `column`/`row` (0 is Sunday) on `grid`
+    pub fn level(&self, column: u32, row: u32, grid: &Grid) -> f64 {
+        if row >= ROWS {
+            return 0.0;
+        }
+        let filled = |on: bool| if on { 1.0 } else { 0.0 };
+        match self {
+            Self::Gradient => {
+                let position = match grid.columns {
+                    0 | 1 => 1.0,
+                    columns => column as f64 / (columns - 1) as f64,
+                };
+                MIN_LEVEL + (1.0 - MIN_LEVEL) * position
+            }
+            Self::Wave => {
+                let phase = column as f64 / WAVE_PERIOD * std::f64::consts::TAU;
+                MIN_LEVEL + (1.0 - MIN_LEVEL) * (1.0 - phase.cos()) / 2.0
+            }
+            Self::Checkerboard => filled((column + row) % 2 == 0),
+            // Row 0 is the top, so adding the row slants the bands upwards
+            Self::Stripes => filled((column + row) % (STRIPE_WIDTH * 2) < STRIPE_WIDTH),
+            Self::Hearts => {
+                let span = self.span(grid).unwrap_or_default();
+                let period = HEART[0].len() as u32 + HEART_GAP;
+                let on = span.contains(&column)
+                    && (column - span.start) % period < HEART[0].len() as u32
+                    && HEART[row as usize].as_bytes()[((column - span.start) % period) as usize] == b'#';
+                filled(on)
+            }
+            Self::Initials { columns: glyphs, .. } => {
+                let span = self.span(grid).unwrap_or_default();
+                let on = span.contains(&column)
+                    && glyphs.get((column - span.start) as usize).is_some_and(|bits| bits & (1 << row) != 0);
+                filled(on)
+            }
+        }
+    }
+}
@@ -0,0 +1,166 @@
+use chrono::{Datelike, NaiveDate};
+use ratatui::crossterm::event::{self, Event, KeyCode, KeyEventKind};
+use ratatui::style::{Color, Style};
+use ratatui::text::{Line, Span};
+use ratatui::widgets::{Block, Paragraph};
+use ratatui::{DefaultTerminal, Frame};
+use std::collections::BTreeMap;
+use crate::error::Result;
+use crate::grid::Grid;
+use crate::messages::{AUTOGEN_MARKER, MessagePool};
+use crate::plan::Plan;
+
+// GitHub's dark theme, from no contributions to the most
+const LEVELS: [Color; 5] = [
+    Color::Rgb(22, 27, 34),
+    Color::Rgb(14, 68, 41),
+    Color::Rgb(0, 109, 50),
+    Color::Rgb(38, 166, 65),
+    Color::Rgb(57, 211, 83),
+];
+const LABELS: [&str; 7] = ["Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"];
+// Day label and border
+const MARGIN: u16 = 6;
+
+// Open the plan as a grid in the terminal to bump days up or down before it
+// is applied. Returns whether the edits were kept; the plan is only changed
+// when they are
+pub fn edit(plan: &mut Plan) -> Result<bool> {
+    let mut editor = Editor::new(plan.clone());
+    let mut terminal = ratatui::try_init()?;
+    let saved = editor.run(&mut terminal);
+    ratatui::restore();
+
+    if saved? && editor.changed {
+        *plan = editor.plan;
+        return Ok(true);
+    }
+    Ok(false)
+}
+
+struct Editor {
+    plan: Plan,
+    grid: Grid,
+    counts: BTreeMap<NaiveDate, usize>,
+    // Subjects for added commits, drawn from the ones already planned
+    messages: MessagePool,
+    cursor: NaiveDate,
+    // First column on screen, for plans wider than the terminal
+    scroll: u32,
+    changed: bool,
+}
+
+impl Editor {
+    fn new(plan: Plan) -> Self {
+        let mut subjects: BTreeMap<String, u32> = BTreeMap::new();
+        for commit in &plan.commits {
+            let subject = commit.message.strip_prefix(AUTOGEN_MARKER).unwrap_or(&commit.message);
+            *subjects.entry(subject.to_string()).or_insert(0) += 1;
+        }
+        let messages = MessagePool::new(subjects.into_iter().collect()).unwrap_or_default();
+
+        Self {
+            grid: Grid::new(plan.start, plan.end),
+            counts: plan.per_day(),
+            cursor: plan.start,
+            plan,
+            messages,
+            scroll: 0,
+            changed: false,
+        }
+    }
+
+    // Handle keys until the plan is saved (true) or the edit cancelled (false)
+    fn run(&mut self, terminal: &mut DefaultTerminal) -> Result<bool> {
+        loop {
+            terminal.draw(|frame| self.draw(frame))?;
+            let Event::Key(key) = event::read()? else { continue };
+            if key.kind != KeyEventKind::Press {
+                continue;
+            }
+
+            let count = self.count(self.cursor);
+            match key.code {
+                KeyCode::Enter | KeyCode::Char('s') => return Ok(true),
+                KeyCode::Esc | KeyCode::Char('q') => return Ok(false),
+                KeyCode::Left | KeyCode::Char('h') => self.move_cursor(-7),
+                KeyCode::Right | KeyCode::Char('l') => self.move_cursor(7),
+                KeyCode::Up | KeyCode::Char('k') => self.move_cursor(-1),
+                KeyCode::Down | KeyCode::Char('j') => self.move_cursor(1),
+                KeyCode::PageUp => self.move_cursor(-7 * 52),
+                KeyCode::PageDown => self.move_cursor(7 * 52),
+                KeyCode::Char('+') | KeyCode::Char('=') => self.set(count + 1),
+                KeyCode::Char('-') => self.set(count.saturating_sub(1)),
+                KeyCode::Char('0') | KeyCode::Delete => self.set(0),
+                _ => {}
+            }
+        }
+    }
+
+    fn count(&self, day: NaiveDate) -> usize {
+        self.counts.get(&day).copied().unwrap_or(0)
+    }
+
+    fn move_cursor(&mut self, days: i64) {
+        let day = self.cursor + chrono::Duration::days(days);
+        self.cursor = day.clamp(self.plan.start, self.plan.end);
+    }
+
+    fn set(&mut self, count: usize) {
+        if count == self.count(self.cursor) {
+            return;
+        }
+        self.plan.set_day(self.cursor, count, &self.messages);
+        self.counts = self.plan.per_day();
+        self.changed = true;
+    }
+
+    fn draw(&mut self, frame: &mut Frame) {
+        let area = frame.area();
+        let visible = (area.width.saturating_sub(MARGIN) / 2).max(1) as u32;
+        let (column, _) = self.grid.cell(self.cursor).unwrap_or((0, 0));
+        if column < self.scroll {
+            self.scroll = column;
+        } else if column >= self.scroll + visible {
+            self.scroll = column + 1 - visible;
+        }
+        let columns = self.scroll..(self.scroll + visible).min(self.grid.columns);
+
+        let busiest = self.counts.values().copied().max().unwrap_or(0).max(1);
+        let mut lines = Vec::new();
+        for (row, label) in LABELS.iter().enumerate() {
+            let mut spans = vec![Span::raw(format!("{} ", label))];
+            for column in columns.clone() {
+                let span = match self.grid.date(column, row as u32) {
+                    Some(day) => {
+                        let level = (self.count(day) * 4).div_ceil(busiest);
+                        let mut style = Style::new().fg(LEVELS[level]);
+                        if day == self.cursor {
+                            style = style.bg(Color::White);
+                        }
+                        Span::styled("■", style)
+                    }
+                    None => Span::raw(" "),
+                };
+                spans.push(span);
+                spans.push(Span::raw(" "));
+            }
+            lines.push(Line::from(spans));
+        }
+
+        let total: usize = self.counts.values().sum();
+        lines.push(Line::from(""));
+        lines.push(Line::from(format!(
+            "{} {}: {} commits    Plan: {} commits on {} days{}",
+            self.cursor, self.cursor.weekday(), self.count(self.cursor),
+            total, self.counts.len(),
+            if self.changed { " (edited)" } else { "" }
+        )));
+        lines.push(Line::from(
+            "←→ week  ↑↓ day  PgUp/PgDn year  + / - add or remove a commit  0 clear day  Enter save  q cancel"
+        ));
+
+        let title = format!(" Plan {} to {} ", self.plan.start, self.plan.end);
+        frame.render_widget(Paragraph::new(lines).block(Block::bordered().title(title)), area);
+    }
+}
@@ -8,6 +8,9 @@ pub enum GitHubGridError {
     Config(String),
     Authentication(String),
     Repository(String),
+    Network(String),
+    Timeout(String),
+    Cancelled,
 }
 
 impl fmt::Display for GitHubGridError {
@@ -19,6 +22,9 @@ impl fmt::Display for GitHubGridError {
             GitHubGridError::Config(msg) => write!(f, "Configuration error: {}", msg),
             GitHubGridError::Authentication(msg) => write!(f, "Authentication error: {}", msg),
             GitHubGridError::Repository(msg) => write!(f, "Repository error: {}", msg),
+            GitHubGridError::Network(msg) => write!(f, "Network error: {}", msg),
+            GitHubGridError::Timeout(msg) => write!(f, "Timeout: {}", msg),
+            GitHubGridError::Cancelled => write!(f, "Cancelled by user"),
         }
     }
 }
@@ -37,6 +43,17 @@ impl From<std::io::Error> for GitHubGridError {
     }
 }
 
+impl From<toml::de::Error> for GitHubGridError {
+    fn from(err: toml::de::Error) -> Self {
+        GitHubGridError::Parse(err.to_string())
+    }
+}
+
+impl From<toml::ser::Error> for GitHubGridError {
+    fn from(err: toml::ser::Error) -> Self {
+        GitHubGridError::Config(err.to_string())
+    }
+}
 
 impl From<chrono::ParseError> for GitHubGridError {
     fn from(err: chrono::ParseError) -> Self {
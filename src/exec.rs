@@ -0,0 +1,224 @@
+use std::env;
+use std::io::Read;
+use std::process::{Command, Output, Stdio};
+use std::thread;
+use std::time::{Duration, Instant};
+use crate::cancel::CancellationToken;
+use crate::error::{GitHubGridError, Result};
+use crate::{trace, verbose};
+
+const POLL_INTERVAL: Duration = Duration::from_millis(50);
+
+// Run a command to completion, killing it once the timeout elapses so a hung
+// credential prompt or stalled connection can't freeze the whole run. The
+// child is also killed as soon as the cancellation token fires
+pub fn run_command(
+    mut cmd: Command,
+    timeout: Option<Duration>,
+    cancel: &CancellationToken,
+) -> Result<Output> {
+    cmd.stdin(Stdio::null())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped());
+
+    verbose!("$ {}", describe(&cmd));
+    trace_environment(&cmd);
+    
+    let mut child = cmd.spawn()?;
+
+    // Drain both pipes on background threads so a chatty child never blocks
+    // on a full pipe while we are waiting for it
+    let stdout_reader = spawn_reader(child.stdout.take());
+    let stderr_reader = spawn_reader(child.stderr.take());
+
+    let deadline = timeout.map(|timeout| Instant::now() + timeout);
+    let status = loop {
+        if let Some(status) = child.try_wait()? {
+            break status;
+        }
+
+        if cancel.is_cancelled() {
+            let _ = child.kill();
+            let _ = child.wait();
+            return Err(GitHubGridError::Cancelled);
+        }
+
+        if deadline.is_some_and(|deadline| Instant::now() >= deadline) {
+            let _ = child.kill();
+            let _ = child.wait();
+            return Err(GitHubGridError::Timeout(format!(
+                "'{}' did not finish within {}s",
+                describe(&cmd),
+                timeout.unwrap_or_default().as_secs()
+            )));
+        }
+
+        thread::sleep(POLL_INTERVAL);
+    };
+
+    let output = Output {
+        status,
+        stdout: stdout_reader.join().unwrap_or_default(),
+        stderr: stderr_reader.join().unwrap_or_default(),
+    };
+    trace!("  exit: {}", output.status);
+    for (name, stream) in [("stdout", &output.stdout), ("stderr", &output.stderr)] {
+        let text = String::from_utf8_lossy(stream);
+        for line in text.lines() {
+            trace!("  {}: {}", name, line);
+        }
+    }
+    Ok(output)
+}
+
+// Variables that pick the repository git works on. Every caller names its
+// repository itself, and a GIT_DIR left in the environment (a bare dotfiles
+// repository, say) would quietly win over that
+const REPOSITORY_VARS: &[&str] = &["GIT_DIR", "GIT_WORK_TREE", "GIT_INDEX_FILE", "GIT_COMMON_DIR"];
+
+// A git command that fails instead of waiting for a password, passphrase or
+// host key confirmation nobody is there to give: no terminal prompt, no
+// credential manager dialog, and ssh asks a program that always declines
+// (OpenSSH 8.4+) unless the user set up an askpass of their own. It doesn't
+// inherit REPOSITORY_VARS
+pub fn git_command() -> Command {
+    let mut cmd = Command::new("git");
+    for var in REPOSITORY_VARS {
+        cmd.env_remove(var);
+    }
+    cmd.env("GIT_TERMINAL_PROMPT", "0")
+        .env("GCM_INTERACTIVE", "never");
+    if env::var_os("SSH_ASKPASS").is_none() {
+        cmd.env("SSH_ASKPASS", "false")
+            .env("SSH_ASKPASS_REQUIRE", "force");
+    }
+    cmd
+}
+
+// The error for a git command that failed because it needed credentials it
+// wasn't allowed to prompt for, or because origin turned the ones it had
+// down, with what to set up instead
+pub fn credentials_error(stderr: &str) -> Option<GitHubGridError> {
+    const PROMPT_FAILURES: &[&str] = &[
+        "terminal prompts disabled",
+        "could not read Username",
+        "could not read Password",
+        "Permission denied (publickey",
+        "Host key verification failed",
+        "read_passphrase",
+    ];
+
+    const REJECTED: &[&str] = &[
+        "Authentication failed",
+        "Invalid username or password",
+        "returned error: 401",
+        "returned error: 403",
+    ];
+
+    if let Some(line) = stderr.lines().find(|line| REJECTED.iter().any(|failure| line.contains(failure))) {
+        return Some(GitHubGridError::Authentication(format!(
+            "origin rejected the credentials ({}). For HTTPS run `gh auth setup-git` or update the token \
+             your credential helper stores; for SSH check `ssh -T git@github.com`",
+            line.trim()
+        )));
+    }
+    let line = stderr.lines().find(|line| PROMPT_FAILURES.iter().any(|failure| line.contains(failure)))?;
+    Some(GitHubGridError::Authentication(format!(
+        "credentials are not configured for non-interactive use ({}). For HTTPS run `gh auth setup-git` \
+         or configure a credential helper; for SSH load your key into ssh-agent with `ssh-add` and accept \
+         GitHub's host key once with `ssh -T git@github.com`",
+        line.trim()
+    )))
+}
+
+// Working directory, explicit overrides and inherited GIT_* variables, which
+// decide which repository and credentials git actually uses
+fn trace_environment(cmd: &Command) {
+    if let Some(dir) = cmd.get_current_dir() {
+        trace!("  cwd: {}", dir.display());
+    }
+    for (key, value) in cmd.get_envs() {
+        trace!("  env: {}={}", key.to_string_lossy(),
+            value.map(|value| value.to_string_lossy().to_string()).unwrap_or_else(|| "<unset>".to_string()));
+    }
+    for (key, value) in env::vars().filter(|(key, _)| key.starts_with("GIT_")) {
+        trace!("  env: {}={}", key, value);
+    }
+}
+
+fn spawn_reader<R: Read + Send + 'static>(pipe: Option<R>) -> thread::JoinHandle<Vec<u8>> {
+    thread::spawn(move || {
+        let mut buf = Vec::new();
+        if let Some(mut pipe) = pipe {
+            let _ = pipe.read_to_end(&mut buf);
+        }
+        buf
+    })
+}
+
+fn describe(cmd: &Command) -> String {
+    let mut parts = vec![cmd.get_program().to_string_lossy().to_string()];
+    parts.extend(cmd.get_args().map(|arg| arg.to_string_lossy().to_string()));
+    parts.join(" ")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::{Path, PathBuf};
+
+    // Set for the copy of the test binary git_command_ignores_inherited_git_dir
+    // runs with GIT_DIR in its environment: the repository git should find
+    const INTENDED_REPO_VAR: &str = "GITHUB_GRID_TEST_INTENDED_REPO";
+
+    fn init_repo(dir: &Path) {
+        let status = git_command().arg("init").arg("-q").arg(dir).status().unwrap();
+        assert!(status.success());
+    }
+
+    #[test]
+    fn git_command_removes_repository_vars() {
+        let cmd = git_command();
+        for var in REPOSITORY_VARS {
+            assert!(
+                cmd.get_envs().any(|(key, value)| key == *var && value.is_none()),
+                "{} is inherited",
+                var
+            );
+        }
+    }
+
+    #[test]
+    fn git_command_ignores_inherited_git_dir() {
+        // In the child: GIT_DIR names the other repository
+        if let Some(intended) = env::var_os(INTENDED_REPO_VAR) {
+            let intended = PathBuf::from(intended);
+            let output = git_command()
+                .arg("-C")
+                .arg(&intended)
+                .args(["rev-parse", "--absolute-git-dir"])
+                .output()
+                .unwrap();
+            assert!(output.status.success(), "{}", String::from_utf8_lossy(&output.stderr));
+            let found = PathBuf::from(String::from_utf8_lossy(&output.stdout).trim());
+            assert_eq!(found, intended.join(".git").canonicalize().unwrap());
+            return;
+        }
+        
+        // The child gets GIT_DIR from its spawn, so this process's environment,
+        // shared with every other test thread, is never changed
+        let base = env::temp_dir().join(format!("github-grid-exec-{}", std::process::id()));
+        let (intended, other) = (base.join("intended"), base.join("other"));
+        init_repo(&intended);
+        init_repo(&other);
+        let output = Command::new(env::current_exe().unwrap())
+            .args(["--exact", "exec::tests::git_command_ignores_inherited_git_dir", "--nocapture"])
+            .env("GIT_DIR", other.join(".git"))
+            .env(INTENDED_REPO_VAR, &intended)
+            .output()
+            .unwrap();
+        let _ = std::fs::remove_dir_all(&base);
+        assert!(output.status.success(), "{}", String::from_utf8_lossy(&output.stdout));
+        assert!(String::from_utf8_lossy(&output.stdout).contains("1 passed"));
+    }
+}
@@ -0,0 +1,371 @@
+use chrono::{DateTime, FixedOffset, NaiveDate};
+use indicatif::ProgressBar;
+use std::sync::Arc;
+use std::time::Instant;
+use crate::cancel::CancellationToken;
+use crate::clock::{Clock, SystemClock};
+use crate::error::{GitHubGridError, Result};
+use crate::git_ops::{GitOperations, PUSH_BATCH_SIZE};
+use crate::output::progress_bar;
+use crate::status;
+use crate::plan::{Plan, PlanOutline};
+use crate::state::{Checkpoint, RunState};
+
+// How a run ended when it didn't fail outright
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum RunOutcome {
+    Complete,
+    // Stopped at the deadline; progress is saved in the checkpoint
+    Partial,
+    // Stopped on request after pushing completed work and saving the checkpoint
+    Cancelled,
+}
+
+impl RunOutcome {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            RunOutcome::Complete => "complete",
+            RunOutcome::Partial => "partial",
+            RunOutcome::Cancelled => "cancelled",
+        }
+    }
+}
+
+// What a run did, for the caller and the post-run hook
+#[derive(Debug, Clone, Copy)]
+pub struct RunReport {
+    pub outcome: RunOutcome,
+    // Commits created by this run
+    pub created: usize,
+    // Commits exist locally that still need to be pushed
+    pub pending_push: bool,
+    // Successful pushes, and push attempts made to flush a deferred push
+    pub pushes: usize,
+    pub push_retries: usize,
+}
+
+// Applies a plan to a repository: creates the commits in order, pushes in
+// batches and records progress in the state file
+pub struct Executor<'a> {
+    git_ops: &'a mut GitOperations,
+    deadline: Option<Instant>,
+    cancel: CancellationToken,
+    clock: Arc<dyn Clock>,
+    // Push batches as they're created; off when the caller force-pushes the
+    // result itself
+    push: bool,
+    pushes: usize,
+    push_retries: usize,
+}
+
+impl<'a> Executor<'a> {
+    pub fn new(git_ops: &'a mut GitOperations) -> Self {
+        Self {
+            git_ops,
+            deadline: None,
+            cancel: CancellationToken::new(),
+            clock: Arc::new(SystemClock),
+            push: true,
+            pushes: 0,
+            push_retries: 0,
+        }
+    }
+
+    pub fn with_clock(mut self, clock: Arc<dyn Clock>) -> Self {
+        self.clock = clock;
+        self
+    }
+
+    // Stop between commits once this instant has passed
+    pub fn with_deadline(mut self, deadline: Option<Instant>) -> Self {
+        self.deadline = deadline;
+        self
+    }
+
+    // Only create commits; the caller pushes (e.g. force-pushes a rewrite)
+    pub fn without_push(mut self) -> Self {
+        self.push = false;
+        self
+    }
+
+    // Shut down gracefully when this token is cancelled: the in-flight commit
+    // finishes, completed work is pushed and the checkpoint is written.
+    // Running git commands are left to finish (bounded by their timeout)
+    pub fn with_cancellation(mut self, cancel: CancellationToken) -> Self {
+        self.cancel = cancel;
+        self
+    }
+
+    // Multi-year plans are applied one calendar year at a time. Every year
+    // ends with a push and a checkpoint, as does every batch, so a failure
+    // late in a long range only costs the commits since then
+    pub fn execute(&mut self, plan: &Plan) -> Result<RunReport> {
+        self.execute_stream(&plan.outline(), plan.years().map(Ok))
+    }
+
+    // Apply a plan part by part as it's generated (see Planner::stream), so
+    // committing starts right away and only one part is held in memory.
+    // `outline` is the whole plan's, for the progress bar and checkpoints
+    pub fn execute_stream(
+        &mut self,
+        outline: &PlanOutline,
+        parts: impl IntoIterator<Item = Result<Plan>>,
+    ) -> Result<RunReport> {
+        let pb = progress_bar(outline.len() as u64);
+        self.git_ops.set_seed(outline.seed);
+
+        let mut state = RunState::load(self.git_ops.repo())?;
+        let before = self.git_ops.head_commit();
+        let mut batch_count = 0;
+        let mut last_commit: Option<DateTime<FixedOffset>> = None;
+        let mut outcome = RunOutcome::Complete;
+        let mut created = 0;
+
+        'chunks: for part in parts {
+            for commit in &part?.commits {
+                if self.cancel.is_cancelled() {
+                    pb.println("🛑 Shutting down: pushing completed commits (signal again to force quit)");
+                    outcome = RunOutcome::Cancelled;
+                    break 'chunks;
+                }
+
+                // Deadline is only checked between commits so the current one always finishes
+                if self.deadline.is_some_and(|deadline| Instant::now() >= deadline) {
+                    outcome = RunOutcome::Partial;
+                    break 'chunks;
+                }
+
+                pb.set_message(format!("Committing {}", commit.date.format("%Y-%m-%d %H:%M")));
+
+                self.git_ops.create_commit(commit)?;
+                last_commit = Some(commit.date);
+                created += 1;
+
+                batch_count += 1;
+                if self.push && batch_count >= PUSH_BATCH_SIZE {
+                    pb.set_message("Pushing batch...".to_string());
+                    self.push_or_defer(&mut state, &pb)?;
+                    batch_count = 0;
+                    save_checkpoint(&mut state, self.git_ops, last_commit, outline.end, outline.seed, false)?;
+                }
+
+                pb.inc(1);
+            }
+
+            if self.push && batch_count > 0 {
+                pb.set_message("Pushing chunk...".to_string());
+                self.push_or_defer(&mut state, &pb)?;
+                batch_count = 0;
+            }
+            save_checkpoint(&mut state, self.git_ops, last_commit, outline.end, outline.seed, false)?;
+        }
+
+        if created > 0 {
+            self.git_ops.sync_generated_files(before)?;
+        }
+
+        if self.push && (batch_count > 0 || state.pending_push) {
+            pb.set_message("Final push...".to_string());
+            self.push_or_defer(&mut state, &pb)?;
+        }
+
+        save_checkpoint(&mut state, self.git_ops, last_commit, outline.end, outline.seed, outcome == RunOutcome::Complete)?;
+
+        match outcome {
+            RunOutcome::Cancelled => pb.abandon_with_message("🛑 Cancelled"),
+            RunOutcome::Partial => pb.abandon_with_message("⏸️  Stopped at time limit"),
+            RunOutcome::Complete if state.pending_push => {
+                pb.finish_with_message("📴 Commits created locally; push deferred until connectivity returns")
+            }
+            RunOutcome::Complete => pb.finish_with_message("✅ All commits created successfully!"),
+        }
+        Ok(RunReport {
+            outcome,
+            created,
+            pending_push: state.pending_push,
+            pushes: self.pushes,
+            push_retries: self.push_retries,
+        })
+    }
+
+    // Retry a push that was deferred by a previous offline run
+    pub fn flush_pending_push(&mut self) -> Result<()> {
+        let mut state = RunState::load(self.git_ops.repo())?;
+        if !state.pending_push {
+            return Ok(());
+        }
+
+        status!("📤 Flushing push deferred since {}", state.pending_since
+            .map(|since| since.format("%Y-%m-%d %H:%M").to_string())
+            .unwrap_or_else(|| "a previous run".to_string()));
+
+        self.push_retries += 1;
+        match self.git_ops.push_commits() {
+            Ok(()) => {
+                self.pushes += 1;
+                state.clear_pending_push();
+                state.save(self.git_ops.repo())?;
+                status!("✅ Deferred commits pushed");
+            }
+            Err(GitHubGridError::Network(msg) | GitHubGridError::Timeout(msg)) => {
+                status!("📴 Still offline, push remains queued: {}", msg);
+            }
+            Err(e) => return Err(e),
+        }
+
+        Ok(())
+    }
+
+    // Push the current batch; when the network is down (or a push hangs until
+    // --git-timeout) keep committing locally and record the pending push in
+    // the state file so it is flushed later
+    fn push_or_defer(&mut self, state: &mut RunState, pb: &ProgressBar) -> Result<()> {
+        if state.pending_push {
+            self.push_retries += 1;
+        }
+        match self.git_ops.push_commits() {
+            Ok(()) => {
+                self.pushes += 1;
+                if state.pending_push {
+                    pb.println("📶 Connectivity restored, deferred commits pushed");
+                    state.clear_pending_push();
+                    state.save(self.git_ops.repo())?;
+                }
+                Ok(())
+            }
+            Err(GitHubGridError::Network(msg) | GitHubGridError::Timeout(msg)) => {
+                if !state.pending_push {
+                    pb.println(format!("📴 Offline, continuing locally: {}", msg));
+                    state.mark_pending_push(self.clock.now());
+                    state.save(self.git_ops.repo())?;
+                }
+                Ok(())
+            }
+            Err(e) => Err(e),
+        }
+    }
+}
+
+// Record how far the run got; nothing to record before the first commit
+fn save_checkpoint(
+    state: &mut RunState,
+    git_ops: &GitOperations,
+    last_commit: Option<DateTime<FixedOffset>>,
+    range_end: NaiveDate,
+    seed: u64,
+    complete: bool,
+) -> Result<()> {
+    if let Some(last_commit) = last_commit {
+        state.checkpoint = Some(Checkpoint { last_commit, range_end, complete, seed: Some(seed) });
+    }
+    state.save(git_ops.repo())
+}
+
+// Range to plan when the command line leaves part of it open: an interrupted
+// run's range and seed, or from the day after the last generated commit (a
+// year back when there is none) up to today
+pub fn determine_date_range(
+    git_ops: &mut GitOperations,
+    clock: &dyn Clock,
+    start: Option<String>,
+    end: Option<String>,
+) -> Result<(NaiveDate, NaiveDate, Option<u64>)> {
+    // Resume an interrupted run from its checkpoint when no range is given.
+    // The day it stopped on is planned again with the run's seed and only
+    // topped up, so nothing is lost or created twice
+    if start.is_none() {
+        if let Some(cp) = RunState::load(git_ops.repo())?.checkpoint.filter(|cp| !cp.complete) {
+            status!("↩️  Resuming partial run stopped at {}", cp.last_commit.format("%Y-%m-%d %H:%M"));
+            let end_date = match end {
+                Some(date_str) => NaiveDate::parse_from_str(&date_str, "%Y-%m-%d")?,
+                None => cp.range_end,
+            };
+            return Ok((cp.last_commit.date_naive(), end_date, cp.seed));
+        }
+    }
+    
+    let end_date = match end {
+        Some(date_str) => NaiveDate::parse_from_str(&date_str, "%Y-%m-%d")?,
+        None => clock.today(),
+    };
+    
+    let start_date = match start {
+        Some(date_str) => NaiveDate::parse_from_str(&date_str, "%Y-%m-%d")?,
+        None => {
+            match git_ops.get_latest_autogen_commit()? {
+                Some(last_commit) => last_commit.date_naive() + chrono::Duration::days(1),
+                None => end_date - chrono::Duration::days(365),
+            }
+        }
+    };
+    
+    Ok((start_date, end_date, None))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use chrono::{Local, TimeZone};
+    use crate::clock::FixedClock;
+    use crate::messages::AUTOGEN_MARKER;
+    use crate::patterns::CommitInfo;
+    use crate::testing::test_repo;
+
+    // Evening of 2024-06-12, so a range left open ends on that day
+    fn clock() -> FixedClock {
+        FixedClock::new(Local.with_ymd_and_hms(2024, 6, 12, 18, 30, 0).unwrap())
+    }
+
+    fn date(s: &str) -> NaiveDate {
+        NaiveDate::parse_from_str(s, "%Y-%m-%d").unwrap()
+    }
+
+    #[test]
+    fn date_range_given_explicitly() {
+        let (dir, mut git_ops) = test_repo("range-explicit");
+        let range = determine_date_range(&mut git_ops, &clock(), Some("2024-01-01".to_string()), Some("2024-03-31".to_string()));
+        assert_eq!(range.unwrap(), (date("2024-01-01"), date("2024-03-31"), None));
+        assert!(determine_date_range(&mut git_ops, &clock(), Some("2024-13-01".to_string()), None).is_err());
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn date_range_defaults_to_the_year_up_to_the_clock() {
+        let (dir, mut git_ops) = test_repo("range-default");
+        let range = determine_date_range(&mut git_ops, &clock(), None, None);
+        assert_eq!(range.unwrap(), (date("2023-06-13"), date("2024-06-12"), None));
+        // Just before midnight is still the same day
+        let late = FixedClock::new(Local.with_ymd_and_hms(2024, 6, 12, 23, 59, 59).unwrap());
+        let range = determine_date_range(&mut git_ops, &late, Some("2024-06-01".to_string()), None);
+        assert_eq!(range.unwrap(), (date("2024-06-01"), date("2024-06-12"), None));
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn date_range_continues_after_the_last_generated_commit() {
+        let (dir, mut git_ops) = test_repo("range-continue");
+        let last = Local.with_ymd_and_hms(2024, 5, 1, 12, 0, 0).unwrap().fixed_offset();
+        git_ops.create_commit(&CommitInfo { date: last, message: format!("{} commit", AUTOGEN_MARKER) }).unwrap();
+        
+        let range = determine_date_range(&mut git_ops, &clock(), None, None);
+        assert_eq!(range.unwrap(), (date("2024-05-02"), date("2024-06-12"), None));
+        let range = determine_date_range(&mut git_ops, &clock(), None, Some("2024-05-31".to_string()));
+        assert_eq!(range.unwrap(), (date("2024-05-02"), date("2024-05-31"), None));
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn date_range_resumes_an_interrupted_run() {
+        let (dir, mut git_ops) = test_repo("range-resume");
+        let last_commit = Local.with_ymd_and_hms(2024, 3, 4, 15, 0, 0).unwrap().fixed_offset();
+        let checkpoint = Checkpoint { last_commit, range_end: date("2024-04-30"), complete: false, seed: Some(7) };
+        RunState { checkpoint: Some(checkpoint), ..RunState::default() }.save(git_ops.repo()).unwrap();
+        
+        let range = determine_date_range(&mut git_ops, &clock(), None, None);
+        assert_eq!(range.unwrap(), (date("2024-03-04"), date("2024-04-30"), Some(7)));
+        // A start date given on the command line wins over the checkpoint
+        let range = determine_date_range(&mut git_ops, &clock(), Some("2024-01-01".to_string()), None);
+        assert_eq!(range.unwrap(), (date("2024-01-01"), date("2024-06-12"), None));
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+}
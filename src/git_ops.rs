@@ -1,21 +1,318 @@
-use chrono::{DateTime, Local};
-use git2::{Repository, Signature, Time, Oid};
+use chrono::{DateTime, FixedOffset, Local, NaiveDate};
+use git2::{Oid, Repository, Signature, Time, Tree};
+use rand::{Rng, SeedableRng};
+use rand::seq::IndexedRandom;
+use rand_chacha::ChaCha8Rng;
+use std::collections::{BTreeMap, BTreeSet};
+use std::process::Output;
+use std::sync::{Arc, OnceLock};
+use std::time::Duration;
+use crate::activity;
+use crate::messages::AUTOGEN_MARKER;
 use crate::patterns::CommitInfo;
-use crate::error::Result;
+use crate::cancel::CancellationToken;
+use crate::clock::{Clock, SystemClock};
+use crate::content::{self, ContentKind, GRID_DIR};
+use crate::error::{GitHubGridError, Result};
+use crate::exec::{credentials_error, git_command, run_command};
+use crate::output::{progress_bar, yellow};
+use crate::safety::{ForcePushGate, RefUpdate};
+use crate::{status, verbose};
+
+// Default limit for a single exec'd git command (push, log, ...)
+pub const DEFAULT_COMMAND_TIMEOUT: Duration = Duration::from_secs(300);
+// Branch all generated commits are written to and pushed from
+pub const MAIN_BRANCH: &str = "main";
+// Number of commits created between pushes
+pub const PUSH_BATCH_SIZE: usize = 500;
+// Namespace for refs recording history before it is rewritten
+pub const BACKUP_REF_PREFIX: &str = "refs/github-grid/backup/";
+
+// Limit for the quick remote access check before a run
+pub const REMOTE_CHECK_TIMEOUT: Duration = Duration::from_secs(20);
+// Scratch branch the preflight probe commit is pushed to
+pub const PROBE_BRANCH: &str = "github-grid-probe";
+// File in the grid directory each generated commit appends a line to when
+// the activity log is on, unless activity_log_file names another
+pub const ACTIVITY_LOG: &str = "activity.log";
+// Keeps the draws for a commit's content apart from other uses of its date
+const CONTENT_SALT: u64 = 0x434f_4e54_454e_5453;
+// Same for the committer date jitter
+const JITTER_SALT: u64 = 0x4a49_5454_4552_4454;
+// And for the activity log's {word}
+const WORD_SALT: u64 = 0x574f_5244_5341_4c54;
+
+// How the committer date of a generated commit relates to its backdated
+// author date, which is the one the graph uses
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub enum CommitterDate {
+    // Identical to the second
+    Author,
+    // A random amount later, up to this much, as if committed a little after
+    // it was written
+    Jitter(Duration),
+    // The actual time the commit is made, so only the author date is
+    // backdated
+    Now,
+}
+
+// What of the repository is actually on disk, and how. libgit2 knows neither
+// sparse checkouts nor partial clones: it would write out every path, and
+// stop at the first blob a partial clone left on the server with "object not
+// found". Generated commits only reuse trees, so they never touch a path or
+// run a filter, but checkouts and resets go through git itself when needed
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct CheckoutLayout {
+    // core.sparseCheckout: only some paths are in the working tree
+    pub sparse: bool,
+    // Remote that missing objects of a partial clone are fetched from
+    pub promisor: Option<String>,
+    // Git LFS files: libgit2 runs no filters, so a checkout would leave
+    // pointer files where the real content belongs
+    pub lfs: bool,
+    // .gitmodules on main; generated commits keep the gitlinks as they are
+    pub submodules: bool,
+}
+
+impl CheckoutLayout {
+    pub fn needs_git(&self) -> bool {
+        self.sparse || self.promisor.is_some() || self.lfs
+    }
+}
+
+// libgit2 refuses to open a repository that declares an extension it doesn't
+// know, which partial clones do (extensions.partialClone). Nothing that could
+// need a missing object goes through libgit2 for them (see CheckoutLayout),
+// so let it open them. Call before opening any repository
+pub fn allow_partial_clones() -> Result<()> {
+    // SAFETY: libgit2's global options aren't synchronized; this runs at
+    // startup, before any other thread uses libgit2
+    unsafe { git2::opts::set_extensions(&["partialclone".to_string()])? };
+    Ok(())
+}
+
+const INITIAL_COMMIT_MESSAGE: &str = "Initial commit: Setup repository for grid patterns";
+const README_CONTENT: &str = "# GitHub Contribution Grid\n\nThis repository contains generated commit patterns for GitHub contribution graphs.\n";
+const GITIGNORE_CONTENT: &str = "# Generated commits are empty; keep stray local files out of the history\n.DS_Store\n*.swp\n*~\n";
 
 pub struct GitOperations {
     repo: Repository,
+    command_timeout: Option<Duration>,
+    clock: Arc<dyn Clock>,
+    // Push with --no-verify so pre-push hooks don't run for filler commits
+    skip_push_hooks: bool,
+    // Appended to every generated message, e.g. "[skip ci]"
+    message_suffix: Option<String>,
+    // Identity overrides; unset parts come from the user's git config
+    author_name: Option<String>,
+    author_email: Option<String>,
+    // Name and email resolved on first use, so git config isn't re-read for
+    // every one of thousands of commits
+    identity: OnceLock<(String, String)>,
+    committer_date: CommitterDate,
+    // Directory, relative to the root, all generated files go in
+    grid_dir: String,
+    // File in `grid_dir` to append a line to with every commit instead of
+    // leaving it empty, and how the line reads; see activity.rs
+    activity_log: Option<String>,
+    activity_template: String,
+    // Kinds of change a generated commit makes, one drawn per commit; empty
+    // commits when there are none
+    content: Vec<ContentKind>,
+    // Now and then add a tiny binary asset instead of a content change
+    assets: bool,
+    // Seed of the plan being committed, mixed into the draws for content and
+    // committer dates so another seed doesn't repeat them
+    seed: u64,
 }
 
 impl GitOperations {
     pub fn new(repo: Repository) -> Self {
-        Self { repo }
+        Self {
+            repo,
+            command_timeout: Some(DEFAULT_COMMAND_TIMEOUT),
+            clock: Arc::new(SystemClock),
+            skip_push_hooks: false,
+            message_suffix: None,
+            author_name: None,
+            author_email: None,
+            identity: OnceLock::new(),
+            committer_date: CommitterDate::Author,
+            grid_dir: GRID_DIR.to_string(),
+            activity_log: None,
+            activity_template: activity::DEFAULT_TEMPLATE.to_string(),
+            content: Vec::new(),
+            assets: false,
+            seed: 0,
+        }
+    }
+    
+    pub fn layout(&self) -> CheckoutLayout {
+        let Ok(config) = self.repo.config() else {
+            return CheckoutLayout::default();
+        };
+        let tree = self.repo.head().and_then(|head| head.peel_to_tree()).ok();
+        let has = |path: &str| tree.as_ref().and_then(|tree| tree.get_path(std::path::Path::new(path)).ok());
+        // A partial clone may not have .gitattributes locally; .git/lfs then
+        // still gives LFS away
+        let attributes = has(".gitattributes")
+            .and_then(|entry| self.repo.find_blob(entry.id()).ok())
+            .is_some_and(|blob| String::from_utf8_lossy(blob.content()).contains("filter=lfs"));
+        CheckoutLayout {
+            sparse: config.get_bool("core.sparseCheckout").unwrap_or(false),
+            promisor: config.get_string("extensions.partialClone").ok(),
+            lfs: attributes || self.repo.path().join("lfs").is_dir(),
+            submodules: has(".gitmodules").is_some(),
+        }
     }
     
     pub fn repo(&self) -> &Repository {
         &self.repo
     }
     
+    pub fn command_timeout(&self) -> Option<Duration> {
+        self.command_timeout
+    }
+    
+    // None disables the timeout entirely
+    pub fn set_command_timeout(&mut self, timeout: Option<Duration>) {
+        self.command_timeout = timeout;
+    }
+    
+    pub fn set_clock(&mut self, clock: Arc<dyn Clock>) {
+        self.clock = clock;
+    }
+    
+    pub fn set_skip_push_hooks(&mut self, skip: bool) {
+        self.skip_push_hooks = skip;
+    }
+    
+    // Marker appended to generated commit messages, such as "[skip ci]" to
+    // keep thousands of filler commits from triggering CI pipelines
+    pub fn set_message_suffix(&mut self, suffix: Option<String>) {
+        self.message_suffix = suffix;
+    }
+    
+    // Commit as this identity instead of the one in the global git config,
+    // e.g. when a profile manages a different account
+    pub fn set_identity(&mut self, name: Option<String>, email: Option<String>) {
+        self.author_name = name;
+        self.author_email = email;
+        self.identity = OnceLock::new();
+    }
+    
+    pub fn set_committer_date(&mut self, committer_date: CommitterDate) {
+        self.committer_date = committer_date;
+    }
+    
+    pub fn set_grid_dir(&mut self, grid_dir: String) {
+        self.grid_dir = grid_dir.trim_end_matches('/').to_string();
+    }
+    
+    pub fn grid_dir(&self) -> &str {
+        &self.grid_dir
+    }
+    
+    pub fn set_activity_log(&mut self, activity_log: Option<String>) {
+        self.activity_log = activity_log;
+    }
+    
+    pub fn set_activity_template(&mut self, template: String) {
+        self.activity_template = template;
+    }
+    
+    pub fn set_content(&mut self, content: Vec<ContentKind>) {
+        self.content = content;
+    }
+    
+    pub fn set_assets(&mut self, assets: bool) {
+        self.assets = assets;
+    }
+    
+    pub fn set_seed(&mut self, seed: u64) {
+        self.seed = seed;
+    }
+    
+    // Save name and email as this repository's own user.name/user.email, so
+    // generated commits (and any made by hand here) use them from now on
+    pub fn configure_identity(&mut self, name: Option<&str>, email: Option<&str>) -> Result<()> {
+        if let Some(email) = email.filter(|email| is_placeholder_email(email)) {
+            return Err(GitHubGridError::Config(format!("'{}' can't belong to a GitHub account", email)));
+        }
+        for (key, value) in [("user.name", name), ("user.email", email)] {
+            let Some(value) = value else { continue };
+            let output = self.run_git(&["config", "--local", key, value])?;
+            if !output.status.success() {
+                let stderr = String::from_utf8_lossy(&output.stderr);
+                return Err(GitHubGridError::Config(format!("Failed to set {}: {}", key, stderr.trim())));
+            }
+        }
+        // Take precedence over a profile's identity for this run as well
+        self.author_name = name.map(str::to_string).or(self.author_name.take());
+        self.author_email = email.map(str::to_string).or(self.author_email.take());
+        self.identity = OnceLock::new();
+        Ok(())
+    }
+    
+    // Make sure generated commits get an author email GitHub can match to an
+    // account. Commits by an unset, local or placeholder address never show
+    // on any graph, so catch that before thousands of them are made
+    pub fn check_author_email(&self) -> Result<String> {
+        const GUIDANCE: &str = "Use an address verified on your GitHub account (or your \
+            ID+login@users.noreply.github.com address): set it with `git config user.email`, \
+            the profile's `email`, or --set-email to save it for this repository";
+        
+        let email = self.author_email.clone()
+            .or_else(|| self.repo.config().ok()?.get_string("user.email").ok())
+            .map(|email| email.trim().to_string())
+            .filter(|email| !email.is_empty());
+        match email {
+            None => Err(GitHubGridError::Config(format!("No author email is configured. {}", GUIDANCE))),
+            Some(email) if is_placeholder_email(&email) => Err(GitHubGridError::Config(format!(
+                "The author email '{}' can't belong to a GitHub account. {}", email, GUIDANCE
+            ))),
+            Some(email) => Ok(email),
+        }
+    }
+    
+    // Run a git command in the repository's working directory, bounded by
+    // the configured per-command timeout. A shutdown signal doesn't kill it:
+    // the run pushes what it made first, and a second signal quits outright
+    pub fn run_git(&self, args: &[&str]) -> Result<Output> {
+        self.run_git_within(args, self.command_timeout)
+    }
+    
+    // Commands never prompt; a failure for lack of credentials becomes an
+    // Authentication error with setup hints. The repository is passed the
+    // way it was opened, so a separate git directory (--git-dir, $GIT_DIR)
+    // or a bare one works the same as a plain clone
+    fn run_git_within(&self, args: &[&str], timeout: Option<Duration>) -> Result<Output> {
+        let mut cmd = git_command();
+        cmd.arg("--git-dir").arg(self.repo.path());
+        match self.repo.workdir() {
+            Some(workdir) => cmd.arg("--work-tree").arg(workdir).current_dir(workdir),
+            None => cmd.current_dir(self.repo.path()),
+        };
+        cmd.args(args);
+        let output = run_command(cmd, timeout, &CancellationToken::new())?;
+        if !output.status.success() {
+            if let Some(e) = credentials_error(&String::from_utf8_lossy(&output.stderr)) {
+                return Err(e);
+            }
+        }
+        Ok(output)
+    }
+    
+    // A git command that has to succeed; `what` it was for goes in the error
+    fn git_checked(&self, args: &[&str], what: &str) -> Result<Output> {
+        let output = self.run_git(args)?;
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Repository(format!("git could not {}: {}", what, stderr.trim())));
+        }
+        Ok(output)
+    }
+    
     pub fn get_latest_autogen_commit(&mut self) -> Result<Option<DateTime<Local>>> {
         let mut revwalk = self.repo.revwalk()?;
         revwalk.push_head()?;
@@ -25,8 +322,8 @@ impl GitOperations {
             let commit = self.repo.find_commit(oid)?;
             
             if let Some(message) = commit.message() {
-                if message.starts_with("[AutoGen]") {
-                    let time = commit.time();
+                if message.starts_with(AUTOGEN_MARKER) {
+                    let time = commit.author().when();
                     let timestamp = time.seconds();
                     let datetime = DateTime::from_timestamp(timestamp, 0)
                         .unwrap()
@@ -43,47 +340,49 @@ impl GitOperations {
         // Ensure we're on main branch
         self.ensure_main_branch()?;
         
-        // Get current tree (we'll create empty commits like --allow-empty)
-        let tree = match self.repo.head() {
-            Ok(head) => {
-                let commit = head.peel_to_commit()?;
-                commit.tree()?
-            }
-            Err(_) => {
-                // If no HEAD, create empty tree
+        // Parent commit, and its tree for the new one (empty commits like
+        // --allow-empty); an empty tree when there is no HEAD yet. The index
+        // is never read or written, so anything the user has staged stays
+        // staged and can't end up in a generated commit
+        let parent_commit = match self.repo.head() {
+            Ok(head) => Some(head.peel_to_commit()?),
+            Err(_) => None,
+        };
+        let tree = match &parent_commit {
+            Some(commit) => commit.tree()?,
+            None => {
                 let tree_id = self.repo.treebuilder(None)?.write()?;
                 self.repo.find_tree(tree_id)?
             }
         };
-        
-        // Get parent commit
-        let parent_commit = match self.repo.head() {
-            Ok(head) => {
-                let oid = head.target().unwrap();
-                Some(self.repo.find_commit(oid)?)
-            }
-            Err(_) => None,
+        let tree = match &self.activity_log {
+            Some(log) => self.append_activity(&tree, log, commit_info)?,
+            None => tree,
+        };
+        let tree = match self.content.is_empty() {
+            true => tree,
+            false => self.change_content(&tree, commit_info)?,
         };
         
-        // Get user's global git config for name and email
-        let config = git2::Config::open_default()?;
-        let name = config.get_string("user.name").unwrap_or_else(|_| "GitHub Grid".to_string());
-        let email = config.get_string("user.email").unwrap_or_else(|_| "github-grid@example.com".to_string());
+        // Signatures with the planned date, and the committer's per
+        // committer_date
+        let author = self.signature_at(commit_info.date)?;
+        let committer = self.signature_at(self.committer_time(commit_info.date))?;
         
-        // Create signature with commit date
-        let sig = Signature::new(
-            &name, 
-            &email,
-            &Time::new(commit_info.date.timestamp(), 0)
-        )?;
+        let message = match &self.message_suffix {
+            Some(suffix) => format!("{} {}", commit_info.message, suffix),
+            None => commit_info.message.clone(),
+        };
         
-        // Create empty commit (like git commit --allow-empty)
+        // Create empty commit (like git commit --allow-empty). libgit2 never
+        // runs pre-commit/commit-msg hooks, so repos with slow or failing
+        // hooks don't affect generation and no --no-verify is needed
         let parents: Vec<_> = parent_commit.iter().collect();
         let commit_id = self.repo.commit(
             Some("HEAD"),
-            &sig,
-            &sig,
-            &commit_info.message,
+            &author,
+            &committer,
+            &message,
             &tree,
             &parents,
         )?;
@@ -91,44 +390,1072 @@ impl GitOperations {
         Ok(commit_id)
     }
     
+    // `tree` with the commit's line added to the end of the activity log, so
+    // each generated commit is a one-line addition to a file that reads as a
+    // running log
+    fn append_activity(&self, tree: &Tree<'_>, log: &str, commit_info: &CommitInfo) -> Result<Tree<'_>> {
+        let path = self.grid_path(&activity::log_path(log, commit_info.date));
+        let mut content = self.file_content(tree, &path)?.unwrap_or_default();
+        if content.last().is_some_and(|&byte| byte != b'\n') {
+            content.push(b'\n');
+        }
+        let subject = commit_info.message.strip_prefix(AUTOGEN_MARKER).unwrap_or(&commit_info.message).trim();
+        let n = content.iter().filter(|&&byte| byte == b'\n').count() + 1;
+        let mut rng = ChaCha8Rng::seed_from_u64(self.seed ^ commit_info.date.timestamp() as u64 ^ WORD_SALT);
+        let line = activity::log_line(&self.activity_template, commit_info.date, subject, n, &mut rng);
+        content.extend(format!("{}\n", line).bytes());
+        self.with_file(tree, &path, &content)
+    }
+    
+    // `tree` with one of the content kinds' changes, to the file of that kind
+    // the repository has grown to so far. The draws follow from the commit's
+    // date and the plan's seed, so the same plan makes the same changes
+    fn change_content(&self, tree: &Tree<'_>, commit_info: &CommitInfo) -> Result<Tree<'_>> {
+        let mut rng = ChaCha8Rng::seed_from_u64(self.seed ^ commit_info.date.timestamp() as u64 ^ CONTENT_SALT);
+        if self.assets && rng.random_bool(content::ASSET_CHANCE) {
+            if let Some((path, bytes)) = content::next_asset(&self.asset_files(tree)?, &mut rng) {
+                return self.with_file(tree, &self.grid_path(&path), &bytes);
+            }
+        }
+        let Some(kind) = self.content.choose(&mut rng) else {
+            return Ok(self.repo.find_tree(tree.id())?);
+        };
+        let files = kind.files().iter()
+            .map(|path| self.file_content(tree, &self.grid_path(path)))
+            .collect::<Result<Vec<_>>>()?;
+        let lines: Vec<Option<usize>> = files.iter()
+            .map(|content| content.as_ref().map(|content| content.iter().filter(|&&byte| byte == b'\n').count()))
+            .collect();
+        let index = kind.next_file(&lines, &mut rng);
+        
+        let path = kind.files()[index];
+        let current = files[index].as_deref().map(String::from_utf8_lossy);
+        let content = kind.change(path, current.as_deref(), &mut rng);
+        self.with_file(tree, &self.grid_path(path), content.as_bytes())
+    }
+    
+    fn grid_path(&self, path: &str) -> String {
+        format!("{}/{}", self.grid_dir, path)
+    }
+    
+    // Paths, relative to the grid directory, of the assets content commits
+    // have added to `tree`
+    fn asset_files(&self, tree: &Tree<'_>) -> Result<Vec<String>> {
+        let mut paths = Vec::new();
+        for dir in content::ASSET_DIRS {
+            let Ok(entry) = tree.get_path(std::path::Path::new(&self.grid_path(dir))) else {
+                continue;
+            };
+            let Ok(dir_tree) = self.repo.find_tree(entry.id()) else {
+                continue;
+            };
+            paths.extend(dir_tree.iter()
+                .filter_map(|entry| entry.name().map(|name| format!("{}/{}", dir, name)))
+                .filter(|path| content::is_asset_file(path)));
+        }
+        Ok(paths)
+    }
+    
+    fn file_content(&self, tree: &Tree<'_>, path: &str) -> Result<Option<Vec<u8>>> {
+        match tree.get_path(std::path::Path::new(path)) {
+            Ok(entry) => Ok(Some(self.repo.find_blob(entry.id())?.content().to_vec())),
+            Err(_) => Ok(None),
+        }
+    }
+    
+    // `tree` with the file at `path` set to `content`
+    fn with_file(&self, tree: &Tree<'_>, path: &str, content: &[u8]) -> Result<Tree<'_>> {
+        self.with_entry(tree, path, Some((self.repo.blob(content)?, 0o100644)))
+    }
+    
+    // `tree` with `entry` (an id and file mode) at `path`, or with nothing
+    // there when it's None
+    fn with_entry(&self, tree: &Tree<'_>, path: &str, entry: Option<(Oid, i32)>) -> Result<Tree<'_>> {
+        let id = match self.write_entry(Some(tree), path, entry)? {
+            Some(id) => id,
+            None => self.repo.treebuilder(None)?.write()?,
+        };
+        Ok(self.repo.find_tree(id)?)
+    }
+    
+    // Writes `tree` again with `entry` at `path`, creating or replacing the
+    // directories on the way to it, or removing it and any directories that
+    // leaves empty. None when the tree itself ends up empty
+    fn write_entry(&self, tree: Option<&Tree<'_>>, path: &str, entry: Option<(Oid, i32)>) -> Result<Option<Oid>> {
+        let mut builder = self.repo.treebuilder(tree)?;
+        let (name, entry) = match path.split_once('/') {
+            Some((dir, rest)) => {
+                let subtree = tree
+                    .and_then(|tree| tree.get_name(dir))
+                    .filter(|entry| entry.kind() == Some(git2::ObjectType::Tree))
+                    .map(|entry| self.repo.find_tree(entry.id()))
+                    .transpose()?;
+                let id = self.write_entry(subtree.as_ref(), rest, entry)?;
+                (dir, id.map(|id| (id, 0o040000)))
+            }
+            None => (path, entry),
+        };
+        match entry {
+            Some((id, mode)) => {
+                builder.insert(name, id, mode)?;
+            }
+            None if builder.get(name)?.is_some() => builder.remove(name)?,
+            None => {}
+        }
+        Ok(match builder.len() {
+            0 => None,
+            _ => Some(builder.write()?),
+        })
+    }
+    
+    // The grid directory's entry in `tree`, None when it has none
+    fn grid_entry(&self, tree: &Tree<'_>) -> Option<(Oid, i32)> {
+        tree.get_path(std::path::Path::new(&self.grid_dir)).ok().map(|entry| (entry.id(), entry.filemode()))
+    }
+    
+    // Files under the grid directory in `tree`, by path from the root
+    pub fn generated_files(&self, tree: &Tree<'_>) -> Result<Vec<(String, Oid)>> {
+        let Ok(entry) = tree.get_path(std::path::Path::new(&self.grid_dir)) else {
+            return Ok(Vec::new());
+        };
+        let mut files = Vec::new();
+        let mut dirs = vec![(self.grid_dir.clone(), entry.id())];
+        while let Some((dir, id)) = dirs.pop() {
+            let Ok(dir_tree) = self.repo.find_tree(id) else {
+                continue;
+            };
+            for entry in dir_tree.iter() {
+                let Some(name) = entry.name() else {
+                    continue;
+                };
+                let path = format!("{}/{}", dir, name);
+                match entry.kind() {
+                    Some(git2::ObjectType::Tree) => dirs.push((path, entry.id())),
+                    Some(git2::ObjectType::Blob) => files.push((path, entry.id())),
+                    _ => {}
+                }
+            }
+        }
+        files.sort();
+        Ok(files)
+    }
+    
+    // Generated commits move main without touching the index or working
+    // tree, which is invisible while they're empty. With the activity log or
+    // content, bring the grid directory up to date afterwards from `before`,
+    // the commit HEAD was at until then, so git status stays clean. Only
+    // files whose index entry and working copy both still match `before` are
+    // updated; anything the user has staged or changed there is left alone
+    // and reported, and nothing outside the grid directory is touched
+    pub fn sync_generated_files(&self, before: Option<Oid>) -> Result<()> {
+        let Some(workdir) = self.repo.workdir() else {
+            return Ok(());
+        };
+        let Ok(tree) = self.repo.head().and_then(|head| head.peel_to_tree()) else {
+            return Ok(());
+        };
+        let old: BTreeMap<String, Oid> = match before {
+            Some(before) => self.generated_files(&self.repo.find_commit(before)?.tree()?)?.into_iter().collect(),
+            None => BTreeMap::new(),
+        };
+        let new: BTreeMap<String, Oid> = self.generated_files(&tree)?.into_iter().collect();
+        let paths: BTreeSet<&String> = old.keys().chain(new.keys())
+            .filter(|path| old.get(*path) != new.get(*path))
+            .collect();
+        if paths.is_empty() {
+            return Ok(());
+        }
+        
+        // Sparse patterns, missing objects and LFS filters are git's to
+        // apply; writing files directly would get them wrong
+        if self.layout().needs_git() {
+            status!("{}", yellow(format!(
+                "⚠️  Sparse, partial or LFS checkout: {}/ was left as it was, so git status shows the generated \
+                 changes undone. `git restore --source=HEAD --staged --worktree -- {}` brings it up to date",
+                self.grid_dir, self.grid_dir
+            )));
+            return Ok(());
+        }
+        
+        let mut index = self.repo.index()?;
+        let mut skipped = Vec::new();
+        for path in paths {
+            let was = old.get(path).copied();
+            let file = workdir.join(path);
+            let staged = index.get_path(std::path::Path::new(path), 0).map(|entry| entry.id);
+            let on_disk = match file.is_file() {
+                true => Some(Oid::hash_file(git2::ObjectType::Blob, &file)?),
+                false => None,
+            };
+            if staged != was || on_disk != was {
+                skipped.push(path.as_str());
+                continue;
+            }
+            match new.get(path) {
+                Some(&id) => {
+                    if let Some(dir) = file.parent() {
+                        std::fs::create_dir_all(dir)?;
+                    }
+                    std::fs::write(&file, self.repo.find_blob(id)?.content())?;
+                    index.add_path(std::path::Path::new(path))?;
+                }
+                None => {
+                    if was.is_some() {
+                        std::fs::remove_file(&file)?;
+                    }
+                    index.remove_path(std::path::Path::new(path))?;
+                    // Drop directories that are empty now, up to the root
+                    let mut dir = file.parent();
+                    while let Some(parent) = dir.filter(|parent| *parent != workdir) {
+                        if std::fs::remove_dir(parent).is_err() {
+                            break;
+                        }
+                        dir = parent.parent();
+                    }
+                }
+            }
+        }
+        index.write()?;
+        if !skipped.is_empty() {
+            status!("{}", yellow(format!(
+                "⚠️  Left {} generated file(s) you've changed alone: {}",
+                skipped.len(), skipped.join(", ")
+            )));
+        }
+        Ok(())
+    }
+    
     pub fn push_commits(&mut self) -> Result<()> {
-        let repo_path = self.repo.workdir().unwrap();
+        verbose!("🚀 Pushing commits to GitHub...");
         
-        println!("🚀 Pushing commits to GitHub...");
+        let mut args = vec!["push"];
+        if self.skip_push_hooks {
+            args.push("--no-verify");
+        }
+        args.extend(["origin", MAIN_BRANCH]);
         
-        let output = std::process::Command::new("git")
-            .current_dir(repo_path)
-            .args(&["push", "origin", "main"])
-            .output()
-            .map_err(|e| crate::error::GitHubGridError::Io(e))?;
+        let output = self.run_git(&args)?;
             
         if !output.status.success() {
             let stderr = String::from_utf8_lossy(&output.stderr);
+            if is_network_failure(&stderr) {
+                return Err(crate::error::GitHubGridError::Network(
+                    format!("Git push failed: {}", stderr.trim())
+                ));
+            }
             return Err(crate::error::GitHubGridError::Repository(
                 format!("Git push failed: {}", stderr)
             ));
         }
         
-        let stdout = String::from_utf8_lossy(&output.stdout);
-        if !stdout.is_empty() {
-            println!("Push output: {}", stdout.trim());
+        Ok(())
+    }
+    
+    // Fetch URL of the origin remote, if configured
+    pub fn remote_url(&self) -> Option<String> {
+        self.repo.find_remote("origin").ok()?.url().map(|url| url.to_string())
+    }
+    
+    // Push one empty commit made now on top of main to a new branch on
+    // origin, for a pull request. main itself is left alone
+    pub fn push_branch(&mut self, branch: &str, message: &str) -> Result<Oid> {
+        self.ensure_main_branch()?;
+        let parent = self.repo.head()?.peel_to_commit()?;
+        let sig = self.signature_at(self.clock.now().fixed_offset())?;
+        let refname = format!("refs/heads/{}", branch);
+        let oid = self.repo.commit(Some(&refname), &sig, &sig, message, &parent.tree()?, &[&parent])?;
+        
+        let mut args = vec!["push"];
+        if self.skip_push_hooks {
+            args.push("--no-verify");
+        }
+        args.extend(["origin", branch]);
+        let output = self.run_git(&args)?;
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Network(format!("Failed to push {}: {}", branch, stderr.trim())));
+        }
+        Ok(oid)
+    }
+    
+    // Remove a local branch, e.g. once its pull request is merged
+    pub fn delete_branch(&mut self, branch: &str) -> Result<()> {
+        if let Ok(mut reference) = self.repo.find_reference(&format!("refs/heads/{}", branch)) {
+            reference.delete()?;
+        }
+        Ok(())
+    }
+    
+    // Fast-forward main to origin, after something was merged there
+    pub fn pull_main(&mut self) -> Result<()> {
+        self.ensure_main_branch()?;
+        let output = self.run_git(&["pull", "--ff-only", "origin", MAIN_BRANCH])?;
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Network(format!("Failed to pull {}: {}", MAIN_BRANCH, stderr.trim())));
+        }
+        Ok(())
+    }
+    
+    // Contact origin with git ls-remote, so missing credentials show up before
+    // thousands of commits are made rather than at the first push. Errors are
+    // Network when origin can't be reached at all (a run can still queue its
+    // pushes) and Authentication when it answers but refuses
+    pub fn check_remote_access(&self) -> Result<()> {
+        let args = ["ls-remote", "--heads", "origin", MAIN_BRANCH];
+        let output = match self.run_git_within(&args, Some(REMOTE_CHECK_TIMEOUT)) {
+            Err(GitHubGridError::Timeout(_)) => return Err(GitHubGridError::Network(format!(
+                "origin did not answer within {}s", REMOTE_CHECK_TIMEOUT.as_secs()
+            ))),
+            result => result?,
+        };
+        if output.status.success() {
+            return Ok(());
+        }
+        
+        let stderr = String::from_utf8_lossy(&output.stderr);
+        if is_network_failure(&stderr) {
+            return Err(GitHubGridError::Network(format!("Cannot reach origin: {}", stderr.trim())));
+        }
+        Err(GitHubGridError::Authentication(format!(
+            "origin refused access: {}. For HTTPS run `gh auth setup-git`; for SSH check `ssh -T git@github.com`",
+            stderr.trim()
+        )))
+    }
+    
+    // Branch origin's HEAD points at, i.e. the default branch on GitHub. None
+    // when origin has no branches yet; the first push then makes main the
+    // default
+    pub fn remote_default_branch(&self) -> Result<Option<String>> {
+        let output = self.run_git_within(&["ls-remote", "--symref", "origin", "HEAD"], Some(REMOTE_CHECK_TIMEOUT))?;
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Network(format!("Cannot read origin's default branch: {}", stderr.trim())));
+        }
+        // "ref: refs/heads/main\tHEAD"
+        Ok(String::from_utf8_lossy(&output.stdout).lines()
+            .find_map(|line| line.strip_prefix("ref: refs/heads/")?.strip_suffix("\tHEAD").map(str::to_string)))
+    }
+    
+    // "owner/name" of origin when it is hosted on GitHub
+    pub fn remote_slug(&self) -> Option<String> {
+        let url = self.remote_url()?;
+        let (_, path) = url.split_once("github.com")?;
+        let slug = path.trim_start_matches([':', '/']).trim_end_matches('/');
+        let slug = slug.strip_suffix(".git").unwrap_or(slug);
+        slug.contains('/').then(|| slug.to_string())
+    }
+    
+    // Push a single commit dated `date`, with the identity generated commits
+    // get, to a scratch branch on origin. main and the working tree are left
+    // alone; remove it again with delete_probe
+    pub fn push_probe(&mut self, date: DateTime<FixedOffset>) -> Result<Oid> {
+        let refname = format!("refs/heads/{}", PROBE_BRANCH);
+        // A leftover from an interrupted probe would otherwise have to be
+        // the new commit's parent
+        if let Ok(mut reference) = self.repo.find_reference(&refname) {
+            reference.delete()?;
+        }
+        
+        let sig = self.signature_at(date)?;
+        let tree_id = self.repo.treebuilder(None)?.write()?;
+        let tree = self.repo.find_tree(tree_id)?;
+        let message = format!("{} github-grid preflight probe", AUTOGEN_MARKER);
+        let oid = self.repo.commit(Some(&refname), &sig, &sig, &message, &tree, &[])?;
+        
+        let mut args = vec!["push", "--force"];
+        if self.skip_push_hooks {
+            args.push("--no-verify");
+        }
+        args.extend(["origin", PROBE_BRANCH]);
+        let output = self.run_git(&args)?;
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(crate::error::GitHubGridError::Network(
+                format!("Failed to push the probe commit: {}", stderr.trim())
+            ));
+        }
+        
+        Ok(oid)
+    }
+    
+    // Remove the probe branch from origin and locally
+    pub fn delete_probe(&mut self) -> Result<()> {
+        let output = self.run_git(&["push", "--no-verify", "origin", "--delete", PROBE_BRANCH])?;
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(crate::error::GitHubGridError::Network(
+                format!("Failed to delete the probe branch on origin: {}", stderr.trim())
+            ));
+        }
+        
+        if let Ok(mut reference) = self.repo.find_reference(&format!("refs/heads/{}", PROBE_BRANCH)) {
+            reference.delete()?;
+        }
+        Ok(())
+    }
+    
+    // Force-push main after history was rewritten locally. Requires the gate's
+    // permission and confirmation, and backs up the remote tip first
+    pub fn force_push(&mut self, gate: &ForcePushGate) -> Result<()> {
+        // Refresh origin/main so the confirmation shows what is really there
+        self.run_git(&["fetch", "origin", MAIN_BRANCH])?;
+        
+        let update = self.main_ref_update()?;
+        gate.authorize(std::slice::from_ref(&update))?;
+        
+        let mut lease = format!("--force-with-lease={}", MAIN_BRANCH);
+        if let Some(remote) = update.remote {
+            let backup = self.create_backup_ref(remote)?;
+            status!("💾 Previous origin/{} saved as {}", MAIN_BRANCH, backup);
+            lease = format!("{}:{}", lease, remote);
+        }
+        
+        status!("🚀 Force pushing {}...", MAIN_BRANCH);
+        let output = self.run_git(&["push", &lease, "origin", MAIN_BRANCH])?;
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(crate::error::GitHubGridError::Repository(
+                format!("Force push failed: {}", stderr)
+            ));
         }
         
         Ok(())
     }
     
+    // Record `target` under refs/github-grid/backup/<timestamp> so rewritten
+    // history can always be restored
+    pub fn create_backup_ref(&self, target: Oid) -> Result<String> {
+        let base = format!("{}{}", BACKUP_REF_PREFIX, self.clock.now().format("%Y%m%d-%H%M%S"));
+        let mut name = base.clone();
+        let mut suffix = 1;
+        while self.repo.find_reference(&name).is_ok() {
+            name = format!("{}-{}", base, suffix);
+            suffix += 1;
+        }
+        
+        self.repo.reference(&name, target, false, "github-grid: backup before rewrite")?;
+        Ok(name)
+    }
+    
+    // Remove the generated commits dated `start` to `end` from main. Works as
+    // a filter over the history rather than a rebase: commits are read once,
+    // oldest first, and every commit after the first dropped one is written
+    // again on top of what remains, with its author and dates intact and its
+    // tree less what the dropped ones wrote to the grid directory. The old
+    // tip is saved as a backup ref first. Returns how many commits were
+    // dropped
+    pub fn drop_autogen_commits(&mut self, start: NaiveDate, end: NaiveDate) -> Result<usize> {
+        self.ensure_main_branch()?;
+        let head = self.repo.head()?.peel_to_commit()?.id();
+        
+        let mut revwalk = self.repo.revwalk()?;
+        revwalk.push(head)?;
+        revwalk.set_sorting(git2::Sort::TOPOLOGICAL | git2::Sort::REVERSE)?;
+        let oids = revwalk.collect::<std::result::Result<Vec<_>, _>>()?;
+        
+        let pb = progress_bar(oids.len() as u64);
+        
+        // New tip of the rewritten history; unchanged until the first drop
+        let mut tip: Option<Oid> = None;
+        let mut dropped = 0;
+        for oid in oids {
+            pb.inc(1);
+            pb.set_message(format!("{} dropped", dropped));
+            
+            let commit = self.repo.find_commit(oid)?;
+            let generated = commit.message().is_some_and(|message| message.starts_with(AUTOGEN_MARKER));
+            if generated && commit_day(commit.author().when()).is_some_and(|day| start <= day && day <= end) {
+                dropped += 1;
+                continue;
+            }
+            if dropped == 0 {
+                tip = Some(commit.id());
+                continue;
+            }
+            if commit.parent_count() > 1 {
+                pb.abandon();
+                return Err(GitHubGridError::Repository(format!(
+                    "Can't drop generated commits: {} is a merge commit", commit.id()
+                )));
+            }
+            
+            // Generated commits only write the grid directory, so a kept
+            // commit that left it as it was takes the rewritten parent's,
+            // without what the dropped ones wrote. One that changed it builds
+            // on what they wrote, which can't be taken out again
+            let tree = commit.tree()?;
+            let original = commit.parents().next().map(|parent| parent.tree()).transpose()?;
+            if original.as_ref().and_then(|tree| self.grid_entry(tree)) != self.grid_entry(&tree) {
+                pb.abandon();
+                return Err(GitHubGridError::Repository(format!(
+                    "Can't drop generated commits: {} after them changes {}/ too, and would keep what they \
+                     wrote there; include it in the range",
+                    commit.id(), self.grid_dir
+                )));
+            }
+            let parent = tip.map(|oid| self.repo.find_commit(oid)).transpose()?;
+            let grid = parent.as_ref().map(|parent| parent.tree()).transpose()?.and_then(|tree| self.grid_entry(&tree));
+            let tree = self.with_entry(&tree, &self.grid_dir, grid)?;
+            let parents: Vec<_> = parent.iter().collect();
+            tip = Some(self.repo.commit(
+                None,
+                &commit.author(),
+                &commit.committer(),
+                commit.message().unwrap_or(""),
+                &tree,
+                &parents,
+            )?);
+        }
+        pb.finish_with_message(format!("{} dropped", dropped));
+        if dropped == 0 {
+            return Ok(0);
+        }
+        let Some(tip) = tip else {
+            return Err(GitHubGridError::Repository(
+                "Can't drop generated commits: nothing would be left on main".to_string()
+            ));
+        };
+        
+        let backup = self.create_backup_ref(head)?;
+        status!("💾 Previous {} saved as {}", MAIN_BRANCH, backup);
+        self.repo.reference(
+            &format!("refs/heads/{}", MAIN_BRANCH),
+            tip,
+            true,
+            "github-grid: drop generated commits",
+        )?;
+        // Main moved under the checkout; what the dropped commits wrote goes
+        // from the working tree too
+        self.sync_generated_files(Some(head))?;
+        Ok(dropped)
+    }
+    
+    // Commits on main that origin's copy doesn't have, as (generated, all).
+    // Goes by the last fetch or push, so it works offline; (0, 0) when main
+    // has never been pushed
+    pub fn unpushed_commits(&self) -> Result<(usize, usize)> {
+        let (Ok(local), Some(remote)) = (self.repo.refname_to_id(&format!("refs/heads/{}", MAIN_BRANCH)), self.remote_main()) else {
+            return Ok((0, 0));
+        };
+        let mut revwalk = self.repo.revwalk()?;
+        revwalk.push(local)?;
+        revwalk.hide(remote)?;
+        
+        let (mut generated, mut all) = (0, 0);
+        for oid in revwalk {
+            let commit = self.repo.find_commit(oid?)?;
+            if commit.message().is_some_and(|message| message.starts_with(AUTOGEN_MARKER)) {
+                generated += 1;
+            }
+            all += 1;
+        }
+        Ok((generated, all))
+    }
+    
+    // Tip of origin/main as of the last fetch or push
+    pub fn remote_main(&self) -> Option<Oid> {
+        self.repo.refname_to_id(&format!("refs/remotes/origin/{}", MAIN_BRANCH)).ok()
+    }
+    
+    // Commit HEAD points at; None before the first commit
+    pub fn head_commit(&self) -> Option<Oid> {
+        self.repo.head().and_then(|head| head.peel_to_commit()).map(|commit| commit.id()).ok()
+    }
+    
+    // Back up main's current tip, e.g. before a run adds to it. None while
+    // main has no commits yet
+    pub fn backup_head(&self) -> Result<Option<String>> {
+        match self.repo.refname_to_id(&format!("refs/heads/{}", MAIN_BRANCH)) {
+            Ok(tip) => Ok(Some(self.create_backup_ref(tip)?)),
+            Err(_) => Ok(None),
+        }
+    }
+    
+    // Backup refs with the commit each points at, oldest first
+    pub fn backups(&self) -> Result<Vec<(String, Oid)>> {
+        let mut backups = Vec::new();
+        for reference in self.repo.references_glob(&format!("{}*", BACKUP_REF_PREFIX))? {
+            let reference = reference?;
+            if let (Some(name), Some(target)) = (reference.name(), reference.target()) {
+                backups.push((name.to_string(), target));
+            }
+        }
+        // Names are timestamps, so they sort by age
+        backups.sort();
+        Ok(backups)
+    }
+    
+    // Point main at `target` and check it out, backing up the current tip
+    // first so the rollback itself can be undone
+    pub fn reset_main(&mut self, target: Oid) -> Result<Option<String>> {
+        // A hard reset rewrites the index and working tree; never take the
+        // user's own changes with it
+        let with_git = self.layout().needs_git();
+        let modified = match with_git {
+            true => !self.git_checked(&["status", "--porcelain", "--untracked-files=no"], "read the status")?.stdout.is_empty(),
+            false => {
+                let mut options = git2::StatusOptions::new();
+                options.include_untracked(false);
+                !self.repo.statuses(Some(&mut options))?.is_empty()
+            }
+        };
+        if modified {
+            return Err(GitHubGridError::Repository(
+                "The repository has staged or modified files; commit or stash them before rolling back".to_string()
+            ));
+        }
+        self.ensure_main_branch()?;
+        let backup = self.backup_head()?;
+        let commit = self.repo.find_commit(target)?;
+        if with_git {
+            self.git_checked(&["reset", "--hard", "--quiet", &target.to_string()], "reset main")?;
+        } else {
+            self.repo.reset(commit.as_object(), git2::ResetType::Hard, None)?;
+        }
+        Ok(backup)
+    }
+    
+    // What a force push of main would change on origin
+    fn main_ref_update(&self) -> Result<RefUpdate> {
+        let name = format!("refs/heads/{}", MAIN_BRANCH);
+        let local = self.repo.refname_to_id(&name)?;
+        let remote = self.repo
+            .refname_to_id(&format!("refs/remotes/origin/{}", MAIN_BRANCH))
+            .ok();
+        
+        let discarded = match remote {
+            Some(remote) => {
+                let mut revwalk = self.repo.revwalk()?;
+                revwalk.push(remote)?;
+                revwalk.hide(local)?;
+                revwalk.count()
+            }
+            None => 0,
+        };
+        
+        Ok(RefUpdate { name, local, remote, discarded })
+    }
+    
+    // Count commits authored in the given calendar year
+    pub fn count_commits_in_year(&self, year: i32) -> Result<u32> {
+        // By author date; --since (committer date) only narrows the walk
+        let output = self.run_git(&[
+            "log",
+            "--format=%ad",
+            "--date=format:%Y",
+            &format!("--since={}-12-30", year - 1),
+        ])?;
+        
+        if !output.status.success() {
+            return Ok(0); // Empty repo or no commits in range
+        }
+        
+        let year = year.to_string();
+        let commit_lines = String::from_utf8_lossy(&output.stdout);
+        Ok(commit_lines.lines().filter(|line| *line == year).count() as u32)
+    }
+    
+    // Generated commits already in the history per day from `start` to `end`,
+    // each counted on the day of its own recorded timezone, as it was planned
+    pub fn autogen_commits_per_day(&self, start: NaiveDate, end: NaiveDate) -> Result<BTreeMap<NaiveDate, u32>> {
+        self.commits_per_day(start, end, |subject| subject.starts_with(AUTOGEN_MARKER))
+    }
+    
+    // Commits made by hand per day: everything but generated commits and the
+    // commit `init` sets the repository up with
+    pub fn real_commits_per_day(&self, start: NaiveDate, end: NaiveDate) -> Result<BTreeMap<NaiveDate, u32>> {
+        self.commits_per_day(start, end, |subject| {
+            !subject.starts_with(AUTOGEN_MARKER) && subject != INITIAL_COMMIT_MESSAGE
+        })
+    }
+    
+    // Commits on HEAD made by hand: neither generated nor init's first
+    // commit. Stops counting once past `limit`
+    pub fn count_real_commits(&self, limit: usize) -> Result<usize> {
+        let mut revwalk = self.repo.revwalk()?;
+        // No commits yet
+        if revwalk.push_head().is_err() {
+            return Ok(0);
+        }
+        
+        let mut count = 0;
+        for oid in revwalk {
+            let commit = self.repo.find_commit(oid?)?;
+            let subject = commit.summary().unwrap_or("");
+            if !subject.starts_with(AUTOGEN_MARKER) && subject != INITIAL_COMMIT_MESSAGE {
+                count += 1;
+                if count > limit {
+                    break;
+                }
+            }
+        }
+        Ok(count)
+    }
+    
+    // Every file path in HEAD's tree; none before the first commit
+    pub fn tracked_files(&self) -> Result<Vec<String>> {
+        let output = self.run_git(&["ls-tree", "-r", "--name-only", "HEAD"])?;
+        if !output.status.success() {
+            return Ok(Vec::new());
+        }
+        Ok(String::from_utf8_lossy(&output.stdout).lines().map(str::to_string).collect())
+    }
+    
+    fn commits_per_day(&self, start: NaiveDate, end: NaiveDate, counts: impl Fn(&str) -> bool) -> Result<BTreeMap<NaiveDate, u32>> {
+        let output = self.run_git(&[
+            "log",
+            "--format=%ad %s",
+            "--date=format:%Y-%m-%d",
+            // --since goes by committer timestamp, which is never before the
+            // author date, so it only narrows the walk; leave room for commits
+            // recorded in a timezone ahead of this machine's. There's no bound
+            // at the end: committer dates may be the real time of the run
+            &format!("--since={}", start - chrono::Duration::days(2)),
+        ])?;
+        
+        let mut days = BTreeMap::new();
+        if !output.status.success() {
+            return Ok(days); // Empty repo or no commits in range
+        }
+        
+        for line in String::from_utf8_lossy(&output.stdout).lines() {
+            let Some((day, subject)) = line.split_once(' ') else { continue };
+            let Ok(day) = NaiveDate::parse_from_str(day, "%Y-%m-%d") else { continue };
+            if start <= day && day <= end && counts(subject) {
+                *days.entry(day).or_insert(0) += 1;
+            }
+        }
+        Ok(days)
+    }
+    
+    // Keep a repository with thousands of generated commits fast: let gc
+    // pack loose objects when git thinks it's due, and write the commit-graph
+    // file that speeds up log and history walks
+    pub fn run_maintenance(&self) -> Result<()> {
+        for args in [
+            &["gc", "--auto", "--quiet"][..],
+            &["commit-graph", "write", "--reachable"][..],
+        ] {
+            let output = self.run_git(args)?;
+            if !output.status.success() {
+                let stderr = String::from_utf8_lossy(&output.stderr);
+                return Err(GitHubGridError::Repository(
+                    format!("git {} failed: {}", args.join(" "), stderr.trim())
+                ));
+            }
+        }
+        Ok(())
+    }
+    
+    // Create the initial README commit in a freshly cloned repository and push it
+    pub fn initialize_repository(&mut self) -> Result<()> {
+        let repo_path = self.repo.workdir()
+            .ok_or_else(|| GitHubGridError::Repository("A bare repository can't be initialized with a README".to_string()))?
+            .to_path_buf();
+        std::fs::write(repo_path.join("README.md"), README_CONTENT)?;
+        std::fs::write(repo_path.join(".gitignore"), GITIGNORE_CONTENT)?;
+        
+        // The first commit creates main, whatever git's default branch is
+        self.repo.set_head(&format!("refs/heads/{}", MAIN_BRANCH))?;
+        
+        // Stage the README and .gitignore
+        let mut index = self.repo.index()?;
+        index.add_path(std::path::Path::new("README.md"))?;
+        index.add_path(std::path::Path::new(".gitignore"))?;
+        index.write()?;
+        
+        let tree_id = index.write_tree()?;
+        let tree = self.repo.find_tree(tree_id)?;
+        let sig = self.signature_at(self.clock.now().fixed_offset())?;
+        
+        self.repo.commit(
+            Some("HEAD"),
+            &sig,
+            &sig,
+            INITIAL_COMMIT_MESSAGE,
+            &tree,
+            &[],
+        )?;
+        
+        self.push_commits()
+    }
+    
+    // Author/committer identity from the override or the user's git config,
+    // so initial and generated commits are attributed the same way. The
+    // commit records the UTC offset in effect at that moment, which differs
+    // across a DST change or during a timezone period
+    fn signature_at(&self, time: DateTime<FixedOffset>) -> Result<Signature<'static>> {
+        let (name, email) = match self.identity.get() {
+            Some(identity) => identity,
+            None => {
+                // The repository's config, so repo-local identities apply too
+                let config = self.repo.config()?;
+                let name = self.author_name.clone()
+                    .or_else(|| config.get_string("user.name").ok())
+                    .unwrap_or_else(|| "GitHub Grid".to_string());
+                let email = self.author_email.clone()
+                    .or_else(|| config.get_string("user.email").ok())
+                    .unwrap_or_else(|| "github-grid@example.com".to_string());
+                self.identity.get_or_init(|| (name, email))
+            }
+        };
+        
+        let offset_minutes = time.offset().local_minus_utc() / 60;
+        Ok(Signature::new(name, email, &Time::new(time.timestamp(), offset_minutes))?)
+    }
+    
+    fn committer_time(&self, date: DateTime<FixedOffset>) -> DateTime<FixedOffset> {
+        match self.committer_date {
+            CommitterDate::Author => date,
+            // Drawn from the seed and the author date, so --seed reproduces
+            // committer dates too
+            CommitterDate::Jitter(max) => {
+                let mut rng = ChaCha8Rng::seed_from_u64(self.seed ^ date.timestamp() as u64 ^ JITTER_SALT);
+                let offset = chrono::Duration::seconds(rng.random_range(0..=max.as_secs() as i64));
+                // Never later than the run itself
+                let now = self.clock.now().with_timezone(date.offset());
+                (date + offset).min(now).max(date)
+            }
+            CommitterDate::Now => self.clock.now().fixed_offset(),
+        }
+    }
+    
     fn ensure_main_branch(&mut self) -> Result<()> {
-        let head = self.repo.head()?;
-        let branch_name = head.shorthand().unwrap_or("");
+        let main_ref = format!("refs/heads/{}", MAIN_BRANCH);
+        
+        let on_main = match self.repo.head() {
+            Ok(head) => head.is_branch() && head.shorthand() == Some(MAIN_BRANCH),
+            // Unborn HEAD: fine if it already points at main
+            Err(e) if e.code() == git2::ErrorCode::UnbornBranch => {
+                self.repo.find_reference("HEAD")?.symbolic_target() == Some(main_ref.as_str())
+            }
+            Err(e) => return Err(e.into()),
+        };
+        if on_main {
+            return Ok(());
+        }
+        
+        if self.repo.head_detached()? {
+            status!("🔀 HEAD is detached, switching to '{}'", MAIN_BRANCH);
+        }
+        
+        if self.repo.find_reference(&main_ref).is_err() {
+            match self.remote_default_commit()? {
+                Some(commit) => {
+                    self.repo.branch(MAIN_BRANCH, &commit, false)?;
+                    status!("🌱 Created missing '{}' branch from the remote default branch", MAIN_BRANCH);
+                }
+                None => {
+                    // Nothing to start from: make main an orphan branch, the
+                    // first generated commit will create it
+                    self.repo.set_head(&main_ref)?;
+                    status!("🌱 Starting '{}' as a new orphan branch", MAIN_BRANCH);
+                    return Ok(());
+                }
+            }
+        }
         
-        if branch_name != "main" {
-            // Try to checkout main branch
-            let obj = self.repo.revparse_single("refs/heads/main")?;
-            self.repo.checkout_tree(&obj, None)?;
-            self.repo.set_head("refs/heads/main")?;
+        // git keeps to the sparse patterns and fetches what a partial clone
+        // is missing
+        if self.layout().needs_git() {
+            self.git_checked(&["checkout", "--quiet", MAIN_BRANCH], "switch to main")?;
+            return Ok(());
         }
+        let obj = self.repo.revparse_single(&main_ref)?;
+        self.repo.checkout_tree(&obj, None)?;
+        self.repo.set_head(&main_ref)?;
         
         Ok(())
     }
     
-}
\ No newline at end of file
+    // Tip of origin's copy of main, or of whatever origin/HEAD points to
+    fn remote_default_commit(&self) -> Result<Option<git2::Commit<'_>>> {
+        let candidates = [
+            format!("refs/remotes/origin/{}", MAIN_BRANCH),
+            "refs/remotes/origin/HEAD".to_string(),
+        ];
+        
+        for name in &candidates {
+            if let Ok(reference) = self.repo.find_reference(name) {
+                return Ok(Some(reference.peel_to_commit()?));
+            }
+        }
+        
+        Ok(None)
+    }
+    
+}
+
+// Day a commit was made on, in the timezone it was recorded with
+fn commit_day(time: Time) -> Option<NaiveDate> {
+    let offset = FixedOffset::east_opt(time.offset_minutes() * 60)?;
+    Some(DateTime::from_timestamp(time.seconds(), 0)?.with_timezone(&offset).date_naive())
+}
+
+// Addresses git makes up from the hostname, and reserved example/local
+// domains: mail to them can't be verified, so GitHub never links them
+fn is_placeholder_email(email: &str) -> bool {
+    const RESERVED_DOMAINS: &[&str] = &["example.com", "example.org", "example.net", "localdomain", "local", "lan", "home", "internal", "invalid", "test"];
+    
+    let Some((user, domain)) = email.rsplit_once('@') else {
+        return true;
+    };
+    let domain = domain.to_ascii_lowercase();
+    // A bare hostname such as "localhost" or "(none)"
+    user.is_empty()
+        || !domain.contains('.')
+        || RESERVED_DOMAINS.iter().any(|reserved| domain == *reserved || domain.ends_with(&format!(".{}", reserved)))
+}
+
+// Recognize push failures caused by missing connectivity rather than by
+// rejected refs or bad credentials, so they can be retried later. Only the
+// connection errors themselves: git's "unable to access" also comes before
+// HTTP 401/403 and certificate errors, which no retry fixes
+fn is_network_failure(stderr: &str) -> bool {
+    const NETWORK_ERRORS: &[&str] = &[
+        "Could not resolve host",
+        "Could not resolve hostname",
+        "Network is unreachable",
+        "Connection refused",
+        "Connection timed out",
+        "Operation timed out",
+        "Failed to connect",
+        "Connection reset",
+    ];
+
+    NETWORK_ERRORS.iter().any(|pattern| stderr.contains(pattern))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use chrono::TimeZone;
+    use crate::testing::{git, test_repo};
+
+    fn generated(git_ops: &mut GitOperations, day: u32) -> Oid {
+        let date = FixedOffset::east_opt(0).unwrap().with_ymd_and_hms(2024, 3, day, 12, 0, 0).unwrap();
+        git_ops.create_commit(&CommitInfo { date, message: format!("{} day {}", AUTOGEN_MARKER, day) }).unwrap()
+    }
+
+    fn messages(git_ops: &GitOperations) -> Vec<String> {
+        let mut revwalk = git_ops.repo().revwalk().unwrap();
+        revwalk.push_head().unwrap();
+        revwalk.set_sorting(git2::Sort::TOPOLOGICAL | git2::Sort::REVERSE).unwrap();
+        revwalk
+            .map(|oid| git_ops.repo().find_commit(oid.unwrap()).unwrap().summary().unwrap_or("").to_string())
+            .collect()
+    }
+
+    fn day(day: u32) -> NaiveDate {
+        NaiveDate::from_ymd_opt(2024, 3, day).unwrap()
+    }
+
+    #[test]
+    fn drop_autogen_commits_keeps_other_commits() {
+        let (dir, mut git_ops) = test_repo("drop-keep");
+        generated(&mut git_ops, 1);
+        git(&dir, &["commit", "-q", "--allow-empty", "-m", "Real work"]);
+        generated(&mut git_ops, 2);
+        generated(&mut git_ops, 5);
+        
+        assert_eq!(git_ops.drop_autogen_commits(day(1), day(3)).unwrap(), 2);
+        assert_eq!(
+            messages(&git_ops),
+            vec!["Initial commit".to_string(), "Real work".to_string(), format!("{} day 5", AUTOGEN_MARKER)]
+        );
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn drop_autogen_commits_outside_range_changes_nothing() {
+        let (dir, mut git_ops) = test_repo("drop-none");
+        let head = generated(&mut git_ops, 10);
+        
+        assert_eq!(git_ops.drop_autogen_commits(day(1), day(3)).unwrap(), 0);
+        assert_eq!(git_ops.head_commit(), Some(head));
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn drop_autogen_commits_refuses_to_empty_main() {
+        let (dir, mut git_ops) = test_repo("drop-all");
+        // Start main over so the generated commit is its only one
+        git(&dir, &["update-ref", "-d", "HEAD"]);
+        generated(&mut git_ops, 1);
+        let head = git_ops.head_commit();
+        
+        assert!(git_ops.drop_autogen_commits(day(1), day(1)).is_err());
+        assert_eq!(git_ops.head_commit(), head);
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn drop_autogen_commits_saves_a_backup_ref() {
+        let (dir, mut git_ops) = test_repo("drop-backup");
+        generated(&mut git_ops, 1);
+        let head = generated(&mut git_ops, 2);
+        
+        assert!(git_ops.backups().unwrap().is_empty());
+        assert_eq!(git_ops.drop_autogen_commits(day(2), day(2)).unwrap(), 1);
+        let backups = git_ops.backups().unwrap();
+        assert_eq!(backups.len(), 1);
+        assert!(backups[0].0.starts_with(BACKUP_REF_PREFIX));
+        assert_eq!(backups[0].1, head);
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn drop_autogen_commits_refuses_later_grid_changes() {
+        let (dir, mut git_ops) = test_repo("drop-grid");
+        generated(&mut git_ops, 1);
+        std::fs::create_dir_all(dir.join(GRID_DIR)).unwrap();
+        std::fs::write(dir.join(GRID_DIR).join("notes.md"), "by hand\n").unwrap();
+        git(&dir, &["add", GRID_DIR]);
+        git(&dir, &["commit", "-q", "-m", "Edit the grid by hand"]);
+        let head = git_ops.head_commit();
+        
+        assert!(git_ops.drop_autogen_commits(day(1), day(1)).is_err());
+        assert_eq!(git_ops.head_commit(), head);
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn drop_autogen_commits_takes_out_what_they_logged() {
+        let (dir, mut git_ops) = test_repo("drop-log");
+        git_ops.set_activity_log(Some("activity.log".to_string()));
+        generated(&mut git_ops, 1);
+        // Generated commits leave the index alone; pick up what they wrote
+        git(&dir, &["reset", "-q"]);
+        git(&dir, &["commit", "-q", "--allow-empty", "-m", "Real work"]);
+        generated(&mut git_ops, 2);
+        
+        // The second line builds on the first, so the first can't go alone
+        assert!(git_ops.drop_autogen_commits(day(1), day(1)).is_err());
+        assert_eq!(git_ops.drop_autogen_commits(day(2), day(2)).unwrap(), 1);
+        let head = git_ops.repo().head().unwrap().peel_to_commit().unwrap();
+        assert_eq!(head.summary(), Some("Real work"));
+        let files = git_ops.generated_files(&head.tree().unwrap()).unwrap();
+        assert_eq!(files.len(), 1);
+        let blob = git_ops.repo().find_blob(files[0].1).unwrap();
+        assert_eq!(String::from_utf8_lossy(blob.content()).lines().count(), 1);
+        let _ = std::fs::remove_dir_all(&dir);
+    }
+
+    #[test]
+    fn network_failures_are_recognized() {
+        assert!(is_network_failure("fatal: unable to access 'https://github.com/a/b.git/': Could not resolve host: github.com"));
+        assert!(is_network_failure("ssh: connect to host github.com port 22: Connection refused"));
+        assert!(is_network_failure("ssh: Could not resolve hostname github.com: Temporary failure in name resolution"));
+        assert!(is_network_failure("fatal: unable to access 'https://github.com/a/b.git/': Failed to connect to github.com port 443"));
+    }
+
+    #[test]
+    fn rejections_and_credential_errors_are_not_network_failures() {
+        assert!(!is_network_failure("fatal: unable to access 'https://github.com/a/b.git/': The requested URL returned error: 403"));
+        assert!(!is_network_failure("remote: Invalid username or password.\nfatal: Authentication failed for 'https://github.com/a/b.git/'"));
+        assert!(!is_network_failure(" ! [rejected]        main -> main (fetch first)"));
+        assert!(!is_network_failure("fatal: unable to access 'https://github.com/a/b.git/': SSL certificate problem: unable to get local issuer certificate"));
+        assert!(!is_network_failure(""));
+    }
+}
@@ -1,5 +1,24 @@
+use chrono::{DateTime, NaiveDate, Utc};
+use std::collections::BTreeMap;
 use std::process::Command;
 use crate::error::{GitHubGridError, Result};
+use crate::git_ops::MAIN_BRANCH;
+
+// Repository settings that decide whether its commits count on the graph
+pub struct RepoSettings {
+    pub private: bool,
+    pub fork: bool,
+    pub default_branch: String,
+    // "owner/name" of the repository a fork was made from
+    pub parent: Option<String>,
+}
+
+// An open issue, as listed by open_issues
+pub struct Issue {
+    pub number: u64,
+    pub title: String,
+    pub created: DateTime<Utc>,
+}
 
 pub struct GitHubClient {
     username: String,
@@ -63,6 +82,135 @@ impl GitHubClient {
         &self.username
     }
     
+    // Logins of the organizations the user is a member of
+    pub fn organizations(&self) -> Result<Vec<String>> {
+        let output = Command::new("gh")
+            .args(&["api", "user/orgs", "--paginate", "--jq", ".[].login"])
+            .output()
+            .map_err(|_| GitHubGridError::Network("Failed to list organizations".to_string()))?;
+            
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Network(format!("Failed to list organizations: {}", stderr.trim())));
+        }
+        
+        Ok(String::from_utf8_lossy(&output.stdout).lines().map(str::to_string).collect())
+    }
+    
+    // The account's ID+login@users.noreply.github.com address, which GitHub
+    // always attributes to it without exposing a real email
+    pub fn noreply_email(&self) -> Result<String> {
+        let output = Command::new("gh")
+            .args(&["api", "user", "--jq", "\"\\(.id)+\\(.login)@users.noreply.github.com\""])
+            .output()
+            .map_err(|_| GitHubGridError::Authentication("Failed to get GitHub user ID".to_string()))?;
+            
+        if !output.status.success() {
+            return Err(GitHubGridError::Authentication("Failed to get GitHub user ID".to_string()));
+        }
+        
+        Ok(String::from_utf8_lossy(&output.stdout).trim().to_string())
+    }
+    
+    // Open a pull request into main and return its number
+    pub fn open_pull_request(&self, repo: &str, head: &str, title: &str, body: &str) -> Result<u64> {
+        let output = Command::new("gh")
+            .args(&["pr", "create", "--repo", repo, "--base", MAIN_BRANCH, "--head", head, "--title", title, "--body", body])
+            .output()
+            .map_err(|_| GitHubGridError::Network("Failed to open a pull request".to_string()))?;
+            
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Network(format!("Failed to open a pull request: {}", stderr.trim())));
+        }
+        
+        // gh prints the new pull request's URL, ending in its number
+        let url = String::from_utf8_lossy(&output.stdout);
+        url.trim().rsplit('/').next()
+            .and_then(|number| number.parse().ok())
+            .ok_or_else(|| GitHubGridError::Parse(format!("Unexpected output from gh pr create: {}", url.trim())))
+    }
+    
+    // Squash-merge a pull request with the given commit subject and delete
+    // its branch on GitHub
+    pub fn merge_pull_request(&self, repo: &str, number: u64, subject: &str) -> Result<()> {
+        let output = Command::new("gh")
+            .args(&["pr", "merge", &number.to_string(), "--repo", repo, "--squash", "--delete-branch", "--subject", subject])
+            .output()
+            .map_err(|_| GitHubGridError::Network("Failed to merge the pull request".to_string()))?;
+            
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Network(format!("Failed to merge pull request #{}: {}", number, stderr.trim())));
+        }
+        Ok(())
+    }
+    
+    // Open an issue and return its number
+    pub fn open_issue(&self, repo: &str, title: &str, body: &str) -> Result<u64> {
+        let output = Command::new("gh")
+            .args(&["issue", "create", "--repo", repo, "--title", title, "--body", body])
+            .output()
+            .map_err(|_| GitHubGridError::Network("Failed to open an issue".to_string()))?;
+            
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Network(format!("Failed to open an issue: {}", stderr.trim())));
+        }
+        
+        // gh prints the new issue's URL, ending in its number
+        let url = String::from_utf8_lossy(&output.stdout);
+        url.trim().rsplit('/').next()
+            .and_then(|number| number.parse().ok())
+            .ok_or_else(|| GitHubGridError::Parse(format!("Unexpected output from gh issue create: {}", url.trim())))
+    }
+    
+    // Open issues the user opened whose body contains `marker`, oldest first
+    pub fn open_issues(&self, repo: &str, marker: &str) -> Result<Vec<Issue>> {
+        let filter = format!(
+            ".[] | select(.body | contains({})) | \"\\(.number) \\(.createdAt) \\(.title)\"",
+            serde_json::to_string(marker).unwrap_or_default()
+        );
+        let output = Command::new("gh")
+            .args(&[
+                "issue", "list", "--repo", repo, "--author", "@me", "--state", "open", "--limit", "100",
+                "--json", "number,createdAt,title,body", "--jq", &filter,
+            ])
+            .output()
+            .map_err(|_| GitHubGridError::Network("Failed to list issues".to_string()))?;
+            
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Network(format!("Failed to list issues: {}", stderr.trim())));
+        }
+        
+        let mut issues = Vec::new();
+        for line in String::from_utf8_lossy(&output.stdout).lines() {
+            let mut parts = line.splitn(3, ' ');
+            let number = parts.next().and_then(|number| number.parse().ok());
+            let created = parts.next().and_then(|created| created.parse().ok());
+            if let (Some(number), Some(created)) = (number, created) {
+                issues.push(Issue { number, created, title: parts.next().unwrap_or("").to_string() });
+            }
+        }
+        issues.sort_by_key(|issue| issue.created);
+        Ok(issues)
+    }
+    
+    // Close an issue as completed with a comment
+    pub fn close_issue(&self, repo: &str, number: u64, comment: &str) -> Result<()> {
+        let output = Command::new("gh")
+            .args(&["issue", "close", &number.to_string(), "--repo", repo, "--reason", "completed", "--comment", comment])
+            .output()
+            .map_err(|_| GitHubGridError::Network("Failed to close the issue".to_string()))?;
+            
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Network(format!("Failed to close issue #{}: {}", number, stderr.trim())));
+        }
+        Ok(())
+    }
+    
     pub fn repo_exists(&self, repo_name: &str) -> Result<bool> {
         // Temporarily set git protocol to https for token auth if needed
         let original_protocol = Self::get_git_protocol().unwrap_or_else(|_| "ssh".to_string());
@@ -142,6 +290,142 @@ impl GitHubClient {
         Ok(())
     }
     
+    // Login GitHub attributes a pushed commit to, or None when its author
+    // email isn't linked to any account. Freshly pushed commits can take a
+    // moment to show up in the API, so a miss is retried a few times
+    pub fn commit_author(&self, repo: &str, sha: &str) -> Result<Option<String>> {
+        let mut attempts = 0;
+        loop {
+            let output = Command::new("gh")
+                .args(&["api", &format!("repos/{}/commits/{}", repo, sha), "--jq", ".author.login // \"\""])
+                .output()
+                .map_err(|_| GitHubGridError::Network("Failed to look up commit".to_string()))?;
+                
+            if output.status.success() {
+                let login = String::from_utf8_lossy(&output.stdout).trim().to_string();
+                return Ok(Some(login).filter(|login| !login.is_empty()));
+            }
+            
+            attempts += 1;
+            if attempts == 5 {
+                let stderr = String::from_utf8_lossy(&output.stderr);
+                return Err(GitHubGridError::Network(
+                    format!("Failed to look up commit {}: {}", sha, stderr.trim())
+                ));
+            }
+            std::thread::sleep(std::time::Duration::from_secs(2));
+        }
+    }
+    
+    pub fn repo_settings(&self, repo: &str) -> Result<RepoSettings> {
+        let output = Command::new("gh")
+            .args(&["api", &format!("repos/{}", repo), "--jq", "\"\\(.private) \\(.fork) \\(.default_branch) \\(.parent.full_name // \"\")\""])
+            .output()
+            .map_err(|_| GitHubGridError::Network("Failed to look up repository".to_string()))?;
+            
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Network(
+                format!("Failed to look up repository {}: {}", repo, stderr.trim())
+            ));
+        }
+        
+        let stdout = String::from_utf8_lossy(&output.stdout);
+        let fields: Vec<_> = stdout.trim().split(' ').collect();
+        match fields[..] {
+            [private, fork, branch] | [private, fork, branch, _] => Ok(RepoSettings {
+                private: private == "true",
+                fork: fork == "true",
+                default_branch: branch.to_string(),
+                parent: fields.get(3).map(|parent| parent.to_string()),
+            }),
+            _ => Err(GitHubGridError::Parse(format!("Unexpected repository details: {}", stdout.trim()))),
+        }
+    }
+    
+    // Turn a repository private; its commits keep counting for users who
+    // show private contributions on their profile
+    pub fn make_private(&self, repo: &str) -> Result<()> {
+        let output = Command::new("gh")
+            .args(&["repo", "edit", repo, "--visibility", "private", "--accept-visibility-change-consequences"])
+            .output()
+            .map_err(|_| GitHubGridError::Repository("Failed to change repository visibility".to_string()))?;
+            
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Repository(
+                format!("Failed to make {} private: {}", repo, stderr.trim())
+            ));
+        }
+        
+        Ok(())
+    }
+    
+    pub fn set_default_branch(&self, repo: &str, branch: &str) -> Result<()> {
+        let output = Command::new("gh")
+            .args(&["repo", "edit", repo, "--default-branch", branch])
+            .output()
+            .map_err(|_| GitHubGridError::Repository("Failed to change the default branch".to_string()))?;
+            
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Repository(
+                format!("Failed to make {} the default branch of {}: {}", branch, repo, stderr.trim())
+            ));
+        }
+        
+        Ok(())
+    }
+    
+    // Contributions per day shown on the user's graph between two dates, as
+    // GitHub counts them (all repositories, in the account's timezone)
+    pub fn contribution_calendar(&self, from: NaiveDate, to: NaiveDate) -> Result<BTreeMap<NaiveDate, u32>> {
+        // GitHub answers for at most a year at a time
+        let mut days = BTreeMap::new();
+        let mut chunk_start = from;
+        while chunk_start <= to {
+            let chunk_end = (chunk_start + chrono::Duration::days(364)).min(to);
+            days.extend(self.contribution_calendar_chunk(chunk_start, chunk_end)?);
+            chunk_start = chunk_end + chrono::Duration::days(1);
+        }
+        Ok(days)
+    }
+    
+    fn contribution_calendar_chunk(&self, from: NaiveDate, to: NaiveDate) -> Result<BTreeMap<NaiveDate, u32>> {
+        let query = "query($login: String!, $from: DateTime!, $to: DateTime!) { \
+            user(login: $login) { contributionsCollection(from: $from, to: $to) { \
+            contributionCalendar { weeks { contributionDays { date contributionCount } } } } } }";
+        let output = Command::new("gh")
+            .args(&[
+                "api", "graphql",
+                "-f", &format!("query={}", query),
+                "-f", &format!("login={}", self.username),
+                "-f", &format!("from={}T00:00:00Z", from),
+                "-f", &format!("to={}T23:59:59Z", to),
+                "--jq", ".data.user.contributionsCollection.contributionCalendar.weeks[].contributionDays[] | \"\\(.date) \\(.contributionCount)\"",
+            ])
+            .output()
+            .map_err(|_| GitHubGridError::Network("Failed to fetch contribution calendar".to_string()))?;
+            
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(GitHubGridError::Network(
+                format!("Failed to fetch contribution calendar: {}", stderr.trim())
+            ));
+        }
+        
+        let mut days = BTreeMap::new();
+        for line in String::from_utf8_lossy(&output.stdout).lines() {
+            let parsed = line.split_once(' ').and_then(|(date, count)| {
+                Some((NaiveDate::parse_from_str(date, "%Y-%m-%d").ok()?, count.parse().ok()?))
+            });
+            if let Some((date, count)) = parsed {
+                days.insert(date, count);
+            }
+        }
+        Ok(days)
+    }
+    
     fn get_git_protocol() -> Result<String> {
         let output = Command::new("gh")
             .args(&["config", "get", "git_protocol"])
@@ -0,0 +1,143 @@
+use chrono::{Datelike, NaiveDate, Weekday};
+use std::collections::BTreeMap;
+use crate::design::Design;
+use crate::error::Result;
+use crate::github::GitHubClient;
+use crate::grid::{self, Grid};
+use crate::output::{self, yellow};
+use crate::patterns::{CommitInfo, PatternConfig};
+use crate::plan::{PlanOptions, Planner, Strategy};
+use crate::status;
+
+// `preview`: a pattern's plan for a range, drawn as the graph for designs
+// and with --overlay, else as a calendar, and summed up
+pub fn preview(pattern_name: &str, start: NaiveDate, end: NaiveDate, seed: Option<u64>, background: Option<f64>, overlay: bool) -> Result<()> {
+    let plan = Planner::new(PlanOptions {
+        seed,
+        design_background: background,
+        ..PlanOptions::new(start, end, Strategy::Pattern(pattern_name.to_string()))
+    }).build()?;
+    
+    println!("Seed: {}", plan.seed);
+    
+    // Designs read as pictures only in the graph's own layout
+    if overlay {
+        let grid = Grid::rolling(end);
+        let github = GitHubClient::new()?;
+        status!("📥 Fetching the contribution graph of {}", github.username());
+        let existing = github.contribution_calendar(grid.start(), grid.end())?;
+        show_graph(&plan.commits, end, &existing);
+    } else if Design::named(pattern_name)?.is_some() {
+        show_graph(&plan.commits, end, &BTreeMap::new());
+    } else {
+        show_calendar(&plan.commits, start, end);
+    }
+    show_summary(&plan.per_day(), &PatternConfig::default().weekend);
+    
+    Ok(())
+}
+
+// Commits per day, a row per week starting on Monday
+pub fn show_calendar(commits: &[CommitInfo], start: NaiveDate, end: NaiveDate) {
+    println!("\n📅 Commit Calendar:");
+    
+    let mut current = start;
+    while current <= end {
+        let count = commits.iter()
+            .filter(|c| c.date.date_naive() == current)
+            .count();
+            
+        if current.weekday().number_from_monday() == 1 {
+            println!();
+            print!("{:>10} ", current.format("%b %d"));
+        }
+        
+        print!("{}", output::calendar_cell(count));
+        current = current.succ_opt().unwrap();
+    }
+    println!("\n\nLegend: {}=0 {}=1-3 {}=4-10 {}=10+ commits\n",
+        output::calendar_cell(0), output::calendar_cell(1), output::calendar_cell(4), output::calendar_cell(11));
+}
+
+// The graph as GitHub shows it on `end`: a column per week, Sunday on top,
+// shaded relative to the busiest day. `existing` contributions are added on
+// top, and days they fill that the plan leaves empty are marked
+pub fn show_graph(commits: &[CommitInfo], end: NaiveDate, existing: &BTreeMap<NaiveDate, u32>) {
+    println!("\n📅 Contribution Graph:\n");
+    
+    let grid = Grid::rolling(end);
+    let mut planned = vec![0usize; (grid.columns * grid::ROWS) as usize];
+    for commit in commits {
+        if let Some((column, row)) = grid.cell(commit.date.date_naive()) {
+            planned[(column * grid::ROWS + row) as usize] += 1;
+        }
+    }
+    let total = |column: u32, row: u32| {
+        let date = grid.date(column, row);
+        let existing = date.and_then(|date| existing.get(&date)).copied().unwrap_or(0) as usize;
+        (planned[(column * grid::ROWS + row) as usize], existing)
+    };
+    let busiest = (0..grid.columns)
+        .flat_map(|column| (0..grid::ROWS).map(move |row| (column, row)))
+        .map(|(column, row)| total(column, row))
+        .map(|(planned, existing)| planned + existing)
+        .max()
+        .unwrap_or(0)
+        .max(1);
+    
+    let mut conflicts = 0;
+    for (row, label) in ["Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"].iter().enumerate() {
+        print!("  {} ", label);
+        for column in 0..grid.columns {
+            let cell = match grid.date(column, row as u32) {
+                Some(_) => match total(column, row as u32) {
+                    (0, existing) if existing > 0 => {
+                        conflicts += 1;
+                        output::conflict_cell()
+                    }
+                    (planned, existing) => output::graph_cell(((planned + existing) * 4).div_ceil(busiest) as u8),
+                },
+                None => " ".to_string(),
+            };
+            print!("{}", cell);
+        }
+        println!();
+    }
+    println!("\nLegend: {} none, {} {} {} {} less to more{}\n",
+        output::graph_cell(0), output::graph_cell(1), output::graph_cell(2), output::graph_cell(3), output::graph_cell(4),
+        if existing.is_empty() { String::new() } else { format!(", {} existing activity on a day left empty", output::conflict_cell()) });
+    if conflicts > 0 {
+        println!("{}", yellow(format!("⚠️  {} days the plan leaves empty already have contributions", conflicts)));
+    }
+}
+
+// Totals, weekend share and commits per year
+pub fn show_summary(per_day: &BTreeMap<NaiveDate, usize>, weekend: &[Weekday]) {
+    let total: usize = per_day.values().sum();
+    let avg_per_day = per_day.len();
+    
+    println!("Summary:");
+    println!("  Total commits: {}", total);
+    println!("  Active days: {}", avg_per_day);
+    if avg_per_day > 0 {
+        println!("  Avg commits/day: {:.1}", total as f64 / avg_per_day as f64);
+    }
+    
+    let weekend_commits: usize = per_day.iter()
+        .filter(|(day, _)| weekend.contains(&day.weekday()))
+        .map(|(_, count)| count)
+        .sum();
+    
+    println!("  Weekend commits: {} ({:.1}%)", weekend_commits, 
+             weekend_commits as f64 / total as f64 * 100.0);
+    
+    let mut years: BTreeMap<i32, usize> = BTreeMap::new();
+    for (day, count) in per_day {
+        *years.entry(day.year()).or_insert(0) += count;
+    }
+    if years.len() > 1 {
+        for (year, count) in years {
+            println!("  {}: {} commits", year, count);
+        }
+    }
+}
@@ -0,0 +1,72 @@
+use chrono::{Datelike, NaiveDate, Weekday};
+use std::ops::Range;
+
+// The contribution graph as cells: one column per week, Sunday to Saturday
+// from top to bottom like GitHub's graph. Column 0 is the week the range
+// starts in; the first and last columns are partial when the range starts
+// after a Sunday or ends before a Saturday, and those cells map to no date
+#[derive(Debug, Clone, Copy)]
+pub struct Grid {
+    start: NaiveDate,
+    end: NaiveDate,
+    // Sunday of column 0
+    origin: NaiveDate,
+    pub columns: u32,
+}
+
+pub const ROWS: u32 = 7;
+
+impl Grid {
+    pub fn new(start: NaiveDate, end: NaiveDate) -> Self {
+        let origin = start - chrono::Duration::days(start.weekday().num_days_from_sunday() as i64);
+        let columns = ((end - origin).num_days() / 7 + 1) as u32;
+        Self { start, end, origin, columns }
+    }
+
+    // The graph on a GitHub profile as of `today`: the year up to and
+    // including today, starting on the same date a year earlier. That is 53
+    // columns (54 in some years), with today in the last, partial one
+    pub fn rolling(today: NaiveDate) -> Self {
+        let year_ago = today
+            .with_year(today.year() - 1)
+            .unwrap_or_else(|| today - chrono::Duration::days(365));
+        Self::new(year_ago, today)
+    }
+
+    pub fn start(&self) -> NaiveDate {
+        self.start
+    }
+
+    pub fn end(&self) -> NaiveDate {
+        self.end
+    }
+
+    // Columns with all seven days in the range
+    pub fn full_columns(&self) -> Range<u32> {
+        let first = if self.start == self.origin { 0 } else { 1 };
+        let last = if self.end.weekday() == Weekday::Sat { self.columns } else { self.columns - 1 };
+        first..last.max(first)
+    }
+
+    // (column, row) of a date in the range; row 0 is Sunday
+    pub fn cell(&self, date: NaiveDate) -> Option<(u32, u32)> {
+        if date < self.start || date > self.end {
+            return None;
+        }
+        let days = (date - self.origin).num_days() as u32;
+        Some((days / 7, days % 7))
+    }
+
+    // Date shown in a cell, or None for cells outside the range
+    pub fn date(&self, column: u32, row: u32) -> Option<NaiveDate> {
+        if row >= ROWS {
+            return None;
+        }
+        let date = self.origin + chrono::Duration::days((column * 7 + row) as i64);
+        (self.start <= date && date <= self.end).then_some(date)
+    }
+
+    pub fn days(&self) -> impl Iterator<Item = NaiveDate> + '_ {
+        self.start.iter_days().take_while(|date| *date <= self.end)
+    }
+}
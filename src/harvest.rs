@@ -0,0 +1,162 @@
+use std::collections::HashMap;
+use std::env;
+use std::fs;
+use std::path::Path;
+use std::process;
+use std::time::Duration;
+use crate::cancel::CancellationToken;
+use crate::error::{GitHubGridError, Result};
+use crate::exec::{credentials_error, git_command, run_command};
+use crate::messages::{AUTOGEN_MARKER, MessagePool};
+use crate::status;
+
+// Longest subject kept; longer ones read like bodies that lost their newline
+const MAX_SUBJECT_LEN: usize = 72;
+
+// CI directives that would change how the grid repository's workflows run
+const CI_MARKERS: &[&str] = &["[skip ci]", "[ci skip]", "[no ci]", "[skip actions]", "[actions skip]"];
+
+// Subjects that say nothing about how their author writes
+const SKIPPED_PREFIXES: &[&str] = &["Merge ", "Revert \"", "fixup!", "squash!", "amend!", "Initial commit"];
+
+#[derive(Debug, Clone)]
+pub struct HarvestOptions {
+    // Most recent commits read from the source
+    pub limit: usize,
+    // Only commits whose author matches, as in `git log --author`
+    pub author: Option<String>,
+    pub timeout: Option<Duration>,
+}
+
+// Build a message pool from the commit subjects of a local repository or a
+// clonable URL. Subjects are cleaned of issue references, hashes, links and
+// CI markers; each distinct subject is weighted by how often it was used
+pub fn harvest(source: &str, options: &HarvestOptions, cancel: &CancellationToken) -> Result<MessagePool> {
+    let subjects = if Path::new(source).exists() {
+        read_subjects(Path::new(source), options, cancel)?
+    } else {
+        let clone_dir = env::temp_dir().join(format!("github-grid-harvest-{}", process::id()));
+        let result = clone_metadata(source, &clone_dir, options, cancel)
+            .and_then(|()| read_subjects(&clone_dir, options, cancel));
+        let _ = fs::remove_dir_all(&clone_dir);
+        result?
+    };
+
+    let mut counts: HashMap<String, u32> = HashMap::new();
+    let mut order = Vec::new();
+    for subject in subjects.iter().filter_map(|subject| sanitize(subject)) {
+        let count = counts.entry(subject.clone()).or_insert(0);
+        if *count == 0 {
+            order.push(subject);
+        }
+        *count += 1;
+    }
+
+    status!("🧺 Read {} commit subjects, kept {} distinct messages", subjects.len(), order.len());
+    if order.is_empty() {
+        return Err(GitHubGridError::Repository(format!("No usable commit subjects found in {}", source)));
+    }
+
+    MessagePool::new(order.into_iter().map(|subject| {
+        let weight = counts[&subject];
+        (subject, weight)
+    }).collect())
+}
+
+// Commits and trees only, no file contents: enough for `git log`
+fn clone_metadata(url: &str, dir: &Path, options: &HarvestOptions, cancel: &CancellationToken) -> Result<()> {
+    status!("📥 Fetching history of {}", url);
+    let mut cmd = git_command();
+    cmd.args(["clone", "--bare", "--quiet", "--filter=blob:none", "--single-branch"])
+        .arg(format!("--depth={}", options.limit.max(1)))
+        .arg(url)
+        .arg(dir);
+
+    let output = run_command(cmd, options.timeout, cancel)?;
+    if !output.status.success() {
+        let stderr = String::from_utf8_lossy(&output.stderr);
+        if let Some(e) = credentials_error(&stderr) {
+            return Err(e);
+        }
+        return Err(GitHubGridError::Network(format!(
+            "Could not clone {}: {}",
+            url,
+            stderr.trim()
+        )));
+    }
+    Ok(())
+}
+
+fn read_subjects(repo: &Path, options: &HarvestOptions, cancel: &CancellationToken) -> Result<Vec<String>> {
+    let mut cmd = git_command();
+    cmd.arg("-C").arg(repo)
+        .args(["log", "--no-merges", "--format=%s"])
+        .arg(format!("--max-count={}", options.limit));
+    if let Some(author) = &options.author {
+        cmd.arg(format!("--author={}", author));
+    }
+
+    let output = run_command(cmd, options.timeout, cancel)?;
+    if !output.status.success() {
+        return Err(GitHubGridError::Repository(format!(
+            "Could not read history of {}: {}",
+            repo.display(),
+            String::from_utf8_lossy(&output.stderr).trim()
+        )));
+    }
+    Ok(String::from_utf8_lossy(&output.stdout).lines().map(str::to_string).collect())
+}
+
+// Strip what would tie a message to its original repository, or None when
+// nothing worth reusing is left
+fn sanitize(subject: &str) -> Option<String> {
+    let subject = subject.trim();
+    if subject.starts_with(AUTOGEN_MARKER) || SKIPPED_PREFIXES.iter().any(|prefix| subject.starts_with(prefix)) {
+        return None;
+    }
+    // Dependency bot updates ("Bump serde from 1.0.1 to 1.0.2")
+    if subject.starts_with("Bump ") && subject.contains(" from ") {
+        return None;
+    }
+
+    let mut cleaned = subject.to_string();
+    for marker in CI_MARKERS {
+        cleaned = cleaned.replace(marker, "");
+    }
+
+    let words: Vec<&str> = cleaned
+        .split_whitespace()
+        .filter(|word| !is_reference(word))
+        .collect();
+    let cleaned = words
+        .join(" ")
+        .chars()
+        .filter(|c| !c.is_control())
+        .collect::<String>()
+        .trim_end_matches(['.', ',', ';', ':', '-', ' '])
+        .to_string();
+
+    let length = cleaned.chars().count();
+    if length < 3 || length > MAX_SUBJECT_LEN || !cleaned.chars().any(char::is_alphabetic) {
+        return None;
+    }
+    Some(cleaned)
+}
+
+// Issue/PR numbers, commit hashes, links and email addresses
+fn is_reference(word: &str) -> bool {
+    let bare = word.trim_matches(|c: char| "()[],.:;".contains(c));
+    let is_number_ref = |prefix: &str| {
+        bare.strip_prefix(prefix).is_some_and(|rest| !rest.is_empty() && rest.chars().all(|c| c.is_ascii_digit()))
+    };
+    let is_hash = bare.len() >= 7
+        && bare.chars().all(|c| c.is_ascii_hexdigit())
+        && bare.chars().any(|c| c.is_ascii_digit());
+
+    is_number_ref("#")
+        || is_number_ref("GH-")
+        || is_hash
+        || bare.contains("://")
+        || (bare.contains('@') && bare.contains('.'))
+        || (bare.contains('/') && bare.contains('#'))
+}
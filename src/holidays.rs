@@ -0,0 +1,265 @@
+use chrono::{Datelike, NaiveDate, Weekday};
+use std::collections::BTreeSet;
+use std::fs;
+use std::path::Path;
+use crate::error::{GitHubGridError, Result};
+
+// How a holiday's date is found in a given year
+#[derive(Debug, Clone, Copy)]
+enum Rule {
+    // Same month/day every year
+    Fixed(u32, u32),
+    // Days from Easter Sunday (Good Friday is -2, Easter Monday 1)
+    Easter(i64),
+    // Nth weekday of a month; -1 is the last one
+    Nth(u32, Weekday, i32),
+    // Last such weekday strictly before month/day (Victoria Day: Monday before May 25)
+    WeekdayBefore(u32, u32, Weekday),
+    VernalEquinox,
+    AutumnalEquinox,
+}
+
+use Rule::*;
+
+#[derive(Debug)]
+struct Country {
+    code: &'static str,
+    name: &'static str,
+    rules: &'static [Rule],
+}
+
+// Nationwide public holidays only; regional days and holidays that follow
+// lunar calendars are left to the additions file
+const COUNTRIES: &[Country] = &[
+    Country { code: "au", name: "Australia", rules: &[
+        Fixed(1, 1), Fixed(1, 26), Easter(-2), Easter(-1), Easter(1), Fixed(4, 25), Fixed(12, 25), Fixed(12, 26),
+    ] },
+    Country { code: "br", name: "Brazil", rules: &[
+        Fixed(1, 1), Easter(-48), Easter(-47), Easter(-2), Fixed(4, 21), Fixed(5, 1), Easter(60), Fixed(9, 7),
+        Fixed(10, 12), Fixed(11, 2), Fixed(11, 15), Fixed(11, 20), Fixed(12, 25),
+    ] },
+    Country { code: "ca", name: "Canada", rules: &[
+        Fixed(1, 1), Easter(-2), WeekdayBefore(5, 25, Weekday::Mon), Fixed(7, 1), Nth(9, Weekday::Mon, 1),
+        Fixed(9, 30), Nth(10, Weekday::Mon, 2), Fixed(11, 11), Fixed(12, 25), Fixed(12, 26),
+    ] },
+    Country { code: "de", name: "Germany", rules: &[
+        Fixed(1, 1), Easter(-2), Easter(1), Fixed(5, 1), Easter(39), Easter(50), Fixed(10, 3), Fixed(12, 25),
+        Fixed(12, 26),
+    ] },
+    Country { code: "es", name: "Spain", rules: &[
+        Fixed(1, 1), Fixed(1, 6), Easter(-2), Fixed(5, 1), Fixed(8, 15), Fixed(10, 12), Fixed(11, 1), Fixed(12, 6),
+        Fixed(12, 8), Fixed(12, 25),
+    ] },
+    Country { code: "fr", name: "France", rules: &[
+        Fixed(1, 1), Easter(1), Fixed(5, 1), Fixed(5, 8), Easter(39), Easter(50), Fixed(7, 14), Fixed(8, 15),
+        Fixed(11, 1), Fixed(11, 11), Fixed(12, 25),
+    ] },
+    Country { code: "gb", name: "United Kingdom (England and Wales)", rules: &[
+        Fixed(1, 1), Easter(-2), Easter(1), Nth(5, Weekday::Mon, 1), Nth(5, Weekday::Mon, -1),
+        Nth(8, Weekday::Mon, -1), Fixed(12, 25), Fixed(12, 26),
+    ] },
+    Country { code: "in", name: "India", rules: &[
+        Fixed(1, 26), Fixed(8, 15), Fixed(10, 2),
+    ] },
+    Country { code: "it", name: "Italy", rules: &[
+        Fixed(1, 1), Fixed(1, 6), Easter(1), Fixed(4, 25), Fixed(5, 1), Fixed(6, 2), Fixed(8, 15), Fixed(11, 1),
+        Fixed(12, 8), Fixed(12, 25), Fixed(12, 26),
+    ] },
+    Country { code: "jp", name: "Japan", rules: &[
+        Fixed(1, 1), Fixed(1, 2), Fixed(1, 3), Nth(1, Weekday::Mon, 2), Fixed(2, 11), Fixed(2, 23), VernalEquinox,
+        Fixed(4, 29), Fixed(5, 3), Fixed(5, 4), Fixed(5, 5), Nth(7, Weekday::Mon, 3), Fixed(8, 11),
+        Nth(9, Weekday::Mon, 3), AutumnalEquinox, Nth(10, Weekday::Mon, 2), Fixed(11, 3), Fixed(11, 23),
+    ] },
+    Country { code: "nl", name: "Netherlands", rules: &[
+        Fixed(1, 1), Easter(1), Fixed(4, 27), Fixed(5, 5), Easter(39), Easter(50), Fixed(12, 25), Fixed(12, 26),
+    ] },
+    Country { code: "us", name: "United States", rules: &[
+        Fixed(1, 1), Nth(1, Weekday::Mon, 3), Nth(2, Weekday::Mon, 3), Nth(5, Weekday::Mon, -1), Fixed(6, 19),
+        Fixed(7, 4), Nth(9, Weekday::Mon, 1), Nth(10, Weekday::Mon, 2), Fixed(11, 11), Nth(11, Weekday::Thu, 4),
+        Fixed(12, 25),
+    ] },
+];
+
+// Country codes and names of the bundled holiday sets
+pub fn countries() -> impl Iterator<Item = (&'static str, &'static str)> {
+    COUNTRIES.iter().map(|country| (country.code, country.name))
+}
+
+#[derive(Debug, Clone, Copy, Default)]
+enum Builtin {
+    #[default]
+    Default,
+    None,
+    Country(&'static Country),
+}
+
+// Days commits get much less likely on. Without a country this is the
+// original built-in calendar (winter break, US long weekends); with one it is
+// that country's public holidays. Additions from a file apply either way
+#[derive(Debug, Clone, Default)]
+pub struct HolidayCalendar {
+    builtin: Builtin,
+    // Specific dates, e.g. a week off in 2025
+    dates: BTreeSet<NaiveDate>,
+    // Month/day pairs repeated every year
+    annual: BTreeSet<(u32, u32)>,
+}
+
+impl HolidayCalendar {
+    // `country` is a code such as "de", or "none" for additions only
+    pub fn new(country: Option<&str>) -> Result<Self> {
+        let builtin = match country.map(str::to_ascii_lowercase) {
+            None => Builtin::Default,
+            Some(code) if code == "none" => Builtin::None,
+            Some(code) => Builtin::Country(COUNTRIES.iter().find(|country| country.code == code).ok_or_else(|| {
+                let known: Vec<&str> = COUNTRIES.iter().map(|country| country.code).collect();
+                GitHubGridError::Config(format!("Unknown holiday country '{}' (available: none, {})", code, known.join(", ")))
+            })?),
+        };
+        Ok(Self { builtin, ..Self::default() })
+    }
+
+    // Merge days from a file, one per line: 2025-08-18 for a single day,
+    // 2025-08-18..2025-08-29 for a range, 12-24 for every year. Blank lines
+    // and text after # are ignored
+    pub fn add_file(&mut self, path: &Path) -> Result<()> {
+        let content = fs::read_to_string(path).map_err(|e| {
+            GitHubGridError::Config(format!("Cannot read holidays file {}: {}", path.display(), e))
+        })?;
+
+        for (number, line) in content.lines().enumerate() {
+            let entry = line.split('#').next().unwrap_or_default().trim();
+            if entry.is_empty() {
+                continue;
+            }
+            self.add_entry(entry).ok_or_else(|| {
+                GitHubGridError::Config(format!("{}:{}: invalid holiday '{}'", path.display(), number + 1, entry))
+            })?;
+        }
+        Ok(())
+    }
+
+    fn add_entry(&mut self, entry: &str) -> Option<()> {
+        if let Some((start, end)) = entry.split_once("..") {
+            let start = NaiveDate::parse_from_str(start.trim(), "%Y-%m-%d").ok()?;
+            let end = NaiveDate::parse_from_str(end.trim(), "%Y-%m-%d").ok()?;
+            if start > end {
+                return None;
+            }
+            self.dates.extend(start.iter_days().take_while(|date| *date <= end));
+        } else if let Ok(date) = NaiveDate::parse_from_str(entry, "%Y-%m-%d") {
+            self.dates.insert(date);
+        } else {
+            let (month, day) = entry.split_once('-')?;
+            let (month, day) = (month.parse().ok()?, day.parse().ok()?);
+            // Validate against a leap year so 02-29 is accepted
+            NaiveDate::from_ymd_opt(2024, month, day)?;
+            self.annual.insert((month, day));
+        }
+        Some(())
+    }
+
+    pub fn is_holiday(&self, date: NaiveDate) -> bool {
+        if self.dates.contains(&date) || self.annual.contains(&(date.month(), date.day())) {
+            return true;
+        }
+        match self.builtin {
+            Builtin::Default => is_default_holiday(date),
+            Builtin::None => false,
+            Builtin::Country(country) => country.rules.iter().any(|rule| rule.matches(date)),
+        }
+    }
+}
+
+impl Rule {
+    fn matches(&self, date: NaiveDate) -> bool {
+        let year = date.year();
+        match *self {
+            Fixed(month, day) => date.month() == month && date.day() == day,
+            Easter(offset) => easter_sunday(year).is_some_and(|easter| date == easter + chrono::Duration::days(offset)),
+            Nth(month, weekday, n) => {
+                let found = if n > 0 {
+                    NaiveDate::from_weekday_of_month_opt(year, month, weekday, n as u8)
+                } else {
+                    last_weekday_of_month(year, month, weekday)
+                };
+                found == Some(date)
+            }
+            WeekdayBefore(month, day, weekday) => {
+                let Some(limit) = NaiveDate::from_ymd_opt(year, month, day) else { return false };
+                let back = (limit.weekday().num_days_from_monday() + 7 - weekday.num_days_from_monday() - 1) % 7 + 1;
+                date == limit - chrono::Duration::days(back as i64)
+            }
+            VernalEquinox => date.month() == 3 && date.day() == equinox_day(year, 20.8431),
+            AutumnalEquinox => date.month() == 9 && date.day() == equinox_day(year, 23.2488),
+        }
+    }
+}
+
+// Anonymous Gregorian algorithm (Meeus/Jones/Butcher)
+fn easter_sunday(year: i32) -> Option<NaiveDate> {
+    let a = year % 19;
+    let b = year / 100;
+    let c = year % 100;
+    let d = b / 4;
+    let e = b % 4;
+    let f = (b + 8) / 25;
+    let g = (b - f + 1) / 3;
+    let h = (19 * a + b - d - g + 15) % 30;
+    let i = c / 4;
+    let k = c % 4;
+    let l = (32 + 2 * e + 2 * i - h - k) % 7;
+    let m = (a + 11 * h + 22 * l) / 451;
+    let month = (h + l - 7 * m + 114) / 31;
+    let day = (h + l - 7 * m + 114) % 31 + 1;
+    NaiveDate::from_ymd_opt(year, month as u32, day as u32)
+}
+
+fn last_weekday_of_month(year: i32, month: u32, weekday: Weekday) -> Option<NaiveDate> {
+    let next_month = if month == 12 {
+        NaiveDate::from_ymd_opt(year + 1, 1, 1)?
+    } else {
+        NaiveDate::from_ymd_opt(year, month + 1, 1)?
+    };
+    let last = next_month.pred_opt()?;
+    let back = (last.weekday().num_days_from_monday() + 7 - weekday.num_days_from_monday()) % 7;
+    Some(last - chrono::Duration::days(back as i64))
+}
+
+// Day of the month of a Japanese equinox holiday; the approximation used for
+// the official calendar, valid 1980-2099
+fn equinox_day(year: i32, base: f64) -> u32 {
+    let years = (year - 1980) as f64;
+    (base + 0.242194 * years - (years / 4.0).floor()).floor() as u32
+}
+
+// The original built-in calendar, kept as the default so existing seeds
+// reproduce the same plans
+fn is_default_holiday(date: NaiveDate) -> bool {
+    let month = date.month();
+    let day = date.day();
+
+    match (month, day) {
+        // New Year's period
+        (1, 1..=3) => true,
+        (12, 31) => true,
+
+        // Christmas/Winter holidays (Dec 20 - Jan 5)
+        (12, 20..=31) => true,
+        (1, 1..=5) => true,
+
+        // US Thanksgiving week
+        (11, 22..=29) => true,
+
+        // July 4th weekend
+        (7, 3..=5) => true,
+
+        // Memorial Day weekend (last Monday of May)
+        (5, 25..=31) if matches!(date.weekday(), Weekday::Sat | Weekday::Sun | Weekday::Mon) => true,
+
+        // Labor Day weekend (first Monday of September)
+        (9, 1..=7) if matches!(date.weekday(), Weekday::Sat | Weekday::Sun | Weekday::Mon) => true,
+
+        _ => false,
+    }
+}
@@ -0,0 +1,80 @@
+use std::process::Command;
+use std::time::Duration;
+use crate::cancel::CancellationToken;
+use crate::error::{GitHubGridError, Result};
+use crate::exec::run_command;
+use crate::executor::RunReport;
+use crate::plan::PlanOutline;
+use crate::output::yellow;
+use crate::status;
+
+// Shell commands from the config run around a generation run, e.g. to bring
+// up a VPN before pushing or to refresh a dashboard afterwards
+#[derive(Debug, Clone, Default)]
+pub struct RunHooks {
+    pub pre_run: Option<String>,
+    pub post_run: Option<String>,
+    pub timeout: Option<Duration>,
+}
+
+impl RunHooks {
+    // A failing pre-run hook aborts the run before anything is committed
+    pub fn pre_run(&self, cancel: &CancellationToken) -> Result<()> {
+        let Some(command) = &self.pre_run else {
+            return Ok(());
+        };
+
+        status!("🪝 Running pre-run hook: {}", command);
+        self.run("pre-run", command, Vec::new(), cancel)
+    }
+
+    // The run summary is exposed as GITHUB_GRID_RUN_* variables. Commits are
+    // already in place by now, so a failure is only reported
+    pub fn post_run(&self, plan: &PlanOutline, report: &RunReport, repo: &str, cancel: &CancellationToken) {
+        let Some(command) = &self.post_run else {
+            return;
+        };
+
+        let env = vec![
+            ("GITHUB_GRID_RUN_OUTCOME", report.outcome.as_str().to_string()),
+            ("GITHUB_GRID_RUN_CREATED", report.created.to_string()),
+            ("GITHUB_GRID_RUN_PLANNED", plan.len().to_string()),
+            ("GITHUB_GRID_RUN_PUSHED", (!report.pending_push).to_string()),
+            ("GITHUB_GRID_RUN_START", plan.start.to_string()),
+            ("GITHUB_GRID_RUN_END", plan.end.to_string()),
+            ("GITHUB_GRID_RUN_SEED", plan.seed.to_string()),
+            ("GITHUB_GRID_RUN_REPO", repo.to_string()),
+        ];
+
+        status!("🪝 Running post-run hook: {}", command);
+        if let Err(e) = self.run("post-run", command, env, cancel) {
+            println!("{}", yellow(format!("⚠️  {}", e)));
+        }
+    }
+
+    fn run(
+        &self,
+        name: &str,
+        command: &str,
+        env: Vec<(&str, String)>,
+        cancel: &CancellationToken,
+    ) -> Result<()> {
+        let mut cmd = Command::new("sh");
+        cmd.arg("-c").arg(command).envs(env);
+
+        let output = run_command(cmd, self.timeout, cancel)?;
+        for line in String::from_utf8_lossy(&output.stdout).lines() {
+            status!("   {}", line);
+        }
+
+        if !output.status.success() {
+            return Err(GitHubGridError::Config(format!(
+                "{} hook failed ({}): {}",
+                name,
+                output.status,
+                String::from_utf8_lossy(&output.stderr).trim()
+            )));
+        }
+        Ok(())
+    }
+}
@@ -0,0 +1,84 @@
+use rand::Rng;
+use rand::seq::IndexedRandom;
+use crate::error::{GitHubGridError, Result};
+use crate::git_ops::GitOperations;
+use crate::github::GitHubClient;
+use crate::messages::AUTOGEN_MARKER;
+use crate::patterns::CommitInfo;
+use crate::status;
+
+// Hidden in the body of every generated issue, so only those are closed
+const ISSUE_MARKER: &str = "<!-- github-grid -->";
+// Titles are "<problem> <area>", and the fix commit "Fix <title>"
+const PROBLEMS: [&str; 8] = [
+    "typo in",
+    "broken link in",
+    "outdated example in",
+    "wrong default in",
+    "missing entry in",
+    "inconsistent formatting in",
+    "unclear wording in",
+    "stale reference in",
+];
+const AREAS: [&str; 8] = [
+    "the README",
+    "the changelog",
+    "the notes",
+    "the setup guide",
+    "the configuration",
+    "the contributing guide",
+    "the examples",
+    "the FAQ",
+];
+// A generated issue stays open for this many days or so before it is fixed
+const OPEN_DAYS: std::ops::RangeInclusive<u64> = 1..=5;
+
+// GitHub counts opening an issue as a contribution on the day it's opened,
+// so like pull requests they can't be backdated. Each call first fixes the
+// generated issues that have been open long enough: a commit on main that
+// references the issue, pushed, then the issue is closed pointing at it.
+// Then, with the given chance, one new issue is opened for a later run to
+// fix. Returns how many were closed and whether one was opened
+pub fn open_and_close(git_ops: &mut GitOperations, github: &GitHubClient, chance: f64, rng: &mut impl Rng) -> Result<(usize, bool)> {
+    let slug = git_ops.remote_slug().ok_or_else(|| GitHubGridError::Config(
+        "origin is not a GitHub repository, so no issues can be opened there".to_string()
+    ))?;
+
+    let now = chrono::Utc::now();
+    let mut closed = 0;
+    for issue in github.open_issues(&slug, ISSUE_MARKER)? {
+        // Spread over OPEN_DAYS by number, so the same issue always waits
+        // the same time
+        let days = OPEN_DAYS.start() + issue.number % (OPEN_DAYS.end() - OPEN_DAYS.start() + 1);
+        if now - issue.created < chrono::Duration::days(days as i64) {
+            continue;
+        }
+
+        let mut subject = issue.title.clone();
+        if subject.is_char_boundary(1) {
+            subject[..1].make_ascii_lowercase();
+        }
+        let fix = CommitInfo {
+            date: chrono::Local::now().fixed_offset(),
+            message: format!("{} Fix {} (#{})", AUTOGEN_MARKER, subject, issue.number),
+        };
+        let before = git_ops.head_commit();
+        let sha = git_ops.create_commit(&fix)?;
+        git_ops.sync_generated_files(before)?;
+        git_ops.push_commits()?;
+        github.close_issue(&slug, issue.number, &format!("Fixed in {}.", sha))?;
+        status!("🐛 Closed issue #{} with {}", issue.number, &sha.to_string()[..7]);
+        closed += 1;
+    }
+
+    if !rng.random_bool(chance) {
+        return Ok((closed, false));
+    }
+    let problem = PROBLEMS.choose(rng).copied().unwrap_or(PROBLEMS[0]);
+    let area = AREAS.choose(rng).copied().unwrap_or(AREAS[0]);
+    let mut title = format!("{} {}", problem, area);
+    title[..1].make_ascii_uppercase();
+    let number = github.open_issue(&slug, &title, &format!("Spotted while reading through it.\n\n{}", ISSUE_MARKER))?;
+    status!("🐛 Opened issue #{} in {}", number, slug);
+    Ok((closed, true))
+}
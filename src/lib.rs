@@ -0,0 +1,42 @@
+// Library API for embedding github-grid in other tools: build a `plan::Plan`
+// with `plan::Planner`, then apply it to a repository with `executor::Executor`
+pub mod activity;
+pub mod actions;
+pub mod bench;
+pub mod cancel;
+pub mod clock;
+pub mod config;
+pub mod content;
+pub mod daemon;
+pub mod design;
+pub mod editor;
+pub mod error;
+pub mod exec;
+pub mod executor;
+pub mod git_ops;
+pub mod github;
+pub mod graph;
+pub mod grid;
+pub mod harvest;
+pub mod holidays;
+pub mod hooks;
+pub mod issues;
+pub mod lock;
+pub mod messages;
+pub mod metrics;
+pub mod output;
+pub mod patterns;
+pub mod plan;
+pub mod preflight;
+pub mod prompt;
+pub mod pulls;
+pub mod safety;
+pub mod schedule;
+pub mod serve;
+pub mod service;
+pub mod state;
+#[cfg(test)]
+mod testing;
+pub mod timezone;
+pub mod version;
+pub mod workspace;
@@ -0,0 +1,91 @@
+use chrono::Local;
+use git2::Repository;
+use std::fs::{self, OpenOptions};
+use std::io::{ErrorKind, Write};
+use std::path::PathBuf;
+use crate::error::{GitHubGridError, Result};
+
+const LOCK_FILE: &str = "github-grid.lock";
+
+// Advisory lock in the repository's .git directory preventing two runs from
+// interleaving commits or clobbering each other's state file. Released on drop
+pub struct RepoLock {
+    path: PathBuf,
+}
+
+impl RepoLock {
+    pub fn acquire(repo: &Repository) -> Result<Self> {
+        let path = repo.path().join(LOCK_FILE);
+
+        match Self::create(&path) {
+            Err(GitHubGridError::Io(e)) if e.kind() == ErrorKind::AlreadyExists => {
+                let holder = fs::read_to_string(&path).unwrap_or_default();
+                let pid = holder.lines()
+                    .find_map(|line| line.strip_prefix("pid="))
+                    .and_then(|pid| pid.trim().parse::<u32>().ok());
+
+                // A lock left behind by a crashed or killed run can be reclaimed
+                if pid.is_some_and(|pid| !process_alive(pid)) {
+                    fs::remove_file(&path)?;
+                    return Self::create(&path);
+                }
+
+                Err(GitHubGridError::Repository(format!(
+                    "Another github-grid run is active on this repository ({}). \
+                     If that's not the case, remove {}",
+                    holder.lines().collect::<Vec<_>>().join(", "),
+                    path.display()
+                )))
+            }
+            result => result,
+        }
+    }
+
+    fn create(path: &PathBuf) -> Result<Self> {
+        let mut file = OpenOptions::new().write(true).create_new(true).open(path)?;
+        writeln!(file, "pid={}", std::process::id())?;
+        writeln!(file, "started={}", Local::now().format("%Y-%m-%d %H:%M:%S"))?;
+        Ok(Self { path: path.clone() })
+    }
+}
+
+impl Drop for RepoLock {
+    fn drop(&mut self) {
+        let _ = fs::remove_file(&self.path);
+    }
+}
+
+// Signal 0 only checks whether the process exists. EPERM means it does, as
+// another user's; if we can't tell, assume it is alive so we never break a
+// lock that is still held
+#[cfg(unix)]
+fn process_alive(pid: u32) -> bool {
+    let Ok(pid) = libc::pid_t::try_from(pid) else {
+        return true;
+    };
+    // SAFETY: signal 0 is never delivered, kill only looks the process up
+    if unsafe { libc::kill(pid, 0) } == 0 {
+        return true;
+    }
+    std::io::Error::last_os_error().raw_os_error() != Some(libc::ESRCH)
+}
+
+// No way to tell elsewhere, so a lock is only ever removed by hand
+#[cfg(not(unix))]
+fn process_alive(_pid: u32) -> bool {
+    true
+}
+
+#[cfg(all(test, unix))]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn process_alive_tells_running_from_exited() {
+        assert!(process_alive(std::process::id()));
+        let mut child = std::process::Command::new("true").spawn().unwrap();
+        let pid = child.id();
+        child.wait().unwrap();
+        assert!(!process_alive(pid));
+    }
+}
@@ -1,29 +1,90 @@
-use chrono::{Local, NaiveDate, Datelike};
+use chrono::{NaiveDate, Datelike};
 use clap::{Parser, Subcommand};
-use git2::{Repository, Signature};
-use indicatif::{ProgressBar, ProgressStyle};
+use rand::{Rng, SeedableRng};
+use rand_chacha::ChaCha8Rng;
+use serde::{Deserialize, Serialize};
+use git2::Repository;
+use std::collections::BTreeMap;
+use std::net::{SocketAddr, TcpListener};
 use std::path::PathBuf;
 use std::fs;
 use std::env;
+use std::time::{Duration, Instant};
 
-mod patterns;
-mod git_ops;
-mod github;
-mod error;
+use github_grid::activity;
+use github_grid::bench;
+use github_grid::cancel::CancellationToken;
+use github_grid::clock::{Clock, SystemClock};
+use github_grid::config::{self, Config, Profile};
+use github_grid::content::{self, ContentKind, GRID_DIR};
+use github_grid::daemon::{self, DEFAULT_LISTEN, Daemon, DaemonOptions};
+use github_grid::editor;
+use github_grid::error::{GitHubGridError, Result};
+use github_grid::executor::{self, Executor, RunOutcome, RunReport};
+use github_grid::git_ops::*;
+use github_grid::github::GitHubClient;
+use github_grid::graph;
+use github_grid::grid::Grid;
+use github_grid::holidays::{self, HolidayCalendar};
+use github_grid::harvest::{self, HarvestOptions};
+use github_grid::hooks::RunHooks;
+use github_grid::issues;
+use github_grid::lock::RepoLock;
+use github_grid::messages::{self, MessagePool};
+use github_grid::output::{self, Verbosity, bold, cyan, green, red, yellow};
+use github_grid::{status, verbose};
+use github_grid::patterns::PatternConfig;
+use github_grid::plan::{Plan, PlanOptions, PlanOutline, Planner, Strategy};
+use github_grid::preflight;
+use github_grid::prompt::confirm;
+use github_grid::pulls;
+use github_grid::actions::{DEFAULT_CRON, DEFAULT_SOURCE, WORKFLOW_PATH, WorkflowSpec};
+use github_grid::safety::{self, ForcePushGate};
+use github_grid::schedule::{CronSchedule, Schedule};
+use github_grid::serve::{PreviewQuery, PreviewServer};
+use github_grid::service::{self, ServiceMode, ServiceSpec};
+use github_grid::state::{RunState, STATE_FORMAT_VERSION};
+use github_grid::timezone::TimezoneSchedule;
+use github_grid::version;
 
-use patterns::{Pattern, CommitInfo, RealisticPattern, SteadyPattern, SporadicPattern, ContractorPattern, CasualPattern, ActivePattern, MaintainerPattern, HyperactivePattern, ExtremePattern, PatternConfig, IntensityLevel, ConfigurablePattern};
-use git_ops::*;
-use github::GitHubClient;
-use error::{GitHubGridError, Result};
+mod commands;
+
+use commands::backfill::{RunPlan, Skips};
+use commands::top_up;
 
 #[derive(Parser)]
 #[command(name = "github-grid")]
+#[command(version = version::LONG_VERSION)]
 #[command(about = "Generate realistic Git commit patterns for GitHub contribution graphs")]
 struct Cli {
     /// Target repository path
     #[arg(short, long)]
     repo: Option<PathBuf>,
     
+    /// Git directory of the target repository, as git's --git-dir (e.g. a bare dotfiles repository)
+    #[arg(long, value_name = "PATH", conflicts_with = "repo")]
+    git_dir: Option<PathBuf>,
+    
+    /// Working tree to use with --git-dir, as git's --work-tree
+    #[arg(long, value_name = "PATH", requires = "git_dir")]
+    work_tree: Option<PathBuf>,
+    
+    /// Config file (defaults to ~/.config/github-grid/config.toml)
+    #[arg(long, env = "GITHUB_GRID_CONFIG")]
+    config: Option<PathBuf>,
+    
+    /// Config profile to use (repo, identity, pattern and hours)
+    #[arg(long, env = "GITHUB_GRID_PROFILE")]
+    profile: Option<String>,
+    
+    /// Run several profiles one after another, each with its own repo, identity and token (comma-separated)
+    #[arg(long, value_delimiter = ',', value_name = "NAMES")]
+    profiles: Vec<String>,
+    
+    /// Run every profile in the config
+    #[arg(long, conflicts_with = "profiles")]
+    all_profiles: bool,
+    
     /// Start date (YYYY-MM-DD)
     #[arg(long)]
     start: Option<String>,
@@ -36,14 +97,158 @@ struct Cli {
     #[arg(long)]
     target_total: Option<u32>,
     
-    /// Pattern to use
-    #[arg(short, long, default_value = "realistic")]
-    pattern: String,
+    /// Pattern to use [default: realistic]
+    #[arg(short, long)]
+    pattern: Option<String>,
+    
+    /// Chance an ordinary weekday is skipped (0 to 1), instead of the pattern's
+    #[arg(long, value_name = "CHANCE")]
+    skip_weekday_chance: Option<f64>,
+    
+    /// Chance an ordinary weekend day is skipped (0 to 1), instead of the pattern's
+    #[arg(long, value_name = "CHANCE")]
+    skip_weekend_chance: Option<f64>,
     
     /// Show preview without committing
     #[arg(long)]
     dry_run: bool,
     
+    /// Only create what today's plan has due by now (for timers and cron jobs)
+    #[arg(long, conflicts_with_all = ["start", "end", "target_total"])]
+    today: bool,
+    
+    /// Skip the confirmation prompt (for scripts and CI)
+    #[arg(short, long)]
+    yes: bool,
+    
+    /// Print a single JSON summary instead of progress output
+    #[arg(long)]
+    json: bool,
+    
+    /// Seed for reproducible runs (random when omitted)
+    #[arg(long)]
+    seed: Option<u64>,
+    
+    /// Stop after this long (e.g. 45m, 2h), push what was created and exit with a partial status
+    #[arg(long, value_parser = humantime::parse_duration)]
+    max_duration: Option<Duration>,
+    
+    /// Write the plan as JSON to this file (combine with --dry-run to only save it)
+    #[arg(long, value_name = "PATH")]
+    save_plan: Option<PathBuf>,
+    
+    /// Open the plan in an interactive grid to adjust individual days before it is saved or applied
+    #[arg(long, conflicts_with = "json")]
+    edit: bool,
+    
+    /// After the run, let git pack objects and write the commit-graph (git gc --auto)
+    #[arg(long)]
+    maintenance: bool,
+    
+    /// Before committing, push one probe commit to a scratch branch and check it will count on your graph
+    #[arg(long)]
+    preflight: bool,
+    
+    /// Chance (0 to 1) of also opening and merging one trivial pull request in the repo after the run
+    #[arg(long, value_name = "CHANCE")]
+    pull_requests: Option<f64>,
+    
+    /// Chance (0 to 1) of also opening an issue in the repo, closed by a fix commit a few days later
+    #[arg(long, value_name = "CHANCE")]
+    issues: Option<f64>,
+    
+    /// Leave days alone that already have commits made by hand in the repo
+    #[arg(long)]
+    skip_active_days: bool,
+    
+    /// Leave days alone that have contributions anywhere on the GitHub account
+    #[arg(long)]
+    skip_github_active_days: bool,
+    
+    /// Run even in a repository that looks like a real project (many hand-made commits, source files)
+    #[arg(long)]
+    force: bool,
+    
+    /// Allow commands that rewrite published history to force-push
+    #[arg(long, global = true)]
+    force_push: bool,
+    
+    /// Set each committer date up to this much after the author date, at random (e.g. 20m)
+    #[arg(long, value_name = "DURATION", value_parser = humantime::parse_duration)]
+    committer_jitter: Option<Duration>,
+    
+    /// Only backdate author dates; committer dates stay the real time of the run
+    #[arg(long, conflicts_with = "committer_jitter")]
+    real_committer_date: bool,
+    
+    /// Make each commit append a line to .grid/activity.log instead of leaving it empty
+    #[arg(long)]
+    activity_log: bool,
+    
+    /// Append to this file in the grid directory instead of activity.log, e.g. "log/{year}-{month}.log" (turns on --activity-log)
+    #[arg(long, value_name = "NAME")]
+    activity_log_file: Option<String>,
+    
+    /// How each activity log line reads, from {date}, {time}, {offset}, {year}, {month}, {day}, {message}, {n} (the line's number) and {word} (a random word) (turns on --activity-log) [default: "{date} {time} {offset} {message}"]
+    #[arg(long, value_name = "TEMPLATE")]
+    activity_template: Option<String>,
+    
+    /// Directory all generated files go in, relative to the repository root [default: .grid]
+    #[arg(long, value_name = "DIR")]
+    grid_dir: Option<String>,
+    
+    /// Make each commit a small change instead of leaving it empty, one of these kinds at random (go, yaml, markdown; comma-separated)
+    #[arg(long, value_delimiter = ',', value_name = "KINDS")]
+    content: Vec<ContentKind>,
+    
+    /// With --content, now and then add a tiny icon or test fixture instead (capped, so the repository stays small)
+    #[arg(long)]
+    assets: bool,
+    
+    /// Save this as user.name in the repository's git config before running
+    #[arg(long, value_name = "NAME")]
+    set_name: Option<String>,
+    
+    /// Save this as user.email in the repository's git config before running
+    #[arg(long, value_name = "EMAIL")]
+    set_email: Option<String>,
+    
+    /// Save your GitHub noreply address (ID+login@users.noreply.github.com) as user.email
+    #[arg(long, conflicts_with = "set_email")]
+    use_noreply: bool,
+    
+    /// Stop unless the target repository is private on GitHub
+    #[arg(long)]
+    require_private: bool,
+    
+    /// Make the target repository private on GitHub if it isn't already
+    #[arg(long, conflicts_with = "require_private")]
+    make_private: bool,
+    
+    /// Push with --no-verify so pre-push hooks are skipped
+    #[arg(long)]
+    no_verify_push: bool,
+    
+    /// Append a CI skip marker to generated messages (default "[skip ci]")
+    #[arg(long, value_name = "MARKER", num_args = 0..=1, default_missing_value = "[skip ci]")]
+    skip_ci: Option<String>,
+    
+    /// Only print the summary and errors
+    #[arg(short, long, global = true, conflicts_with = "verbose")]
+    quiet: bool,
+    
+    /// Show each git command (-v), plus full output and environment (-vv)
+    #[arg(short, long, global = true, action = clap::ArgAction::Count)]
+    verbose: u8,
+    
+    /// Disable colored output (also honours NO_COLOR)
+    #[arg(long, global = true)]
+    no_color: bool,
+    
+    /// Timeout in seconds for each git command (0 disables)
+    #[arg(long, env = "GITHUB_GRID_GIT_TIMEOUT", default_value_t = DEFAULT_COMMAND_TIMEOUT.as_secs())]
+    git_timeout: u64,
+    
     #[command(subcommand)]
     command: Option<Commands>,
 }
@@ -52,6 +257,97 @@ struct Cli {
 enum Commands {
     /// Show available patterns
     Patterns,
+    /// Print the commit message list and weights, ready to paste into a profile
+    Messages,
+    /// Build a message pack from the commit subjects of a local repository or a public URL
+    HarvestMessages {
+        /// Repository path or clone URL
+        source: String,
+        /// Pack name; select it with message_pack = "<name>" in a profile
+        #[arg(long, default_value = "harvested")]
+        name: String,
+        /// Most recent commits to read
+        #[arg(long, default_value_t = 1000)]
+        limit: usize,
+        /// Only use commits by this author (name or email, as in git log --author)
+        #[arg(long)]
+        author: Option<String>,
+        /// Overwrite an existing pack with the same name
+        #[arg(long)]
+        force: bool,
+    },
+    /// Show version, commit and build date
+    Version,
+    /// Write a commented default config file, or check an existing one
+    Config {
+        #[command(subcommand)]
+        action: ConfigAction,
+    },
+    /// Time planning and committing into a throwaway repository (nothing is pushed)
+    Bench {
+        /// Days of history to generate
+        #[arg(long, default_value_t = 365)]
+        days: u32,
+        #[arg(short, long, default_value = "active")]
+        pattern: String,
+    },
+    /// Keep running and top up today's commits periodically
+    Daemon {
+        /// Time between top-up cycles (e.g. 30m, 2h)
+        #[arg(long, default_value = "1h", value_parser = humantime::parse_duration)]
+        interval: Duration,
+        /// Run cycles on a cron schedule instead (e.g. "0 9-18 * * mon-fri")
+        #[arg(long, conflicts_with = "interval")]
+        cron: Option<String>,
+        /// Delay each scheduled cycle by a random amount up to this (e.g. 15m)
+        #[arg(long, value_parser = humantime::parse_duration)]
+        jitter: Option<Duration>,
+        /// Serve metrics, status and the control API on this address (e.g. 127.0.0.1:9464)
+        #[arg(long)]
+        listen: Option<SocketAddr>,
+    },
+    /// Install a systemd user unit (Linux) or launchd agent (macOS) that keeps the grid topped up
+    InstallService {
+        /// Run the long-lived daemon instead of a periodic --today top-up
+        #[arg(long)]
+        daemon: bool,
+        /// How often to top up (timer period or daemon interval)
+        #[arg(long, default_value = "1h", value_parser = humantime::parse_duration)]
+        interval: Duration,
+        /// Stop and remove a previously installed service
+        #[arg(long)]
+        uninstall: bool,
+    },
+    /// Write a scheduled GitHub Actions workflow that runs --today in the grid repository
+    InitActions {
+        /// Cron schedule for the workflow (UTC)
+        #[arg(long, default_value = DEFAULT_CRON)]
+        cron: String,
+        /// Git URL github-grid is installed from on the runner
+        #[arg(long, default_value = DEFAULT_SOURCE)]
+        source: String,
+        /// Overwrite an existing workflow file
+        #[arg(long)]
+        force: bool,
+    },
+    /// Show the status of a running daemon
+    Status {
+        /// Address the daemon was started with --listen on
+        #[arg(long, default_value = DEFAULT_LISTEN)]
+        addr: SocketAddr,
+        /// Ask the daemon to run a cycle now, even while paused
+        #[arg(long, conflicts_with_all = ["pause", "resume"])]
+        top_up: bool,
+        /// Skip scheduled cycles until resumed
+        #[arg(long, conflicts_with = "resume")]
+        pause: bool,
+        /// Resume scheduled cycles
+        #[arg(long)]
+        resume: bool,
+        /// Show today's plan as of the daemon's last cycle
+        #[arg(long)]
+        plan: bool,
+    },
     /// Preview commits for date range
     Preview {
         #[arg(long)]
@@ -60,6 +356,67 @@ enum Commands {
         end: String,
         #[arg(short, long, default_value = "realistic")]
         pattern: String,
+        /// Seed for a reproducible preview
+        #[arg(long)]
+        seed: Option<u64>,
+        /// Chance of light background commits on a design's empty days (0-1)
+        #[arg(long)]
+        background: Option<f64>,
+        /// Draw the plan on top of your current contribution graph (needs gh)
+        #[arg(long)]
+        overlay: bool,
+    },
+    /// Serve a local page with the planned and current graphs side by side, re-planned from a form
+    Serve {
+        /// Address to listen on
+        #[arg(long, default_value = "127.0.0.1:8080")]
+        listen: SocketAddr,
+        /// First day (default: a year before today)
+        #[arg(long)]
+        start: Option<String>,
+        /// Last day (default: today)
+        #[arg(long)]
+        end: Option<String>,
+        #[arg(short, long, default_value = "realistic")]
+        pattern: String,
+        #[arg(long)]
+        seed: Option<u64>,
+        /// Chance of light background commits on a design's empty days (0-1)
+        #[arg(long)]
+        background: Option<f64>,
+        /// Show a plan saved with --save-plan instead of planning from the form
+        #[arg(long, value_name = "PATH")]
+        plan: Option<PathBuf>,
+    },
+    /// Remove generated commits, all of them or those in a date range (force-pushes)
+    Clean {
+        #[arg(long)]
+        start: Option<String>,
+        #[arg(long)]
+        end: Option<String>,
+    },
+    /// Check a plan saved with --save-plan and show its contribution graph
+    InspectPlan {
+        file: PathBuf,
+    },
+    /// Adjust a plan saved with --save-plan day by day in an interactive grid and save it back
+    EditPlan {
+        file: PathBuf,
+    },
+    /// Reset main to a backup ref taken before an earlier run or rewrite
+    Rollback {
+        /// Backup to restore (default: the most recent); see --list
+        backup: Option<String>,
+        /// List the backups instead of restoring one
+        #[arg(long)]
+        list: bool,
+    },
+    /// Replace the generated commits in a date range with a fresh plan from the current settings (force-pushes)
+    Regenerate {
+        #[arg(long)]
+        start: String,
+        #[arg(long)]
+        end: String,
     },
     /// Initialize or reset a private GitHub repo for commit patterns
     Init {
@@ -75,433 +432,1555 @@ enum Commands {
     },
 }
 
-fn main() -> Result<()> {
+#[derive(Subcommand)]
+enum ConfigAction {
+    /// Write a config file with every setting, commented out (to --config or the default path)
+    Init {
+        /// Print it instead of writing the file
+        #[arg(long)]
+        stdout: bool,
+        /// Overwrite an existing file
+        #[arg(long)]
+        force: bool,
+    },
+    /// Check the config file for unknown keys and values a run would reject
+    Validate,
+}
+
+// Exit status for a run that stopped early (e.g. --max-duration exceeded)
+const PARTIAL_EXIT_CODE: i32 = 3;
+// Exit status after a graceful shutdown on SIGINT/SIGTERM
+const CANCELLED_EXIT_CODE: i32 = 130;
+
+fn main() {
     let cli = Cli::parse();
+    output::init_color(cli.no_color);
+    // JSON mode keeps stdout to the final summary
+    output::set_verbosity(Verbosity::from_flags(cli.quiet || cli.json, cli.verbose));
+    
+    if let Err(e) = run(cli) {
+        eprintln!("{} {}", red("❌"), red(e));
+        std::process::exit(1);
+    }
+}
+
+fn run(mut cli: Cli) -> Result<()> {
+    allow_partial_clones()?;
+    let deadline = cli.max_duration.map(|limit| Instant::now() + limit);
+    
+    if cli.all_profiles || !cli.profiles.is_empty() {
+        return run_accounts(&cli);
+    }
     
-    match cli.command {
+    let mut regenerate = false;
+    let mut clean = None;
+    let mut rollback = None;
+    let daemon = match cli.command.take() {
         Some(Commands::Patterns) => {
             show_patterns();
             return Ok(());
         }
-        Some(Commands::Preview { start, end, pattern }) => {
+        Some(Commands::HarvestMessages { source, name, limit, author, force }) => {
+            harvest_messages(&cli, &source, &name, limit, author, force)?;
+            return Ok(());
+        }
+        Some(Commands::Messages) => {
+            show_messages(&cli)?;
+            return Ok(());
+        }
+        Some(Commands::Version) => {
+            show_version();
+            return Ok(());
+        }
+        Some(Commands::Config { action }) => {
+            let path = cli.config.clone().unwrap_or_else(Config::default_path);
+            match action {
+                ConfigAction::Init { stdout, force } => init_config(&path, stdout, force)?,
+                ConfigAction::Validate => validate_config(&path)?,
+            }
+            return Ok(());
+        }
+        Some(Commands::Bench { days, pattern }) => {
+            status!("⏱️  Generating {} days of '{}' into a throwaway repository", days, pattern);
+            bench::run(&pattern, SystemClock.today(), days)?.print();
+            return Ok(());
+        }
+        Some(Commands::InspectPlan { file }) => {
+            inspect_plan(&file)?;
+            return Ok(());
+        }
+        Some(Commands::EditPlan { file }) => {
+            edit_plan(&file)?;
+            return Ok(());
+        }
+        Some(Commands::Preview { start, end, pattern, seed, background, overlay }) => {
             let start_date = NaiveDate::parse_from_str(&start, "%Y-%m-%d")?;
             let end_date = NaiveDate::parse_from_str(&end, "%Y-%m-%d")?;
-            preview_pattern(&pattern, start_date, end_date)?;
+            graph::preview(&pattern, start_date, end_date, seed, background, overlay)?;
+            return Ok(());
+        }
+        Some(Commands::Serve { listen, start, end, pattern, seed, background, plan }) => {
+            let grid = Grid::rolling(SystemClock.today());
+            let defaults = PreviewQuery {
+                start: start.map(|date| NaiveDate::parse_from_str(&date, "%Y-%m-%d")).transpose()?.unwrap_or(grid.start()),
+                end: end.map(|date| NaiveDate::parse_from_str(&date, "%Y-%m-%d")).transpose()?.unwrap_or(grid.end()),
+                pattern,
+                seed,
+                background,
+            };
+            let saved = plan.as_deref().map(Plan::load).transpose()?;
+            let github = GitHubClient::new()
+                .inspect_err(|e| status!("{}", yellow(format!("⚠️  {}; only the plan is shown", e))))
+                .ok();
+            PreviewServer::new(defaults, saved, github).run(TcpListener::bind(listen)?)?;
             return Ok(());
         }
         Some(Commands::Init { name, force, local_dir }) => {
-            init_github_repo(name, force, local_dir)?;
+            init_github_repo(&cli, name, force, local_dir)?;
+            return Ok(());
+        }
+        Some(Commands::InstallService { daemon, interval, uninstall }) => {
+            let mode = if daemon { ServiceMode::Daemon } else { ServiceMode::Timer };
+            install_service(&cli, mode, interval, uninstall)?;
+            return Ok(());
+        }
+        Some(Commands::InitActions { cron, source, force }) => {
+            init_actions(&cli, cron, source, force)?;
+            return Ok(());
+        }
+        Some(Commands::Status { addr, top_up, pause, resume, plan }) => {
+            let action = [(top_up, "top-up"), (pause, "pause"), (resume, "resume")]
+                .into_iter()
+                .find_map(|(requested, action)| requested.then_some(action));
+            if let Some(action) = action {
+                daemon::control(addr, action)?;
+                println!("{}", green(format!("✅ Sent {} to the daemon at {}", action, addr)));
+            }
+            show_daemon_status(addr)?;
+            if plan {
+                show_daemon_plan(addr)?;
+            }
             return Ok(());
         }
-        None => {}
+        Some(Commands::Daemon { interval, cron, jitter, listen }) => {
+            let schedule = match cron {
+                Some(expression) => Schedule::Cron(expression.parse()?),
+                None => Schedule::Every(interval),
+            };
+            Some((schedule, jitter, listen))
+        }
+        Some(Commands::Rollback { backup, list }) => {
+            rollback = Some((backup, list));
+            None
+        }
+        Some(Commands::Clean { start, end }) => {
+            clean = Some((start, end));
+            None
+        }
+        Some(Commands::Regenerate { start, end }) => {
+            if cli.today {
+                return Err(GitHubGridError::Config("regenerate takes a date range, not --today".to_string()));
+            }
+            cli.start = Some(start);
+            cli.end = Some(end);
+            regenerate = true;
+            None
+        }
+        None => None,
+    };
+    
+    let profile = Config::load(cli.config.as_deref())?.profile(cli.profile.as_deref())?;
+    if let Some(name) = cli.profile.as_deref() {
+        status!("👤 Profile: {}", name);
+    }
+    if let Some(var) = &profile.token_env {
+        let token = env::var(var).map_err(|_| GitHubGridError::Authentication(format!(
+            "The profile takes its GitHub token from {}, which is not set", var
+        )))?;
+        // SAFETY: no other threads exist yet, so nothing reads the
+        // environment concurrently; gh and git children inherit it
+        unsafe { env::set_var("GH_TOKEN", token) };
     }
     
-    // Use default path if not specified
-    let home_dir = env::var("HOME").unwrap_or_else(|_| ".".to_string());
-    let repo_path = match cli.repo {
-        Some(path) => path,
-        None => {
-            // Get username dynamically for default path
-            let github = GitHubClient::new()?;
-            let username = github.username();
-            PathBuf::from(format!("{}/github/{}-grid", home_dir, username))
+    let messages = message_pool(&profile)?;
+    let year_profiles = profile.year_profiles()?;
+    if let Some(workspace) = profile.workspace.clone().filter(|_| cli.repo.is_none() && cli.git_dir.is_none()) {
+        if daemon.is_some() || clean.is_some() || rollback.is_some() || regenerate || cli.today {
+            return Err(GitHubGridError::Config(
+                "The profile has a workspace; this works on one repository, so pass --repo".to_string()
+            ));
         }
+        return commands::workspace::run(cli, profile, workspace, messages, deadline);
+    }
+    let committer_date = committer_date(&cli, &profile)?;
+    let generated = GeneratedFiles::resolve(&cli, &profile)?;
+    let (repo, repo_path) = open_repository(cli.git_dir.as_deref(), cli.work_tree.as_deref(), cli.repo.clone().or(profile.repo.clone()))?;
+    let lock = RepoLock::acquire(&repo)?;
+    let mut git_ops = git_operations(repo, &cli, &profile, committer_date, &generated);
+    // clean and rollback only take generated commits away
+    check_checkout(&git_ops, &cli, clean.is_none() && rollback.is_none())?;
+    let gate = ForcePushGate::new(cli.force_push);
+    if regenerate && !cli.dry_run {
+        gate.ensure_allowed()?;
+    }
+    let hooks = RunHooks {
+        pre_run: profile.pre_run.clone(),
+        post_run: profile.post_run.clone(),
+        timeout: git_ops.command_timeout(),
     };
+    let cancel = CancellationToken::on_shutdown_signals()?;
+    let pattern = cli.pattern.clone().or(profile.pattern.clone()).unwrap_or_else(|| "realistic".to_string());
+    let timezones = TimezoneSchedule::new(profile.timezones.clone().unwrap_or_default())?;
+    let weekend = profile.weekend.clone().unwrap_or_else(|| PatternConfig::default().weekend);
+    let mut holidays = HolidayCalendar::new(profile.holidays.as_deref())?;
+    if let Some(path) = &profile.holidays_file {
+        holidays.add_file(path)?;
+    }
     
-    let repo = Repository::open(&repo_path)?;
-    let mut git_ops = GitOperations::new(repo);
+    if let Some((backup, list)) = rollback {
+        return rollback_to_backup(&mut git_ops, &gate, backup, list, cli.force_push, cli.yes);
+    }
+    if let Some((start, end)) = clean {
+        return clean_history(&mut git_ops, &gate, start, end, cli.dry_run, cli.yes);
+    }
+    
+    if cli.use_noreply {
+        let email = GitHubClient::new()?.noreply_email()?;
+        status!("📧 Using your noreply address {}", email);
+        cli.set_email = Some(email);
+    }
+    if cli.set_name.is_some() || cli.set_email.is_some() {
+        git_ops.configure_identity(cli.set_name.as_deref(), cli.set_email.as_deref())?;
+        status!("🪪 Commit identity saved in the repository's git config");
+    }
+    check_counts(&git_ops, &cli)?;
+    
+    let pull_requests = cli.pull_requests.or(profile.pull_requests).unwrap_or(0.0);
+    let issues = cli.issues.or(profile.issues).unwrap_or(0.0);
+    for (key, chance) in [("pull_requests", pull_requests), ("issues", issues)] {
+        if !(0.0..=1.0).contains(&chance) {
+            return Err(GitHubGridError::Config(format!("{} {}: expected a probability between 0 and 1", key, chance)));
+        }
+    }
     
-    let (start_date, end_date) = determine_date_range(&mut git_ops, cli.start, cli.end)?;
+    if let Some((schedule, jitter, listen)) = daemon {
+        // The daemon takes the lock for each cycle instead, so manual runs can
+        // still get in between
+        drop(lock);
+        let top_up = top_up::options(&git_ops, &cli, &profile, pattern, timezones, weekend, holidays, messages)?;
+        return Daemon::new(git_ops, DaemonOptions {
+            schedule,
+            jitter,
+            listen,
+            pull_requests,
+            issues,
+            top_up,
+        }).with_cancellation(cancel).run();
+    }
     
-    println!("Generating commits from {} to {}", start_date, end_date);
+    if !prepare_origin(&mut git_ops, &cli, &cancel)? {
+        if cli.json {
+            JsonSummary::new("aborted", None, &repo_path).print();
+        } else {
+            println!("Aborted, nothing was committed");
+        }
+        return Ok(());
+    }
     
-    let (_pattern_name, commits) = if let Some(target_total) = cli.target_total {
-        // Target-based generation
-        let current_year = start_date.year();
-        let existing_commits = count_existing_commits(&git_ops, current_year)?;
-        let commits_needed = target_total.saturating_sub(existing_commits);
-        let days_in_range = (end_date - start_date).num_days() + 1;
+    let (mut plan, mut outline) = if cli.today {
+        let options = top_up::options(&git_ops, &cli, &profile, pattern, timezones, weekend.clone(), holidays, messages)?;
+        match top_up::plan_today(&mut git_ops, &options, cli.json, &repo_path)? {
+            Some(plan) => {
+                let outline = plan.outline();
+                (RunPlan::Whole(plan), outline)
+            }
+            None => return Ok(()),
+        }
+    } else {
+        let (start_date, end_date, resume_seed) = executor::determine_date_range(&mut git_ops, &SystemClock, cli.start, cli.end)?;
         
-        println!("🎯 Target: {} commits total for {}", target_total, current_year);
-        println!("📊 Existing: {} commits", existing_commits);
-        println!("➕ Generating: ~{} commits over {} days", commits_needed, days_in_range);
+        status!("Generating commits from {} to {}", start_date, end_date);
         
-        if commits_needed == 0 {
-            println!("✅ Target already reached!");
-            return Ok(());
+        // Generated commits that regenerate will remove, so targets don't
+        // count them as existing
+        let replaced = if regenerate {
+            git_ops.autogen_commits_per_day(start_date, end_date)?
+        } else {
+            BTreeMap::new()
+        };
+        let replaced_in = |year: i32| -> u32 {
+            replaced.iter().filter(|(day, _)| day.year() == year).map(|(_, count)| count).sum()
+        };
+        if regenerate {
+            status!("🧹 Replacing {} generated commits in the range", replaced.values().sum::<u32>());
+        }
+        
+        let strategy = if let Some(target_total) = cli.target_total.or(profile.target_total) {
+            // Target-based generation
+            let current_year = start_date.year();
+            let existing_commits = git_ops.count_commits_in_year(current_year)?.saturating_sub(replaced_in(current_year));
+            let commits_needed = target_total.saturating_sub(existing_commits);
+            let days_in_range = (end_date - start_date).num_days() + 1;
+            
+            status!("🎯 Target: {} commits total for {}", target_total, current_year);
+            status!("📊 Existing: {} commits", existing_commits);
+            status!("➕ Generating: ~{} commits over {} days", commits_needed, days_in_range);
+            
+            if commits_needed == 0 {
+                if cli.json {
+                    JsonSummary::new("up_to_date", None, &repo_path).print();
+                } else {
+                    println!("{}", green("✅ Target already reached!"));
+                }
+                return Ok(());
+            }
+            
+            Strategy::Target { total: target_total, existing: existing_commits }
+        } else {
+            // Traditional pattern-based generation
+            status!("Pattern: {}", pattern);
+            Strategy::Pattern(pattern)
+        };
+        
+        // Years with their own pattern or target
+        let mut years = BTreeMap::new();
+        for (&year, settings) in year_profiles.range(start_date.year()..=end_date.year()) {
+            let strategy = match settings.target_total {
+                Some(total) => {
+                    let existing = git_ops.count_commits_in_year(year)?.saturating_sub(replaced_in(year));
+                    status!("📆 {}: target {} commits ({} existing)", year, total, existing);
+                    Strategy::Target { total, existing }
+                }
+                None => {
+                    let pattern = settings.pattern.clone().unwrap_or_default();
+                    status!("📆 {}: pattern {}", year, pattern);
+                    Strategy::Pattern(pattern)
+                }
+            };
+            years.insert(year, strategy);
         }
         
-        let config = calibrate_pattern_for_target(commits_needed, days_in_range);
-        let pattern_impl = ConfigurablePattern::new(config);
-        let commits = pattern_impl.generate(start_date, end_date);
+        // Generated commits from earlier runs over these days; days planned
+        // from a pattern are only topped up. Targets already count them
+        let mut existing = if regenerate {
+            BTreeMap::new()
+        } else {
+            git_ops.autogen_commits_per_day(start_date, end_date)?
+        };
+        existing.retain(|day, _| matches!(years.get(&day.year()).unwrap_or(&strategy), Strategy::Pattern(_)));
+        
+        let planner = Planner::new(PlanOptions {
+            seed: cli.seed.or(profile.seed).or(resume_seed),
+            work_hours: profile.hours,
+            timezones,
+            weekend: Some(weekend.clone()),
+            holidays: Some(holidays),
+            messages: Some(messages),
+            weekday_ranges: profile.weekdays,
+            curve: profile.curve,
+            big_days: profile.big_days,
+            skip_weekday_chance: cli.skip_weekday_chance.or(profile.skip_weekday_chance),
+            skip_weekend_chance: cli.skip_weekend_chance.or(profile.skip_weekend_chance),
+            day_correlation: profile.day_correlation,
+            commit_offset: profile.commit_offset,
+            graph_timezone: profile.github_timezone,
+            design_background: profile.design_background,
+            years,
+            ..PlanOptions::new(start_date, end_date, strategy)
+        });
         
-        (format!("target-{}", target_total), commits)
-    } else {
-        // Traditional pattern-based generation
-        println!("Pattern: {}", cli.pattern);
-        let pattern = create_pattern(&cli.pattern)?;
-        let commits = pattern.generate(start_date, end_date);
-        (cli.pattern.clone(), commits)
+        let mut skips = Skips { existing, ..Skips::default() };
+        let skip_active_days = cli.skip_active_days || profile.skip_active_days.unwrap_or(false);
+        if skip_active_days {
+            skips.active = git_ops.real_commits_per_day(start_date, end_date)?;
+        }
+        let skip_github_active_days = cli.skip_github_active_days || profile.skip_github_active_days.unwrap_or(false);
+        if skip_github_active_days {
+            let github = GitHubClient::new()?;
+            status!("📥 Fetching the contribution graph of {}", github.username());
+            let mut active = github.contribution_calendar(start_date, end_date)?;
+            // The graph includes what earlier runs pushed from this repository
+            let generated = git_ops.autogen_commits_per_day(start_date, end_date)?;
+            active.retain(|day, count| *count > generated.get(day).copied().unwrap_or(0));
+            skips.github_active = active;
+        }
+        
+        let (active_days, github_active_days) = (skips.active.len(), skips.github_active.len());
+        
+        // Only counted here, a year at a time; the commits are generated
+        // again as they're applied
+        let plan = RunPlan::Streamed(planner, skips);
+        let (outline, [existing, active, github_active]) = plan.outline()?;
+        status!("Generated {} commits (seed {})", outline.len() + active + github_active, outline.seed);
+        if existing > 0 {
+            status!("⏭️  Skipped {} commits earlier runs already made", existing);
+        }
+        if skip_active_days {
+            status!("⏭️  Left {} days with real commits alone ({} planned commits dropped)", active_days, active);
+        }
+        if skip_github_active_days {
+            status!("⏭️  Left {} days with contributions on GitHub alone ({} planned commits dropped)", github_active_days, github_active);
+        }
+        if outline.is_empty() && existing + active + github_active > 0 && !regenerate {
+            if cli.json {
+                JsonSummary::new("up_to_date", Some(&outline), &repo_path).print();
+            } else {
+                println!("{}", green("✅ Already up to date"));
+            }
+            return Ok(());
+        }
+        (plan, outline)
     };
     
-    println!("Generated {} commits", commits.len());
+    // A profile shows the last year by default; earlier days only appear
+    // when their year is selected
+    let visible_from = Grid::rolling(SystemClock.today()).start();
+    if outline.start < visible_from {
+        let hidden: usize = outline.per_day.range(..visible_from).map(|(_, count)| count).sum();
+        status!("{}", yellow(format!(
+            "⚠️  {} to {} ({} commits) is before the default profile view, which starts {}; it only shows when those years are selected",
+            outline.start, visible_from - chrono::Duration::days(1), hidden, visible_from
+        )));
+        if !cli.yes && !cli.json && outline.end >= visible_from
+            && confirm(&format!("Clip the range to start at {}?", visible_from))? {
+            plan.clip(visible_from);
+            outline.clip(visible_from);
+        }
+    }
+    
+    // Editing and saving need every commit at once
+    if cli.edit || cli.save_plan.is_some() {
+        let mut whole = plan.into_whole(&outline)?;
+        if cli.edit && editor::edit(&mut whole)? {
+            status!("✏️  Plan edited: {} commits on {} days", whole.len(), whole.per_day().len());
+        }
+        if let Some(path) = &cli.save_plan {
+            whole.save(path)?;
+            status!("💾 Plan saved to {}", path.display());
+        }
+        outline = whole.outline();
+        plan = RunPlan::Whole(whole);
+    }
     
     if cli.dry_run {
-        show_commit_summary(&commits);
+        if cli.json {
+            JsonSummary::new("dry_run", Some(&outline), &repo_path).print();
+        } else {
+            graph::show_summary(&outline.per_day, &weekend);
+            println!();
+            show_impact(&outline, &git_ops, regenerate)?;
+        }
         return Ok(());
     }
     
-    execute_commits(&mut git_ops, commits)?;
-    
-    Ok(())
-}
-
-fn show_patterns() {
-    println!("Available patterns:");
-    println!("\nActivity levels (commits/year):");
-    println!("  casual      - Weekend warrior, occasional PRs (~300/year)");
-    println!("  realistic   - Professional developer activity (~1,200/year)");
-    println!("  active      - Multiple projects, good practices (~2,500/year)");
-    println!("  maintainer  - Managing repos, reviewing PRs (~5,000/year)");
-    println!("  hyperactive - Startup pace, heavy open source (~12,000/year)");
-    println!("  extreme     - AI-assisted development (~20,000+/year)");
-    println!("\nLegacy patterns:");
-    println!("  steady      - Consistent daily activity");
-    println!("  sporadic    - Irregular bursts of activity");
-    println!("  contractor  - Mon-Fri focused with occasional weekends");
-}
-
-fn preview_pattern(pattern_name: &str, start: NaiveDate, end: NaiveDate) -> Result<()> {
-    let pattern = create_pattern(pattern_name)?;
-    let commits = pattern.generate(start, end);
-    
-    show_commit_calendar(&commits, start, end);
-    show_commit_summary(&commits);
-    
-    Ok(())
-}
-
-fn show_commit_calendar(commits: &[CommitInfo], start: NaiveDate, end: NaiveDate) {
-    println!("\n📅 Commit Calendar:");
-    
-    let mut current = start;
-    while current <= end {
-        let count = commits.iter()
-            .filter(|c| c.date.date_naive() == current)
-            .count();
-            
-        let symbol = match count {
-            0 => "░",
-            1..=3 => "▓",
-            4..=10 => "█",
-            _ => "🔥",
-        };
-        
-        if current.weekday().number_from_monday() == 1 {
-            println!();
-            print!("{:>10} ", current.format("%b %d"));
+    if !cli.json {
+        show_plan_summary(&outline, &repo_path, &git_ops);
+        show_impact(&outline, &git_ops, regenerate)?;
+    }
+    if !cli.yes && !confirm("Create these commits?")? {
+        if cli.json {
+            JsonSummary::new("aborted", Some(&outline), &repo_path).print();
+        } else {
+            println!("Aborted, nothing was committed");
         }
-        
-        print!("{}", symbol);
-        current = current.succ_opt().unwrap();
+        return Ok(());
     }
-    println!("\n\nLegend: ░=0 ▓=1-3 █=4-10 🔥=10+ commits\n");
-}
-
-fn show_commit_summary(commits: &[CommitInfo]) {
-    let total = commits.len();
-    let avg_per_day = if total > 0 {
-        commits.iter()
-            .map(|c| c.date.date_naive())
-            .collect::<std::collections::HashSet<_>>()
-            .len()
-    } else {
-        0
-    };
     
-    println!("Summary:");
-    println!("  Total commits: {}", total);
-    println!("  Active days: {}", avg_per_day);
-    if avg_per_day > 0 {
-        println!("  Avg commits/day: {:.1}", total as f64 / avg_per_day as f64);
+    if cli.preflight || profile.preflight.unwrap_or(false) {
+        if let Some(first) = plan.first_commit()? {
+            let github = GitHubClient::new()?;
+            for warning in preflight::check(&mut git_ops, &github, first)? {
+                status!("{}", yellow(format!("⚠️  {}", warning)));
+            }
+            status!("✅ Preflight passed: the probe commit counts for {}", github.username());
+        }
     }
     
-    let weekend_commits = commits.iter()
-        .filter(|c| {
-            let weekday = c.date.weekday();
-            weekday == chrono::Weekday::Sat || weekday == chrono::Weekday::Sun
-        })
-        .count();
+    hooks.pre_run(&cancel)?;
     
-    println!("  Weekend commits: {} ({:.1}%)", weekend_commits, 
-             weekend_commits as f64 / total as f64 * 100.0);
-}
-
-fn determine_date_range(
-    git_ops: &mut GitOperations,
-    start: Option<String>,
-    end: Option<String>,
-) -> Result<(NaiveDate, NaiveDate)> {
-    let end_date = match end {
-        Some(date_str) => NaiveDate::parse_from_str(&date_str, "%Y-%m-%d")?,
-        None => Local::now().date_naive(),
-    };
+    if regenerate {
+        let dropped = git_ops.drop_autogen_commits(outline.start, outline.end)?;
+        status!("🧹 Removed {} generated commits from {} to {}", dropped, outline.start, outline.end);
+    }
+    let maintenance = cli.maintenance || profile.maintenance.unwrap_or(false);
+    let report = apply_plan(&mut git_ops, &outline, plan.parts(), regenerate, maintenance, deadline, &cancel)?;
+    if regenerate {
+        if report.outcome == RunOutcome::Complete {
+            git_ops.force_push(&gate)?;
+        } else {
+            // Half a rewrite on origin would lose the rest of the range's
+            // commits, so it stays local until a run gets to the end
+            status!("{}", yellow(format!(
+                "⚠️  Regenerate stopped early, so nothing was pushed and origin/{} is unchanged. Run `github-grid regenerate --start {} --end {}` again to finish",
+                MAIN_BRANCH, outline.start, outline.end
+            )));
+        }
+    }
     
-    let start_date = match start {
-        Some(date_str) => NaiveDate::parse_from_str(&date_str, "%Y-%m-%d")?,
-        None => {
-            match git_ops.get_latest_autogen_commit()? {
-                Some(last_commit) => last_commit.date_naive() + chrono::Duration::days(1),
-                None => end_date - chrono::Duration::days(365),
+    // Only after a clean run: a pull request merged over pending pushes would
+    // leave main to be pulled on top of unpushed commits. Drawn from the
+    // plan's seed, so --seed reproduces them
+    if !regenerate && report.outcome == RunOutcome::Complete && !report.pending_push {
+        let mut rng = ChaCha8Rng::seed_from_u64(outline.seed ^ pulls::ROLL_SALT);
+        if rng.random_bool(pull_requests) {
+            let opened = GitHubClient::new().and_then(|github| pulls::open_and_merge(&mut git_ops, &github, &mut rng));
+            if let Err(e) = opened {
+                // The commits are in place either way
+                status!("{}", yellow(format!("⚠️  Pull request failed: {}", e)));
             }
         }
-    };
+        if issues > 0.0 {
+            let updated = GitHubClient::new().and_then(|github| issues::open_and_close(&mut git_ops, &github, issues, &mut rng));
+            if let Err(e) = updated {
+                status!("{}", yellow(format!("⚠️  Issues failed: {}", e)));
+            }
+        }
+    }
+    
+    // Runs even after Ctrl+C so it can see the cancelled outcome; a second
+    // signal still force-quits
+    hooks.post_run(&outline, &report, &repo_path.display().to_string(), &CancellationToken::new());
+    
+    // process::exit skips destructors, so release the lock explicitly
+    drop(lock);
+    
+    if cli.json {
+        JsonSummary {
+            created: report.created,
+            pending_push: report.pending_push,
+            ..JsonSummary::new(report.outcome.as_str(), Some(&outline), &repo_path)
+        }.print();
+    }
+    
+    match report.outcome {
+        RunOutcome::Complete => {}
+        RunOutcome::Partial => {
+            if !cli.json && !regenerate {
+                println!("{}", yellow("⏸️  Partial run: time limit reached, progress saved to checkpoint"));
+            }
+            std::process::exit(PARTIAL_EXIT_CODE);
+        }
+        RunOutcome::Cancelled => {
+            if !cli.json && !regenerate {
+                println!("{}", yellow("🛑 Cancelled: completed commits pushed, progress saved to checkpoint"));
+            }
+            std::process::exit(CANCELLED_EXIT_CODE);
+        }
+    }
     
-    Ok((start_date, end_date))
+    Ok(())
 }
 
-fn create_pattern(name: &str) -> Result<Box<dyn Pattern>> {
-    match name {
-        // Legacy patterns
-        "realistic" => Ok(Box::new(RealisticPattern::new())),
-        "steady" => Ok(Box::new(SteadyPattern::new())),
-        "sporadic" => Ok(Box::new(SporadicPattern::new())),
-        "contractor" => Ok(Box::new(ContractorPattern::new())),
-        // Activity-level patterns
-        "casual" => Ok(Box::new(CasualPattern::new())),
-        "active" => Ok(Box::new(ActivePattern::new())),
-        "maintainer" => Ok(Box::new(MaintainerPattern::new())),
-        "hyperactive" => Ok(Box::new(HyperactivePattern::new())),
-        "extreme" => Ok(Box::new(ExtremePattern::new())),
-        _ => Err(GitHubGridError::Config(format!("Unknown pattern: {}", name))),
+// How the checkout is laid out, and, with `guard_project`, whether the
+// repository looks like a real project generated commits would clutter
+fn check_checkout(git_ops: &GitOperations, cli: &Cli, guard_project: bool) -> Result<()> {
+    let layout = git_ops.layout();
+    if let Some(promisor) = &layout.promisor {
+        verbose!("🧩 Partial clone of {}: checkouts go through git, which fetches missing objects", promisor);
+    }
+    if layout.sparse {
+        verbose!("🧩 Sparse checkout: checkouts go through git, which keeps to the sparse patterns");
+    }
+    if layout.lfs {
+        status!("{}", yellow(
+            "⚠️  The repository uses Git LFS. Generated commits write plain blobs without running LFS filters, so keep \
+             the grid directory out of its patterns; checkouts go through git so LFS files are restored. Pushes still \
+             run git-lfs's pre-push hook; --no-verify-push skips it"
+        ));
+    }
+    if layout.submodules {
+        status!("{}", yellow(
+            "⚠️  The repository has submodules. Generated commits keep them pinned exactly as they are on main and never update them"
+        ));
+    }
+    if guard_project {
+        if let Some(problem) = safety::project_lookalike(git_ops)? {
+            if !cli.force && !cli.dry_run {
+                return Err(GitHubGridError::Config(format!(
+                    "{}. Point --repo at a repository made for the graph (github-grid init creates one), \
+                     or pass --force if this really is the one", problem
+                )));
+            }
+            let note = if cli.force { "going ahead because of --force" } else { "a real run needs --force" };
+            status!("{}", yellow(format!("⚠️  {}; {}", problem, note)));
+        }
+    }
+    Ok(())
+}
+
+// Whether commits made here count on the graph: an author address GitHub
+// can match (a dry run only warns, since nothing is committed) and, when
+// asked for, a private origin
+fn check_counts(git_ops: &GitOperations, cli: &Cli) -> Result<()> {
+    if let Err(e) = git_ops.check_author_email() {
+        if !cli.dry_run {
+            return Err(e);
+        }
+        status!("{}", yellow(format!("⚠️  {}", e)));
+    }
+    if cli.require_private || cli.make_private {
+        ensure_private(git_ops, cli.make_private, cli.dry_run)?;
+    }
+    Ok(())
+}
+
+// Gets origin ready for a run: reachable, or pushes are queued until it's
+// back, with main as its default branch and commits that count for the
+// user, and with what earlier runs left unpushed dealt with. Returns false
+// when the user chose to stop
+fn prepare_origin(git_ops: &mut GitOperations, cli: &Cli, cancel: &CancellationToken) -> Result<bool> {
+    if !cli.dry_run {
+        match git_ops.check_remote_access() {
+            Ok(()) => check_default_branch(git_ops, cli.yes)?,
+            // Offline runs still work: pushes are queued until origin is back
+            Err(GitHubGridError::Network(message)) => {
+                status!("{}", yellow(format!("⚠️  {}; pushes will be queued", message)));
+            }
+            Err(e) => return Err(e),
+        }
     }
+    if !check_github_repo(git_ops, cli.yes || cli.dry_run)? {
+        return Ok(false);
+    }
+    
+    Executor::new(git_ops)
+        .with_cancellation(cancel.clone())
+        .flush_pending_push()?;
+    if !cli.dry_run {
+        recover_unpushed(git_ops, cli.yes)?;
+    }
+    Ok(true)
 }
 
-fn execute_commits(
+// Commits a plan, part by part, to one repository: main is backed up first (regenerate
+// has already backed it up, and pushes itself), and the repository is
+// maintained afterwards when asked to
+fn apply_plan(
     git_ops: &mut GitOperations,
-    commits: Vec<CommitInfo>,
-) -> Result<()> {
-    let pb = ProgressBar::new(commits.len() as u64);
-    pb.set_style(
-        ProgressStyle::default_bar()
-            .template("{spinner:.green} [{elapsed_precise}] [{bar:40.cyan/blue}] {pos}/{len} {msg}")
-            .unwrap(),
-    );
-    
-    let mut batch_count = 0;
-    const BATCH_SIZE: usize = 500;
-    
-    for commit in commits {
-        pb.set_message(format!("Committing {}", commit.date.format("%Y-%m-%d %H:%M")));
-        
-        git_ops.create_commit(&commit)?;
-        
-        batch_count += 1;
-        if batch_count >= BATCH_SIZE {
-            pb.set_message("Pushing batch...".to_string());
-            git_ops.push_commits()?;
-            batch_count = 0;
+    outline: &PlanOutline,
+    parts: impl IntoIterator<Item = Result<Plan>>,
+    regenerate: bool,
+    maintenance: bool,
+    deadline: Option<Instant>,
+    cancel: &CancellationToken,
+) -> Result<RunReport> {
+    if !regenerate {
+        if let Some(backup) = git_ops.backup_head()? {
+            status!("💾 {} saved as {}", MAIN_BRANCH, backup);
+        }
+    }
+    let mut executor = Executor::new(git_ops)
+        .with_deadline(deadline)
+        .with_cancellation(cancel.clone());
+    if regenerate {
+        executor = executor.without_push();
+    }
+    let report = executor.execute_stream(outline, parts)?;
+    
+    if maintenance && report.created > 0 {
+        status!("🧰 Running repository maintenance...");
+        if let Err(e) = git_ops.run_maintenance() {
+            // The commits are in place either way
+            status!("{}", yellow(format!("⚠️  Maintenance failed: {}", e)));
+        }
+    }
+    Ok(report)
+}
+
+// Result of a run for --json, one object on stdout
+#[derive(Serialize, Deserialize)]
+struct JsonSummary {
+    outcome: String,
+    planned: usize,
+    created: usize,
+    pending_push: bool,
+    start: Option<NaiveDate>,
+    end: Option<NaiveDate>,
+    seed: Option<u64>,
+    repo: String,
+}
+
+impl JsonSummary {
+    fn new(outcome: &str, plan: Option<&PlanOutline>, repo_path: &std::path::Path) -> Self {
+        Self {
+            outcome: outcome.to_string(),
+            planned: plan.map(PlanOutline::len).unwrap_or(0),
+            created: 0,
+            pending_push: false,
+            start: plan.map(|plan| plan.start),
+            end: plan.map(|plan| plan.end),
+            seed: plan.map(|plan| plan.seed),
+            repo: repo_path.display().to_string(),
+        }
+    }
+    
+    fn print(&self) {
+        println!("{}", serde_json::to_string(self).unwrap_or_default());
+    }
+}
+
+// One account's result in a --profiles run
+#[derive(Serialize)]
+struct AccountSummary {
+    profile: String,
+    #[serde(flatten)]
+    summary: Option<JsonSummary>,
+    error: Option<String>,
+}
+
+// --profiles/--all-profiles: run each profile as its own process with --json,
+// so repositories, locks, identities and GH_TOKEN stay separate, then report
+// per account. Stops early when a run is cancelled
+fn run_accounts(cli: &Cli) -> Result<()> {
+    if cli.command.is_some() {
+        return Err(GitHubGridError::Config("--profiles only applies to generation runs, not subcommands".to_string()));
+    }
+    if !cli.yes && !cli.dry_run {
+        return Err(GitHubGridError::Config(
+            "Running several profiles needs --yes (or --dry-run): the accounts can't be confirmed one by one".to_string()
+        ));
+    }
+    let config = Config::load(cli.config.as_deref())?;
+    let names: Vec<String> = match cli.all_profiles {
+        true => config.profiles.keys().cloned().collect(),
+        false => cli.profiles.clone(),
+    };
+    if names.is_empty() {
+        return Err(GitHubGridError::Config("The config has no profiles to run".to_string()));
+    }
+    if let Some(unknown) = names.iter().find(|name| !config.profiles.contains_key(*name)) {
+        config.profile(Some(unknown))?;
+    }
+    
+    let cancel = CancellationToken::on_shutdown_signals()?;
+    let exe = env::current_exe()?;
+    let mut accounts = Vec::new();
+    for name in &names {
+        if cancel.is_cancelled() {
+            break;
         }
+        status!("👤 {}", bold(name));
+        let output = std::process::Command::new(&exe)
+            .args(account_args(name))
+            .stdin(std::process::Stdio::null())
+            .output()?;
+        // Warnings and errors from the run
+        eprint!("{}", String::from_utf8_lossy(&output.stderr));
         
-        pb.inc(1);
+        let stdout = String::from_utf8_lossy(&output.stdout);
+        let summary: Option<JsonSummary> = stdout.lines().rev().find_map(|line| serde_json::from_str(line).ok());
+        let error = match (&summary, output.status.success()) {
+            (Some(_), _) | (None, true) => None,
+            (None, false) => Some(String::from_utf8_lossy(&output.stderr)
+                .lines()
+                .rev()
+                .find(|line| !line.trim().is_empty())
+                .map(|line| line.trim_start_matches("❌").trim().to_string())
+                .unwrap_or_else(|| format!("exited with {}", output.status))),
+        };
+        let cancelled = summary.as_ref().is_some_and(|summary| summary.outcome == "cancelled");
+        accounts.push(AccountSummary { profile: name.clone(), summary, error });
+        if cancelled {
+            break;
+        }
     }
     
-    if batch_count > 0 {
-        pb.set_message("Final push...".to_string());
-        git_ops.push_commits()?;
+    if cli.json {
+        println!("{}", serde_json::to_string(&accounts).unwrap_or_default());
+    } else {
+        println!("\n📊 Accounts:");
+        let width = accounts.iter().map(|account| account.profile.len()).max().unwrap_or(0);
+        for account in &accounts {
+            match (&account.summary, &account.error) {
+                (_, Some(error)) => println!("  {:<width$}  {} {}", account.profile, red("❌"), error, width = width),
+                (Some(summary), None) => println!("  {:<width$}  {} {}: {} created of {} planned ({})",
+                    account.profile, green("✅"), summary.outcome, summary.created, summary.planned, summary.repo, width = width),
+                (None, None) => println!("  {:<width$}  {} done", account.profile, green("✅"), width = width),
+            }
+        }
+        if accounts.len() < names.len() {
+            println!("  {}", yellow(format!("🛑 Cancelled before {}", names[accounts.len()..].join(", "))));
+        }
     }
     
-    pb.finish_with_message("✅ All commits created successfully!");
+    let failed = accounts.iter().filter(|account| account.error.is_some()).count();
+    if failed > 0 {
+        return Err(GitHubGridError::Config(format!("{} of {} accounts failed", failed, names.len())));
+    }
     Ok(())
 }
 
-fn init_github_repo(
-    name: Option<String>,
-    force: bool,
-    local_dir: Option<String>,
-) -> Result<()> {
-    println!("🚀 Initializing GitHub repository for commit patterns...");
-    
-    // Create GitHub client
-    let github = GitHubClient::new()?;
-    let username = github.username();
-    println!("📋 GitHub username: {}", username);
+// This invocation's arguments for a single profile's run
+fn account_args(profile: &str) -> Vec<String> {
+    let mut args = Vec::new();
+    let mut skip_value = false;
+    for arg in env::args().skip(1) {
+        if std::mem::take(&mut skip_value) {
+            continue;
+        }
+        match arg.as_str() {
+            "--all-profiles" | "--json" => {}
+            "--profiles" | "--profile" => skip_value = true,
+            _ if arg.starts_with("--profiles=") || arg.starts_with("--profile=") => {}
+            _ => args.push(arg),
+        }
+    }
+    args.extend(["--profile".to_string(), profile.to_string(), "--json".to_string()]);
+    args
+}
+
+fn show_patterns() {
+    println!("Available patterns:");
+    println!("\nActivity levels (commits/year):");
+    println!("  casual      - Weekend warrior, occasional PRs (~300/year)");
+    println!("  realistic   - Professional developer activity (~1,200/year)");
+    println!("  active      - Multiple projects, good practices (~2,500/year)");
+    println!("  maintainer  - Managing repos, reviewing PRs (~5,000/year)");
+    println!("  hyperactive - Startup pace, heavy open source (~12,000/year)");
+    println!("  extreme     - AI-assisted development (~20,000+/year)");
+    println!("  sparse      - 2-4 random days a week, a few commits each (~300/year)");
+    println!("\nLegacy patterns:");
+    println!("  steady      - Consistent daily activity");
+    println!("  sporadic    - Irregular bursts of activity");
+    println!("  contractor  - Mon-Fri focused with occasional weekends");
+    println!("\nDesigns (drawn onto the graph, every day filled):");
+    println!("  gradient    - Light to dark from left to right");
+    println!("  wave        - Bands rising and falling across the year");
+    println!("  checkerboard - Alternating filled and empty days");
+    println!("  stripes     - Diagonal bands");
+    println!("  heart       - A row of hearts");
+    println!("  initials:JD - Letters and digits centered on the graph");
+    println!("\nMessage packs (message_pack = \"<code>\" in a profile):");
+    for (code, language) in messages::packs() {
+        println!("  {:<11} - {}", code, language);
+    }
+    println!("\nHoliday calendars (holidays = \"<code>\" in a profile):");
+    for (code, name) in holidays::countries() {
+        println!("  {:<11} - {}", code, name);
+    }
+}
+
+// Explicit messages win over a pack; English is the default pack
+fn message_pool(profile: &Profile) -> Result<MessagePool> {
+    let pool = match (&profile.messages, &profile.message_pack) {
+        (Some(entries), _) => MessagePool::from_entries(entries)?,
+        (None, Some(pack)) => MessagePool::pack(pack, &Config::messages_dir())?,
+        (None, None) => MessagePool::default(),
+    };
+    Ok(pool.with_daily_limit(profile.message_daily_limit))
+}
+
+fn harvest_messages(cli: &Cli, source: &str, name: &str, limit: usize, author: Option<String>, force: bool) -> Result<()> {
+    let options = HarvestOptions {
+        limit,
+        author,
+        timeout: match cli.git_timeout {
+            0 => None,
+            secs => Some(Duration::from_secs(secs)),
+        },
+    };
+    let pool = harvest::harvest(source, &options, &CancellationToken::on_shutdown_signals()?)?;
+    let path = pool.write_pack(name, &Config::messages_dir(), force)?;
     
-    // Determine repo name
-    let repo_name = name.unwrap_or_else(|| format!("{}-grid", username));
-    println!("📂 Repository name: {}", repo_name);
+    println!("{}", green(format!("✅ Wrote {} messages to {}", pool.messages().count(), path.display())));
+    println!("💡 Review it for anything private, then add to your profile: message_pack = \"{}\"", name);
+    Ok(())
+}
+
+// The profile's messages, or the built-in ones, in config file syntax
+fn show_messages(cli: &Cli) -> Result<()> {
+    let profile = Config::load(cli.config.as_deref())?.profile(cli.profile.as_deref())?;
+    let pool = message_pool(&profile)?;
     
-    // Determine local directory (default: ~/github/repo-name)
-    let home_dir = env::var("HOME").unwrap_or_else(|_| ".".to_string());
-    let local_path = local_dir.unwrap_or_else(|| format!("{}/github/{}", home_dir, repo_name));
-    println!("💾 Local directory: {}", local_path);
+    print!("{}", pool.to_toml());
+    Ok(())
+}
+
+fn init_config(path: &std::path::Path, stdout: bool, force: bool) -> Result<()> {
+    if stdout {
+        print!("{}", config::TEMPLATE);
+        return Ok(());
+    }
+    if path.exists() && !force {
+        return Err(GitHubGridError::Config(format!(
+            "{} already exists (use --force to overwrite, or --stdout to print the template)", path.display()
+        )));
+    }
+    if let Some(dir) = path.parent() {
+        fs::create_dir_all(dir)?;
+    }
+    fs::write(path, config::TEMPLATE)?;
+    println!("📝 Wrote {}", path.display());
+    Ok(())
+}
+
+fn validate_config(path: &std::path::Path) -> Result<()> {
+    let problems = Config::validate(path)?;
+    if problems.is_empty() {
+        println!("{}", green(format!("✅ {} is valid", path.display())));
+        return Ok(());
+    }
+    for problem in &problems {
+        eprintln!("{}", red(problem));
+    }
+    let noun = if problems.len() == 1 { "problem" } else { "problems" };
+    Err(GitHubGridError::Config(format!("{} {} in {}", problems.len(), noun, path.display())))
+}
+
+fn show_version() {
+    println!("github-grid {}", version::VERSION);
+    println!("  Commit: {}", version::COMMIT);
+    println!("  Built: {}", version::BUILD_DATE);
+    println!("  State format: {}", STATE_FORMAT_VERSION);
+}
+
+// Where generated commits write and what: the activity log, content and
+// assets, all under the grid directory. Flags first, then the profile
+struct GeneratedFiles {
+    grid_dir: String,
+    activity_log: Option<String>,
+    activity_template: String,
+    content: Vec<ContentKind>,
+    assets: bool,
+}
+
+impl GeneratedFiles {
+    fn resolve(cli: &Cli, profile: &Profile) -> Result<Self> {
+        let grid_dir = cli.grid_dir.clone().or(profile.grid_dir.clone()).unwrap_or_else(|| GRID_DIR.to_string());
+        let template = cli.activity_template.clone().or(profile.activity_template.clone());
+        let activity_log = cli.activity_log_file.clone()
+            .or(profile.activity_log_file.clone())
+            .or_else(|| {
+                let on = cli.activity_log || profile.activity_log.unwrap_or(false) || template.is_some();
+                on.then(|| ACTIVITY_LOG.to_string())
+            });
+        let activity_template = template.unwrap_or_else(|| activity::DEFAULT_TEMPLATE.to_string());
+        let problem = content::path_problem("grid_dir", &grid_dir)
+            .or_else(|| activity_log.as_deref().and_then(|log| activity::path_problem("activity_log_file", log)))
+            .or_else(|| activity::template_problem("activity_template", &activity_template));
+        if let Some(problem) = problem {
+            return Err(GitHubGridError::Config(problem));
+        }
+        
+        let content = match cli.content.is_empty() {
+            true => profile.content.clone().unwrap_or_default(),
+            false => cli.content.clone(),
+        };
+        let assets = cli.assets || profile.assets.unwrap_or(false);
+        if assets && content.is_empty() {
+            return Err(GitHubGridError::Config("--assets needs --content: assets are added in place of some of its changes".to_string()));
+        }
+        Ok(Self { grid_dir, activity_log, activity_template, content, assets })
+    }
     
-    // Check if repo exists on GitHub
-    let repo_exists = github.repo_exists(&repo_name)?;
+    // Binary assets belong in LFS where a repository uses it, and generated
+    // commits can't put them there, so they're left out
+    fn apply(&self, git_ops: &mut GitOperations) {
+        let assets = self.assets && !git_ops.layout().lfs;
+        if self.assets && !assets {
+            status!("{}", yellow("⚠️  The repository uses Git LFS, so --assets is off: generated commits can't store binaries through it"));
+        }
+        git_ops.set_grid_dir(self.grid_dir.clone());
+        git_ops.set_activity_log(self.activity_log.clone());
+        git_ops.set_activity_template(self.activity_template.clone());
+        git_ops.set_content(self.content.clone());
+        git_ops.set_assets(assets);
+    }
+}
+
+// Git operations on a repository of this run, set up from the flags and
+// the profile
+fn git_operations(repo: Repository, cli: &Cli, profile: &Profile, committer_date: CommitterDate, generated: &GeneratedFiles) -> GitOperations {
+    let mut git_ops = GitOperations::new(repo);
+    git_ops.set_command_timeout(match cli.git_timeout {
+        0 => None,
+        secs => Some(Duration::from_secs(secs)),
+    });
+    git_ops.set_skip_push_hooks(cli.no_verify_push);
+    git_ops.set_message_suffix(cli.skip_ci.clone());
+    git_ops.set_identity(profile.name.clone(), profile.email.clone());
+    git_ops.set_committer_date(committer_date);
+    generated.apply(&mut git_ops);
+    git_ops
+}
+
+// Flags first, then the profile
+fn committer_date(cli: &Cli, profile: &Profile) -> Result<CommitterDate> {
+    Ok(match (cli.real_committer_date, cli.committer_jitter, &profile.committer_jitter) {
+        (true, _, _) => CommitterDate::Now,
+        (false, Some(jitter), _) => CommitterDate::Jitter(jitter),
+        _ if profile.real_committer_date.unwrap_or(false) => CommitterDate::Now,
+        (false, None, Some(jitter)) => CommitterDate::Jitter(humantime::parse_duration(jitter).map_err(|e| {
+            GitHubGridError::Config(format!("Invalid committer_jitter '{}': {}", jitter, e))
+        })?),
+        (false, None, None) => CommitterDate::Author,
+    })
+}
+
+// Generated commits left unpushed by a run that crashed or lost the network
+// without recording it. Offer to push them (the default with --yes) or, when
+// nothing else is unpushed, to discard them; otherwise the run pushes them
+// along with its own commits
+fn recover_unpushed(git_ops: &mut GitOperations, yes: bool) -> Result<()> {
+    let (generated, all) = git_ops.unpushed_commits()?;
+    if generated == 0 {
+        return Ok(());
+    }
+    status!("{}", yellow(format!("⚠️  {} generated commits on {} were never pushed", generated, MAIN_BRANCH)));
     
-    if repo_exists {
-        if force {
-            println!("⚠️  Repository exists, deleting due to --force flag...");
-            // Remove local directory first to avoid clone conflicts
-            if PathBuf::from(&local_path).exists() {
-                fs::remove_dir_all(&local_path)?;
-                println!("🗑️  Removed local directory");
+    if yes || confirm("Push them now?")? {
+        match git_ops.push_commits() {
+            Ok(()) => status!("✅ Unpushed commits pushed"),
+            // Offline: queue them like a run does, to be flushed later
+            Err(GitHubGridError::Network(msg) | GitHubGridError::Timeout(msg)) => {
+                let mut state = RunState::load(git_ops.repo())?;
+                state.mark_pending_push(SystemClock.now());
+                state.save(git_ops.repo())?;
+                status!("{}", yellow(format!("📴 Offline, push queued for a later run: {}", msg)));
             }
-            github.delete_repo(&repo_name)?;
-        } else {
-            println!("✅ Repository already exists: https://github.com/{}/{}", username, repo_name);
-            println!("💡 Use --force to recreate or update the existing repo");
-            
-            // Check if local clone exists
-            if PathBuf::from(&local_path).exists() {
-                println!("📁 Local clone already exists at: {}", local_path);
-                println!("🎯 Ready to use!");
-                return Ok(());
-            } else {
-                println!("📥 Cloning existing repository...");
-                github.clone_repo(&repo_name, &local_path)?;
-                
-                // Check if repo needs initialization (empty repo)
-                let repo = Repository::open(&local_path)?;
-                if repo.is_empty()? {
-                    println!("🔧 Repository is empty, initializing...");
-                    initialize_repo(&repo, &local_path)?;
-                }
-                
-                println!("🎯 Ready to use!");
-                return Ok(());
+            Err(e) => return Err(e),
+        }
+        return Ok(());
+    }
+    if generated == all && confirm("Discard them instead?")? {
+        if let Some(remote) = git_ops.remote_main() {
+            if let Some(backup) = git_ops.reset_main(remote)? {
+                status!("💾 Discarded commits saved as {}", backup);
             }
+            let mut state = RunState::load(git_ops.repo())?;
+            state.checkpoint = None;
+            state.save(git_ops.repo())?;
+            status!("🗑️  {} reset to origin/{}", MAIN_BRANCH, MAIN_BRANCH);
         }
+        return Ok(());
+    }
+    status!("Keeping them; they'll be pushed with this run");
+    Ok(())
+}
+
+// Only commits on the default branch count on the graph, so a run is wasted
+// while origin's HEAD points anywhere but main. Asks to switch it on GitHub;
+// with --yes (or when the answer is no) it only warns
+fn check_default_branch(git_ops: &GitOperations, yes: bool) -> Result<()> {
+    // Unreadable or not set yet: nothing to go on, and the push still works
+    let Ok(Some(branch)) = git_ops.remote_default_branch() else {
+        return Ok(());
+    };
+    if branch == MAIN_BRANCH {
+        return Ok(());
     }
     
-    // Create new private repository
-    println!("🏗️  Creating private repository...");
-    github.create_repo(&repo_name)?;
+    let origin = git_ops.remote_slug().unwrap_or_else(|| "origin".to_string());
+    status!("{}", yellow(format!(
+        "⚠️  The default branch of {} is '{}', but commits are made on '{}' and only the default branch counts on the graph",
+        origin, branch, MAIN_BRANCH
+    )));
+    let Some(slug) = git_ops.remote_slug() else {
+        return Ok(());
+    };
+    if yes || !confirm(&format!("Make '{}' the default branch of {}?", MAIN_BRANCH, slug))? {
+        return Ok(());
+    }
+    // main may not exist on GitHub yet; the run goes ahead either way
+    match GitHubClient::new().and_then(|github| github.set_default_branch(&slug, MAIN_BRANCH)) {
+        Ok(()) => status!("✅ '{}' is now the default branch of {}", MAIN_BRANCH, slug),
+        Err(e) => status!("{}", yellow(format!(
+            "⚠️  {}. Change it under Settings → General → Default branch", e
+        ))),
+    }
+    Ok(())
+}
+
+// Best-effort look at the repository on GitHub before a run fills it, for
+// the two ways its commits end up somewhere other than the user's graph: a
+// fork, whose commits don't count, and a repository of another account or of
+// an organization the user isn't in. The latter asks to go on unless `yes`.
+// Without gh or a GitHub origin there's nothing to ask. Returns false when
+// the user chose to stop
+fn check_github_repo(git_ops: &GitOperations, yes: bool) -> Result<bool> {
+    let Some(slug) = git_ops.remote_slug() else {
+        return Ok(true);
+    };
+    let Ok(github) = GitHubClient::new() else {
+        return Ok(true);
+    };
+    
+    if let Ok(settings) = github.repo_settings(&slug) {
+        if settings.fork {
+            let parent = settings.parent.map(|parent| format!(" of {}", parent)).unwrap_or_default();
+            status!("{}", yellow(format!(
+                "⚠️  {} is a fork{}. Commits in a fork only count on your graph once they're merged into the parent \
+                 repository through a pull request, so these won't show up. Use a repository of your own, or ask \
+                 GitHub Support to detach the fork",
+                slug, parent
+            )));
+        }
+    }
     
-    // Clone the repository locally
-    println!("📥 Cloning repository...");
-    github.clone_repo(&repo_name, &local_path)?;
-    let repo = Repository::open(&local_path)?;
+    let owner = slug.split('/').next().unwrap_or_default();
+    if owner.eq_ignore_ascii_case(github.username()) {
+        return Ok(true);
+    }
+    // Commits in an organization's repositories count for its members; when
+    // the memberships can't be read, don't second-guess
+    let Ok(organizations) = github.organizations() else {
+        return Ok(true);
+    };
+    if organizations.iter().any(|organization| organization.eq_ignore_ascii_case(owner)) {
+        return Ok(true);
+    }
+    status!("{}", yellow(format!(
+        "⚠️  {} belongs to {}, which is neither you ({}) nor one of your organizations, so the commits would land \
+         in someone else's repository. Check origin, or which gh account is signed in (gh auth status)",
+        slug, owner, github.username()
+    )));
+    Ok(yes || confirm(&format!("Generate commits in {} anyway?", slug))?)
+}
+
+// Check origin is a private GitHub repository, switching it to private when
+// `make_private` is set (a dry run only reports it)
+fn ensure_private(git_ops: &GitOperations, make_private: bool, dry_run: bool) -> Result<()> {
+    let slug = git_ops.remote_slug().ok_or_else(|| GitHubGridError::Config(
+        "origin is not a GitHub repository, so its visibility can't be checked".to_string()
+    ))?;
+    let github = GitHubClient::new()?;
+    if github.repo_settings(&slug)?.private {
+        status!("🔒 {} is private", slug);
+    } else if make_private && dry_run {
+        status!("🔓 {} is public and would be made private", slug);
+    } else if make_private {
+        github.make_private(&slug)?;
+        status!("🔒 {} is now private", slug);
+    } else {
+        return Err(GitHubGridError::Config(format!(
+            "{} is public. Make it private on GitHub or pass --make-private", slug
+        )));
+    }
+    Ok(())
+}
+
+// Restore main from a backup ref, force-pushing it when --force-push is given
+fn rollback_to_backup(git_ops: &mut GitOperations, gate: &ForcePushGate, backup: Option<String>, list: bool, force_push: bool, yes: bool) -> Result<()> {
+    let backups = git_ops.backups()?;
+    if list {
+        if backups.is_empty() {
+            println!("No backups yet");
+        }
+        for (name, target) in &backups {
+            let id = target.to_string();
+            println!("{}  {}", &id[..id.len().min(8)], name.trim_start_matches(BACKUP_REF_PREFIX));
+        }
+        return Ok(());
+    }
     
-    // Initialize with empty commit
-    initialize_repo(&repo, &local_path)?;
+    let chosen = match &backup {
+        Some(wanted) => backups.iter().rev().find(|(name, _)| {
+            name == wanted || name.trim_start_matches(BACKUP_REF_PREFIX) == wanted
+        }),
+        None => backups.last(),
+    };
+    let Some((name, target)) = chosen.cloned() else {
+        return Err(GitHubGridError::Config(match backup {
+            Some(wanted) => format!("No backup named '{}'; see rollback --list", wanted),
+            None => "No backups to roll back to".to_string(),
+        }));
+    };
     
-    println!("✅ Repository setup complete!");
-    println!("🌐 GitHub: https://github.com/{}/{}", username, repo_name);
-    println!("📁 Local: {}", local_path);
-    println!();
-    println!("🎯 Usage:");
-    println!("  ./target/release/github-grid --target-total 5000");
-    println!("  ./target/release/github-grid --pattern active");
-    println!("  ./target/release/github-grid --dry-run");
+    if force_push {
+        gate.ensure_allowed()?;
+    }
+    if !yes && !confirm(&format!("Reset {} to {}?", MAIN_BRANCH, name))? {
+        println!("Aborted, nothing was changed");
+        return Ok(());
+    }
+    
+    if let Some(previous) = git_ops.reset_main(target)? {
+        status!("💾 Previous {} saved as {}", MAIN_BRANCH, previous);
+    }
+    let mut state = RunState::load(git_ops.repo())?;
+    state.checkpoint = None;
+    state.save(git_ops.repo())?;
+    println!("{}", green(format!("✅ {} reset to {}", MAIN_BRANCH, name)));
     
+    if force_push {
+        git_ops.force_push(gate)?;
+    } else {
+        println!("Local only; rerun with --force-push to update origin as well");
+    }
     Ok(())
 }
 
-fn initialize_repo(repo: &Repository, local_path: &str) -> Result<()> {
-    let repo_path = PathBuf::from(local_path);
+// Remove generated commits from main and force-push the result
+fn clean_history(git_ops: &mut GitOperations, gate: &ForcePushGate, start: Option<String>, end: Option<String>, dry_run: bool, yes: bool) -> Result<()> {
+    let start = match start {
+        Some(date_str) => NaiveDate::parse_from_str(&date_str, "%Y-%m-%d")?,
+        None => NaiveDate::from_ymd_opt(1970, 1, 1).unwrap(),
+    };
+    let end = match end {
+        Some(date_str) => NaiveDate::parse_from_str(&date_str, "%Y-%m-%d")?,
+        None => SystemClock.today(),
+    };
     
-    // Create initial README
-    let readme_content = "# GitHub Contribution Grid\n\nThis repository contains generated commit patterns for GitHub contribution graphs.\n";
-    fs::write(repo_path.join("README.md"), readme_content)?;
+    let count: u32 = git_ops.autogen_commits_per_day(start, end)?.values().sum();
+    if count == 0 {
+        println!("{}", green("✅ No generated commits to remove"));
+        return Ok(());
+    }
+    if dry_run {
+        println!("Would remove {} generated commits from {} to {}", count, start, end);
+        return Ok(());
+    }
     
-    // Stage the README
-    let mut index = repo.index()?;
-    index.add_path(std::path::Path::new("README.md"))?;
-    index.write()?;
+    gate.ensure_allowed()?;
+    if !yes && !confirm(&format!("Remove {} generated commits from {} to {}?", count, start, end))? {
+        println!("Aborted, nothing was changed");
+        return Ok(());
+    }
     
-    // Create tree
-    let tree_id = index.write_tree()?;
-    let tree = repo.find_tree(tree_id)?;
+    status!("🧹 Dropping generated commits from {} to {}", start, end);
+    let dropped = git_ops.drop_autogen_commits(start, end)?;
     
-    // Create signature
-    let sig = Signature::now("GitHub Grid", "github-grid@example.com")?;
+    // A checkpoint may point at commits that are gone now
+    let mut state = RunState::load(git_ops.repo())?;
+    state.checkpoint = None;
+    state.save(git_ops.repo())?;
     
-    // Create initial commit
-    repo.commit(
-        Some("HEAD"),
-        &sig,
-        &sig,
-        "Initial commit: Setup repository for grid patterns",
-        &tree,
-        &[],
-    )?;
+    git_ops.force_push(gate)?;
+    println!("{}", green(format!("✅ Removed {} generated commits", dropped)));
+    Ok(())
+}
+
+fn show_daemon_status(addr: SocketAddr) -> Result<()> {
+    let status = daemon::fetch_status(addr)?;
+    let format = |time: Option<chrono::DateTime<chrono::Local>>| {
+        time.map(|time| time.format("%Y-%m-%d %H:%M:%S").to_string())
+            .unwrap_or_else(|| "never".to_string())
+    };
     
-    // Push to GitHub using our git operations
-    let mut git_ops = GitOperations::new(Repository::open(local_path)?);
-    git_ops.push_commits()?;
+    println!("🕒 Daemon at {}", cyan(addr));
+    println!("  Started:   {}", format(status.started));
+    println!("  Last run:  {}", format(status.last_run));
+    println!("  Last push: {}", format(status.last_push));
+    println!("  Next run:  {}", format(status.next_run));
+    if status.paused {
+        println!("  {}", yellow("⏸️  Paused: scheduled cycles are skipped until resumed"));
+    }
+    if status.pending_push {
+        println!("  {}", yellow("📴 Push pending (offline)"));
+    }
+    if status.errors.is_empty() {
+        println!("  {}", green("No pending errors"));
+    } else {
+        println!("  {}", red(format!("{} pending error(s):", status.errors.len())));
+        for error in &status.errors {
+            println!("    {}", error);
+        }
+    }
+    Ok(())
+}
+
+fn show_daemon_plan(addr: SocketAddr) -> Result<()> {
+    let plan = daemon::fetch_plan(addr)?;
+    let now = chrono::Local::now();
+    let due = plan.commits.iter().filter(|commit| commit.date <= now).count();
     
+    println!("\n📋 Today's plan: {} commits, {} due so far", plan.len(), due);
+    for commit in &plan.commits {
+        let mark = if commit.date <= now { green("✓") } else { "·".to_string() };
+        println!("  {} {} {}", mark, commit.date.format("%H:%M"), commit.message);
+    }
     Ok(())
 }
 
-fn count_existing_commits(git_ops: &GitOperations, year: i32) -> Result<u32> {
-    let repo_path = git_ops.repo().workdir().unwrap();
-    let output = std::process::Command::new("git")
-        .current_dir(repo_path)
-        .args(&[
-            "log",
-            "--oneline",
-            &format!("--since={}-01-01", year),
-            &format!("--until={}-12-31", year),
-        ])
-        .output()
-        .map_err(|e| GitHubGridError::Io(e))?;
+fn inspect_plan(path: &std::path::Path) -> Result<()> {
+    let plan = Plan::load(path)?;
+    let per_day = plan.per_day();
     
-    if !output.status.success() {
-        return Ok(0); // Empty repo or no commits in range
+    println!("📋 Plan: {}", path.display());
+    println!("Range: {} to {} ({} days)", plan.start, plan.end, plan.days());
+    println!("Seed: {}", plan.seed);
+    println!("Commits: {} on {} days (max {} per day)",
+        plan.len(), per_day.len(), per_day.values().max().copied().unwrap_or(0));
+    let per_year = plan.per_year();
+    if per_year.len() > 1 {
+        for (year, count) in &per_year {
+            println!("  {}: {} commits", year, count);
+        }
     }
     
-    let commit_lines = String::from_utf8_lossy(&output.stdout);
-    let count = commit_lines.lines().count() as u32;
+    graph::show_graph(&plan.commits, plan.end, &BTreeMap::new());
     
-    Ok(count)
+    let problems = plan.problems();
+    if problems.is_empty() {
+        println!("{}", green("✅ Plan is consistent"));
+        return Ok(());
+    }
+    for problem in &problems {
+        println!("  {} {}", red("✗"), problem);
+    }
+    Err(GitHubGridError::Parse(format!("{} problems found in {}", problems.len(), path.display())))
+}
+
+fn edit_plan(path: &std::path::Path) -> Result<()> {
+    let mut plan = Plan::load(path)?;
+    if !editor::edit(&mut plan)? {
+        println!("No changes made to {}", path.display());
+        return Ok(());
+    }
+    plan.save(path)?;
+    println!("{}", green(format!("✅ Saved {} commits on {} days to {}", plan.len(), plan.per_day().len(), path.display())));
+    Ok(())
 }
 
+// What is about to happen, shown before anything is committed
+fn show_plan_summary(plan: &PlanOutline, repo_path: &std::path::Path, git_ops: &GitOperations) {
+    println!("\n📋 {}", bold("Plan:"));
+    println!("  {} commits over {} days ({} to {})", bold(plan.len()), plan.days(), plan.start, plan.end);
+    let years = plan.per_year();
+    if years.len() > 1 {
+        for (year, count) in years {
+            println!("    {}: {} commits", year, count);
+        }
+    }
+    println!("  Repository: {}", cyan(repo_path.display()));
+    println!("  Branch: {} -> origin ({})", cyan(MAIN_BRANCH),
+        git_ops.remote_url().unwrap_or_else(|| "no origin remote".to_string()));
+    println!();
+}
 
-fn calibrate_pattern_for_target(commits_needed: u32, days_in_range: i64) -> PatternConfig {
-    let avg_per_day = commits_needed as f64 / days_in_range as f64;
+// What the plan does to the graph, to judge whether it looks plausible: how
+// busy the range gets and the total the profile will show for the last year.
+// Only counts this repository; contributions elsewhere come on top
+fn show_impact(plan: &PlanOutline, git_ops: &GitOperations, regenerate: bool) -> Result<()> {
+    let per_day = &plan.per_day;
+    let weeks = Grid::new(plan.start, plan.end).columns;
+    let busiest = per_day.values().max().copied().unwrap_or(0);
     
-    // Choose intensity level based on required daily average
-    // Calibrated for aggressive spike system: 0.7x accounts for frequent high spikes  
-    let target_avg = avg_per_day * 0.7;  // Conservative: two-tier spikes significantly boost output
+    let today = SystemClock.today();
+    let window = Grid::rolling(today);
+    let mut year_total: usize = git_ops.real_commits_per_day(window.start(), today)?.values().sum::<u32>() as usize;
+    let mut generated = git_ops.autogen_commits_per_day(window.start(), today)?;
+    if regenerate {
+        // Those are replaced by the plan
+        generated.retain(|day, _| *day < plan.start || *day > plan.end);
+    }
+    year_total += generated.values().sum::<u32>() as usize;
+    year_total += per_day.range(window.start()..=today).map(|(_, count)| count).sum::<usize>();
     
-    let intensity = if target_avg < 5.0 {
-        IntensityLevel::Casual
-    } else if target_avg < 15.0 {
-        IntensityLevel::Active  
-    } else if target_avg < 30.0 {
-        IntensityLevel::Maintainer
-    } else if target_avg < 50.0 {
-        IntensityLevel::Hyperactive
-    } else {
-        IntensityLevel::Extreme
+    println!("📈 {}", bold("Impact:"));
+    println!("  {} commits across {} weeks, {} active days", plan.len(), weeks, per_day.len());
+    println!("  {:.1} commits/day on average ({:.1} per active day, busiest day {})",
+        plan.len() as f64 / plan.days().max(1) as f64,
+        plan.len() as f64 / per_day.len().max(1) as f64,
+        busiest);
+    println!("  Last-year total on your profile from this repository: {}", bold(year_total));
+    println!();
+    Ok(())
+}
+
+// The repository a run works on, and the path it's reported by: --git-dir
+// (with --work-tree) the way git takes them, else --repo or the profile's,
+// else $GIT_DIR (and $GIT_WORK_TREE) when set, else the default
+fn open_repository(git_dir: Option<&std::path::Path>, work_tree: Option<&std::path::Path>, repo: Option<PathBuf>) -> Result<(Repository, PathBuf)> {
+    let repo = match (git_dir, repo) {
+        (Some(git_dir), _) => {
+            let repo = Repository::open(git_dir)?;
+            if let Some(work_tree) = work_tree {
+                repo.set_workdir(work_tree, false)?;
+            }
+            repo
+        }
+        (None, None) if env::var_os("GIT_DIR").is_some() => Repository::open_from_env()?,
+        (None, repo) => {
+            let path = resolve_repo_path(repo)?;
+            return Ok((Repository::open(&path)?, path));
+        }
     };
-    
-    // Create pattern config with enhanced variance for target hitting
-    // More realistic vacation frequencies
-    let vacation_freq = match intensity {
-        IntensityLevel::Casual => 0.05,     // More time off
-        IntensityLevel::Active => 0.035,    // Regular breaks
-        IntensityLevel::Maintainer => 0.025, // Still needs breaks
-        IntensityLevel::Hyperactive => 0.02,  // Less but still important
-        IntensityLevel::Extreme => 0.015,    // Rare but necessary
+    // Bare without a working tree: the git directory is all there is
+    let path = repo.workdir().unwrap_or(repo.path()).to_path_buf();
+    Ok((repo, path))
+}
+
+// Repository from --repo or the profile, else ~/github/<username>-grid
+fn resolve_repo_path(repo: Option<PathBuf>) -> Result<PathBuf> {
+    match repo {
+        Some(path) => Ok(path),
+        None => {
+            // Get username dynamically for default path
+            let home_dir = env::var("HOME").unwrap_or_else(|_| ".".to_string());
+            let github = GitHubClient::new()?;
+            let username = github.username();
+            Ok(PathBuf::from(format!("{}/github/{}-grid", home_dir, username)))
+        }
+    }
+}
+
+fn install_service(cli: &Cli, mode: ServiceMode, interval: Duration, uninstall: bool) -> Result<()> {
+    // One service per profile so several accounts can run side by side
+    let name = match cli.profile.as_deref() {
+        Some(profile) => format!("github-grid-{}", profile),
+        None => "github-grid".to_string(),
     };
     
-    // More aggressive spike probability for dramatic variance
-    let spike_prob = match intensity {
-        IntensityLevel::Casual => 0.25,
-        IntensityLevel::Active => 0.32,
-        IntensityLevel::Maintainer => 0.38,
-        IntensityLevel::Hyperactive => 0.42,
-        IntensityLevel::Extreme => 0.48,
+    if uninstall {
+        let removed = service::uninstall(&name)?;
+        if removed.is_empty() {
+            println!("Nothing to uninstall: no '{}' service found", name);
+        }
+        for path in removed {
+            println!("🗑️  Removed {}", path.display());
+        }
+        return Ok(());
+    }
+    
+    // Services run from a different working directory, so pin absolute paths
+    let profile = Config::load(cli.config.as_deref())?.profile(cli.profile.as_deref())?;
+    let mut args = match &cli.git_dir {
+        Some(git_dir) => {
+            let mut args = vec!["--git-dir".to_string(), fs::canonicalize(git_dir)?.display().to_string()];
+            if let Some(work_tree) = &cli.work_tree {
+                args.extend(["--work-tree".to_string(), fs::canonicalize(work_tree)?.display().to_string()]);
+            }
+            args
+        }
+        None => {
+            let repo_path = fs::canonicalize(resolve_repo_path(cli.repo.clone().or(profile.repo))?)?;
+            vec!["--repo".to_string(), repo_path.display().to_string()]
+        }
     };
+    if let Some(config) = &cli.config {
+        args.extend(["--config".to_string(), fs::canonicalize(config)?.display().to_string()]);
+    }
+    if let Some(profile) = &cli.profile {
+        args.extend(["--profile".to_string(), profile.clone()]);
+    }
+    if let Some(pattern) = &cli.pattern {
+        args.extend(["--pattern".to_string(), pattern.clone()]);
+    }
+    if let Some(seed) = cli.seed {
+        args.extend(["--seed".to_string(), seed.to_string()]);
+    }
+    
+    let written = ServiceSpec { name: name.clone(), mode, interval, args }.install()?;
+    for path in written {
+        println!("📝 Wrote {}", path.display());
+    }
+    println!("{}", green(format!("✅ Service '{}' installed and started", name)));
+    println!("💡 Remove it with: github-grid{} install-service --uninstall",
+        cli.profile.as_deref().map(|profile| format!(" --profile {}", profile)).unwrap_or_default());
+    Ok(())
+}
+
+fn init_actions(cli: &Cli, cron: String, source: String, force: bool) -> Result<()> {
+    // Fail early on a schedule GitHub would reject
+    cron.parse::<CronSchedule>()?;
+    
+    let profile = Config::load(cli.config.as_deref())?.profile(cli.profile.as_deref())?;
+    let repo_path = resolve_repo_path(cli.repo.clone().or(profile.repo))?;
+    let pattern = cli.pattern.clone().or(profile.pattern);
+    let path = WorkflowSpec { cron, source, pattern }.write(&repo_path, force)?;
+    
+    println!("{}", green(format!("✅ Wrote {}", path.display())));
+    println!();
+    println!("🎯 Next steps:");
+    println!("  1. Set repository variables GRID_AUTHOR_NAME and GRID_AUTHOR_EMAIL (Settings > Secrets and variables > Actions)");
+    println!("     The email must be verified on your GitHub account for commits to count");
+    println!("  2. Optionally set GRID_SEED, and a GRID_TOKEN secret if the default token can't push to main");
+    println!("  3. Commit and push the workflow:");
+    println!("     git -C {} add {} && git -C {} commit -m 'Add github-grid workflow' && git -C {} push",
+        repo_path.display(), WORKFLOW_PATH, repo_path.display(), repo_path.display());
+    Ok(())
+}
+
+fn init_github_repo(
+    cli: &Cli,
+    name: Option<String>,
+    force: bool,
+    local_dir: Option<String>,
+) -> Result<()> {
+    status!("🚀 Initializing GitHub repository for commit patterns...");
+    
+    // Create GitHub client
+    let github = GitHubClient::new()?;
+    let username = github.username();
+    status!("📋 GitHub username: {}", username);
+    
+    // Determine repo name
+    let repo_name = name.unwrap_or_else(|| format!("{}-grid", username));
+    status!("📂 Repository name: {}", repo_name);
+    
+    // Determine local directory (default: ~/github/repo-name)
+    let home_dir = env::var("HOME").unwrap_or_else(|_| ".".to_string());
+    let local_path = local_dir.unwrap_or_else(|| format!("{}/github/{}", home_dir, repo_name));
+    status!("💾 Local directory: {}", local_path);
+    
+    // Check if repo exists on GitHub
+    let repo_exists = github.repo_exists(&repo_name)?;
     
-    PatternConfig {
-        intensity,
-        use_weekly_rhythm: true,
-        vacation_frequency: vacation_freq,
-        vacation_duration: (2, 8),  // Longer, more realistic breaks
-        spike_probability: spike_prob,
-        spike_multiplier: 3.5,  // Much more dramatic spikes for release/deadline days
+    if repo_exists && force {
+        status!("{}", yellow("⚠️  Repository exists, deleting due to --force flag..."));
+        // Remove local directory first to avoid clone conflicts
+        if PathBuf::from(&local_path).exists() {
+            fs::remove_dir_all(&local_path)?;
+            status!("🗑️  Removed local directory");
+        }
+        github.delete_repo(&repo_name)?;
+    }
+    
+    if repo_exists && !force {
+        println!("✅ Repository already exists: https://github.com/{}/{}", username, repo_name);
+        status!("💡 Use --force to recreate or update the existing repo");
+        
+        // Check if local clone exists
+        if PathBuf::from(&local_path).exists() {
+            println!("📁 Local clone already exists at: {}", local_path);
+        } else {
+            status!("📥 Cloning existing repository...");
+            github.clone_repo(&repo_name, &local_path)?;
+        }
+    } else {
+        // Create new private repository
+        status!("🏗️  Creating private repository...");
+        github.create_repo(&repo_name)?;
+        
+        // Clone the repository locally
+        status!("📥 Cloning repository...");
+        github.clone_repo(&repo_name, &local_path)?;
+    }
+    let mut git_ops = GitOperations::new(Repository::open(&local_path)?);
+    
+    // Identity first, so the initial commit already counts
+    let email = if cli.use_noreply { Some(github.noreply_email()?) } else { cli.set_email.clone() };
+    if cli.set_name.is_some() || email.is_some() {
+        git_ops.configure_identity(cli.set_name.as_deref(), email.as_deref())?;
+        status!("🪪 Commit identity saved in the repository's git config");
+    }
+    if let Err(e) = git_ops.check_author_email() {
+        status!("{}", yellow(format!("⚠️  {}", e)));
     }
+    
+    // Initialize with README and .gitignore on main, unless it has history
+    if git_ops.repo().is_empty()? {
+        status!("🔧 Repository is empty, initializing...");
+        git_ops.initialize_repository()?;
+    }
+    
+    // Remember the clone so runs find it without --repo
+    let repo_path = fs::canonicalize(&local_path)?;
+    let config_path = cli.config.clone().unwrap_or_else(Config::default_path);
+    match Config::save_repo(cli.config.as_deref(), cli.profile.as_deref(), &repo_path)? {
+        Some(profile) => status!("📝 Saved as profile '{}' in {}", profile, config_path.display()),
+        None => status!("💡 The profile already names a repository in {}; left unchanged", config_path.display()),
+    }
+    
+    println!("{}", green("✅ Repository setup complete!"));
+    println!("🌐 GitHub: https://github.com/{}/{}", username, repo_name);
+    println!("📁 Local: {}", local_path);
+    println!();
+    status!("🎯 Usage:");
+    status!("  ./target/release/github-grid --target-total 5000");
+    status!("  ./target/release/github-grid --pattern active");
+    status!("  ./target/release/github-grid --dry-run");
+    
+    Ok(())
 }
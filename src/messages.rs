@@ -0,0 +1,390 @@
+use rand::Rng;
+use rand_chacha::ChaCha8Rng;
+use serde::Deserialize;
+use std::collections::HashMap;
+use std::fs;
+use std::path::{Path, PathBuf};
+use crate::error::{GitHubGridError, Result};
+
+// Every generated subject starts with this; it is how earlier generated
+// commits are found again when continuing a pattern
+pub const AUTOGEN_MARKER: &str = "[AutoGen]";
+
+const EN_MESSAGES: &[&str] = &[
+    "Add new feature implementation",
+    "Fix critical bug in core logic",
+    "Refactor existing codebase",
+    "Add comprehensive tests",
+    "Update documentation",
+    "Optimize performance bottleneck",
+    "Implement user feedback",
+    "Fix merge conflicts",
+    "Add error handling",
+    "Update dependencies",
+    "Clean up code structure",
+    "Add logging and monitoring",
+    "Fix security vulnerability",
+    "Improve user interface",
+    "Add API endpoints",
+    "Fix failing tests",
+    "Add database migrations",
+    "Improve code coverage",
+    "Add configuration options",
+    "Fix production issue",
+];
+
+const DE_MESSAGES: &[&str] = &[
+    "Neue Funktion implementiert",
+    "Kritischen Fehler in der Kernlogik behoben",
+    "Bestehenden Code refaktoriert",
+    "Umfassende Tests hinzugefügt",
+    "Dokumentation aktualisiert",
+    "Performance-Engpass optimiert",
+    "Nutzerfeedback umgesetzt",
+    "Merge-Konflikte behoben",
+    "Fehlerbehandlung ergänzt",
+    "Abhängigkeiten aktualisiert",
+    "Codestruktur aufgeräumt",
+    "Logging und Monitoring ergänzt",
+    "Sicherheitslücke geschlossen",
+    "Benutzeroberfläche verbessert",
+    "API-Endpunkte hinzugefügt",
+    "Fehlschlagende Tests repariert",
+    "Datenbankmigrationen hinzugefügt",
+    "Testabdeckung erhöht",
+    "Konfigurationsoptionen ergänzt",
+    "Produktionsproblem behoben",
+];
+
+const ES_MESSAGES: &[&str] = &[
+    "Añadir implementación de nueva funcionalidad",
+    "Corregir error crítico en la lógica principal",
+    "Refactorizar el código existente",
+    "Añadir pruebas completas",
+    "Actualizar documentación",
+    "Optimizar cuello de botella de rendimiento",
+    "Implementar comentarios de usuarios",
+    "Resolver conflictos de merge",
+    "Añadir manejo de errores",
+    "Actualizar dependencias",
+    "Limpiar la estructura del código",
+    "Añadir logs y monitorización",
+    "Corregir vulnerabilidad de seguridad",
+    "Mejorar la interfaz de usuario",
+    "Añadir endpoints de la API",
+    "Arreglar pruebas fallidas",
+    "Añadir migraciones de base de datos",
+    "Mejorar la cobertura de código",
+    "Añadir opciones de configuración",
+    "Corregir incidencia en producción",
+];
+
+const FR_MESSAGES: &[&str] = &[
+    "Ajout d'une nouvelle fonctionnalité",
+    "Correction d'un bug critique dans la logique principale",
+    "Refactorisation du code existant",
+    "Ajout de tests complets",
+    "Mise à jour de la documentation",
+    "Optimisation d'un goulot d'étranglement",
+    "Prise en compte des retours utilisateurs",
+    "Résolution des conflits de fusion",
+    "Ajout de la gestion des erreurs",
+    "Mise à jour des dépendances",
+    "Nettoyage de la structure du code",
+    "Ajout de logs et de monitoring",
+    "Correction d'une faille de sécurité",
+    "Amélioration de l'interface utilisateur",
+    "Ajout de points d'accès API",
+    "Correction des tests en échec",
+    "Ajout de migrations de base de données",
+    "Amélioration de la couverture de code",
+    "Ajout d'options de configuration",
+    "Correction d'un incident en production",
+];
+
+const PT_MESSAGES: &[&str] = &[
+    "Adiciona implementação de nova funcionalidade",
+    "Corrige bug crítico na lógica principal",
+    "Refatora código existente",
+    "Adiciona testes abrangentes",
+    "Atualiza documentação",
+    "Otimiza gargalo de desempenho",
+    "Implementa feedback de usuários",
+    "Resolve conflitos de merge",
+    "Adiciona tratamento de erros",
+    "Atualiza dependências",
+    "Organiza a estrutura do código",
+    "Adiciona logs e monitoramento",
+    "Corrige vulnerabilidade de segurança",
+    "Melhora a interface do usuário",
+    "Adiciona endpoints da API",
+    "Corrige testes com falha",
+    "Adiciona migrações de banco de dados",
+    "Melhora a cobertura de testes",
+    "Adiciona opções de configuração",
+    "Corrige problema em produção",
+];
+
+const JA_MESSAGES: &[&str] = &[
+    "新機能を実装",
+    "コアロジックの重大なバグを修正",
+    "既存コードをリファクタリング",
+    "テストを追加",
+    "ドキュメントを更新",
+    "パフォーマンスのボトルネックを改善",
+    "ユーザーフィードバックを反映",
+    "マージコンフリクトを解消",
+    "エラーハンドリングを追加",
+    "依存関係を更新",
+    "コード構成を整理",
+    "ログとモニタリングを追加",
+    "セキュリティ脆弱性を修正",
+    "UIを改善",
+    "APIエンドポイントを追加",
+    "失敗しているテストを修正",
+    "DBマイグレーションを追加",
+    "テストカバレッジを向上",
+    "設定オプションを追加",
+    "本番環境の不具合を修正",
+];
+
+const ZH_MESSAGES: &[&str] = &[
+    "实现新功能",
+    "修复核心逻辑中的严重缺陷",
+    "重构现有代码",
+    "补充完整测试",
+    "更新文档",
+    "优化性能瓶颈",
+    "根据用户反馈改进",
+    "解决合并冲突",
+    "增加错误处理",
+    "更新依赖",
+    "整理代码结构",
+    "增加日志和监控",
+    "修复安全漏洞",
+    "改进用户界面",
+    "新增 API 接口",
+    "修复失败的测试",
+    "新增数据库迁移",
+    "提高代码覆盖率",
+    "增加配置选项",
+    "修复线上问题",
+];
+
+// Built-in message packs by language code
+const PACKS: &[(&str, &str, &[&str])] = &[
+    ("en", "English", EN_MESSAGES),
+    ("de", "German", DE_MESSAGES),
+    ("es", "Spanish", ES_MESSAGES),
+    ("fr", "French", FR_MESSAGES),
+    ("ja", "Japanese", JA_MESSAGES),
+    ("pt", "Portuguese", PT_MESSAGES),
+    ("zh", "Chinese", ZH_MESSAGES),
+];
+
+// Codes and languages of the built-in packs
+pub fn packs() -> impl Iterator<Item = (&'static str, &'static str)> {
+    PACKS.iter().map(|(code, language, _)| (*code, *language))
+}
+
+// A user pack: a TOML file with the same `messages` list as a profile
+#[derive(Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+struct PackFile {
+    messages: Vec<MessageEntry>,
+}
+
+// One message in the config: a plain string (weight 1) or a table
+//
+//   messages = [
+//       "Fix typo",
+//       { text = "Bump dependencies", weight = 4 },
+//   ]
+#[derive(Debug, Clone, Deserialize)]
+#[serde(untagged)]
+pub enum MessageEntry {
+    Text(String),
+    Weighted { text: String, weight: u32 },
+}
+
+// Commit subjects with relative weights; a message with weight 4 comes up
+// four times as often as one with weight 1
+#[derive(Debug, Clone)]
+pub struct MessagePool {
+    messages: Vec<(String, u32)>,
+    total_weight: usize,
+    // Most times one subject may be used in a day; unlimited when unset
+    daily_limit: Option<u32>,
+}
+
+impl Default for MessagePool {
+    fn default() -> Self {
+        let messages = EN_MESSAGES.iter().map(|text| (text.to_string(), 1)).collect();
+        Self::new(messages).unwrap()
+    }
+}
+
+impl MessagePool {
+    // Subjects are given without the marker, which is added when picking
+    pub fn new(messages: Vec<(String, u32)>) -> Result<Self> {
+        let messages: Vec<(String, u32)> = messages
+            .into_iter()
+            .map(|(text, weight)| (text.trim().to_string(), weight))
+            .filter(|(text, weight)| !text.is_empty() && *weight > 0)
+            .collect();
+        let total_weight = messages.iter().try_fold(0usize, |total, (_, weight)| total.checked_add(*weight as usize));
+
+        match total_weight {
+            Some(total_weight) if total_weight > 0 => Ok(Self { messages, total_weight, daily_limit: None }),
+            Some(_) => Err(GitHubGridError::Config(
+                "Message list is empty: at least one message needs a weight above 0".to_string(),
+            )),
+            None => Err(GitHubGridError::Config("Message weights are too large".to_string())),
+        }
+    }
+
+    pub fn from_entries(entries: &[MessageEntry]) -> Result<Self> {
+        Self::new(entries.iter().map(|entry| match entry {
+            MessageEntry::Text(text) => (text.clone(), 1),
+            MessageEntry::Weighted { text, weight } => (text.clone(), *weight),
+        }).collect())
+    }
+
+    // A built-in pack by language code, else <dir>/<name>.toml
+    pub fn pack(name: &str, dir: &Path) -> Result<Self> {
+        if let Some((_, _, messages)) = PACKS.iter().find(|(code, _, _)| code.eq_ignore_ascii_case(name)) {
+            return Self::new(messages.iter().map(|text| (text.to_string(), 1)).collect());
+        }
+
+        let path = dir.join(format!("{}.toml", name));
+        if !path.exists() {
+            let known: Vec<&str> = packs().map(|(code, _)| code).collect();
+            return Err(GitHubGridError::Config(format!(
+                "Unknown message pack '{}': not one of {} and no {} found",
+                name,
+                known.join(", "),
+                path.display()
+            )));
+        }
+        let content = fs::read_to_string(&path)?;
+        let pack: PackFile = toml::from_str(&content)
+            .map_err(|e| GitHubGridError::Config(format!("{}: {}", path.display(), e)))?;
+        Self::from_entries(&pack.messages)
+    }
+
+    // Save as a user pack, <dir>/<name>.toml
+    pub fn write_pack(&self, name: &str, dir: &Path, force: bool) -> Result<PathBuf> {
+        if PACKS.iter().any(|(code, _, _)| code.eq_ignore_ascii_case(name)) {
+            return Err(GitHubGridError::Config(format!("'{}' is a built-in pack; choose another name", name)));
+        }
+        let path = dir.join(format!("{}.toml", name));
+        if path.exists() && !force {
+            return Err(GitHubGridError::Config(format!(
+                "{} already exists; use --force to overwrite it",
+                path.display()
+            )));
+        }
+
+        fs::create_dir_all(dir)?;
+        fs::write(&path, self.to_toml())?;
+        Ok(path)
+    }
+
+    // Config syntax, as accepted by `messages` in a profile or a pack file
+    pub fn to_toml(&self) -> String {
+        let mut toml = String::from("messages = [\n");
+        for (text, weight) in &self.messages {
+            toml.push_str(&format!("    {{ text = {}, weight = {} }},\n", toml::Value::String(text.clone()), weight));
+        }
+        toml.push_str("]\n");
+        toml
+    }
+
+    pub fn with_daily_limit(mut self, daily_limit: Option<u32>) -> Self {
+        self.daily_limit = daily_limit;
+        self
+    }
+
+    pub fn messages(&self) -> impl Iterator<Item = (&str, u32)> {
+        self.messages.iter().map(|(text, weight)| (text.as_str(), *weight))
+    }
+
+    // Weighted draw. With equal weights this is the same single draw as
+    // picking an index uniformly, so default plans are unchanged
+    pub fn pick(&self, rng: &mut ChaCha8Rng) -> String {
+        let mut remaining = rng.random_range(0..self.total_weight);
+        for (text, weight) in &self.messages {
+            let weight = *weight as usize;
+            if remaining < weight {
+                return format!("{} {}", AUTOGEN_MARKER, text);
+            }
+            remaining -= weight;
+        }
+        unreachable!("draw is below the total weight")
+    }
+
+    // Weighted draw among the messages `avoid` doesn't reject; None when it
+    // rejects all of them
+    fn pick_avoiding(&self, rng: &mut ChaCha8Rng, avoid: impl Fn(&str) -> bool) -> Option<String> {
+        let allowed: Vec<(String, usize)> = self.messages
+            .iter()
+            .map(|(text, weight)| (format!("{} {}", AUTOGEN_MARKER, text), *weight as usize))
+            .filter(|(message, _)| !avoid(message))
+            .collect();
+        let total: usize = allowed.iter().map(|(_, weight)| weight).sum();
+        if total == 0 {
+            return None;
+        }
+
+        let mut remaining = rng.random_range(0..total);
+        for (message, weight) in allowed {
+            if remaining < weight {
+                return Some(message);
+            }
+            remaining -= weight;
+        }
+        None
+    }
+}
+
+// Keeps the same subject from appearing twice in a row, and more than the
+// pool's daily limit times in one day. Messages are checked in commit order;
+// only a repeat costs an extra draw, so plans without repeats are unchanged
+#[derive(Debug, Clone, Default)]
+pub struct MessageHistory {
+    daily_limit: Option<u32>,
+    previous: Option<String>,
+    today: HashMap<String, u32>,
+}
+
+impl MessageHistory {
+    pub fn new(pool: &MessagePool) -> Self {
+        Self { daily_limit: pool.daily_limit, ..Self::default() }
+    }
+
+    pub fn start_day(&mut self) {
+        self.today.clear();
+    }
+
+    // The message to use next: `message` itself unless it would repeat, else
+    // a fresh draw. Once every subject has hit the daily limit only direct
+    // repeats are avoided, and a one-message pool keeps `message`
+    pub fn next(&mut self, pool: &MessagePool, message: String, rng: &mut ChaCha8Rng) -> String {
+        let back_to_back = |candidate: &str| self.previous.as_deref() == Some(candidate);
+        let repeats = |candidate: &str| {
+            back_to_back(candidate)
+                || self.daily_limit.is_some_and(|limit| self.today.get(candidate).copied().unwrap_or(0) >= limit)
+        };
+        let message = if repeats(&message) {
+            pool.pick_avoiding(rng, repeats)
+                .or_else(|| if back_to_back(&message) { pool.pick_avoiding(rng, back_to_back) } else { None })
+                .unwrap_or(message)
+        } else {
+            message
+        };
+
+        *self.today.entry(message.clone()).or_insert(0) += 1;
+        self.previous = Some(message.clone());
+        message
+    }
+}
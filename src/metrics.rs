@@ -0,0 +1,50 @@
+use std::fmt::Write;
+use std::sync::atomic::{AtomicI64, AtomicU64, Ordering};
+use crate::executor::RunReport;
+
+// Counters exported by the daemon on /metrics in the Prometheus text format
+#[derive(Debug, Default)]
+pub struct Metrics {
+    pub commits_created: AtomicU64,
+    pub pushes: AtomicU64,
+    pub push_retries: AtomicU64,
+    pub failures: AtomicU64,
+    // Days between the newest generated commit and today
+    pub days_behind: AtomicI64,
+}
+
+impl Metrics {
+    pub fn record_run(&self, report: &RunReport) {
+        self.commits_created.fetch_add(report.created as u64, Ordering::Relaxed);
+        self.pushes.fetch_add(report.pushes as u64, Ordering::Relaxed);
+        self.push_retries.fetch_add(report.push_retries as u64, Ordering::Relaxed);
+    }
+
+    pub fn record_failure(&self) {
+        self.failures.fetch_add(1, Ordering::Relaxed);
+    }
+
+    pub fn set_days_behind(&self, days: i64) {
+        self.days_behind.store(days, Ordering::Relaxed);
+    }
+
+    pub fn render(&self) -> String {
+        let mut out = String::new();
+        let counters = [
+            ("github_grid_commits_created_total", "Commits created", &self.commits_created),
+            ("github_grid_pushes_total", "Successful pushes", &self.pushes),
+            ("github_grid_push_retries_total", "Push attempts for a previously deferred push", &self.push_retries),
+            ("github_grid_failures_total", "Top-up cycles that failed", &self.failures),
+        ];
+        for (name, help, value) in counters {
+            let _ = writeln!(out, "# HELP {} {}", name, help);
+            let _ = writeln!(out, "# TYPE {} counter", name);
+            let _ = writeln!(out, "{} {}", name, value.load(Ordering::Relaxed));
+        }
+
+        let _ = writeln!(out, "# HELP github_grid_days_behind Days since the newest generated commit");
+        let _ = writeln!(out, "# TYPE github_grid_days_behind gauge");
+        let _ = writeln!(out, "github_grid_days_behind {}", self.days_behind.load(Ordering::Relaxed));
+        out
+    }
+}
@@ -0,0 +1,163 @@
+use indicatif::{ProgressBar, ProgressStyle};
+use std::env;
+use std::fmt::Display;
+use std::io::{self, IsTerminal};
+use std::sync::atomic::{AtomicBool, AtomicU8, Ordering};
+
+// How much is printed: -q keeps only the summary and errors, -v adds each
+// git command, -vv adds full command output and the git environment
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord)]
+pub enum Verbosity {
+    Quiet = 0,
+    Normal = 1,
+    Verbose = 2,
+    Trace = 3,
+}
+
+static VERBOSITY: AtomicU8 = AtomicU8::new(Verbosity::Normal as u8);
+
+impl Verbosity {
+    pub fn from_flags(quiet: bool, verbose: u8) -> Self {
+        match (quiet, verbose) {
+            (true, _) => Verbosity::Quiet,
+            (false, 0) => Verbosity::Normal,
+            (false, 1) => Verbosity::Verbose,
+            _ => Verbosity::Trace,
+        }
+    }
+}
+
+pub fn set_verbosity(verbosity: Verbosity) {
+    VERBOSITY.store(verbosity as u8, Ordering::Relaxed);
+}
+
+pub fn verbosity() -> Verbosity {
+    match VERBOSITY.load(Ordering::Relaxed) {
+        0 => Verbosity::Quiet,
+        1 => Verbosity::Normal,
+        2 => Verbosity::Verbose,
+        _ => Verbosity::Trace,
+    }
+}
+
+// Progress messages, silenced by -q
+#[macro_export]
+macro_rules! status {
+    ($($arg:tt)*) => {
+        if $crate::output::verbosity() >= $crate::output::Verbosity::Normal {
+            println!($($arg)*);
+        }
+    };
+}
+
+// Per-command detail for -v, on stderr so stdout stays parseable
+#[macro_export]
+macro_rules! verbose {
+    ($($arg:tt)*) => {
+        if $crate::output::verbosity() >= $crate::output::Verbosity::Verbose {
+            eprintln!("{}", $crate::output::dim(format!($($arg)*)));
+        }
+    };
+}
+
+// Full command output and environment for -vv
+#[macro_export]
+macro_rules! trace {
+    ($($arg:tt)*) => {
+        if $crate::output::verbosity() >= $crate::output::Verbosity::Trace {
+            eprintln!("{}", $crate::output::dim(format!($($arg)*)));
+        }
+    };
+}
+
+// Console styling. Color is on only when stdout is a terminal, NO_COLOR is
+// unset (https://no-color.org) and --no-color wasn't given
+static COLOR: AtomicBool = AtomicBool::new(false);
+
+pub fn init_color(no_color_flag: bool) {
+    let no_color_env = env::var_os("NO_COLOR").is_some_and(|value| !value.is_empty());
+    let dumb_term = env::var("TERM").is_ok_and(|term| term == "dumb");
+    let enabled = !no_color_flag && !no_color_env && !dumb_term && io::stdout().is_terminal();
+    COLOR.store(enabled, Ordering::Relaxed);
+}
+
+pub fn color_enabled() -> bool {
+    COLOR.load(Ordering::Relaxed)
+}
+
+// A bar counting up to `len`, hidden by -q
+pub fn progress_bar(len: u64) -> ProgressBar {
+    if verbosity() == Verbosity::Quiet {
+        return ProgressBar::hidden();
+    }
+    let template = if color_enabled() {
+        "{spinner:.green} [{elapsed_precise}] [{bar:40.cyan/blue}] {pos}/{len} {msg}"
+    } else {
+        "{spinner} [{elapsed_precise}] [{bar:40}] {pos}/{len} {msg}"
+    };
+    let pb = ProgressBar::new(len);
+    pb.set_style(ProgressStyle::default_bar().template(template).unwrap());
+    pb
+}
+
+fn paint(code: &str, text: impl Display) -> String {
+    if color_enabled() {
+        format!("\x1b[{}m{}\x1b[0m", code, text)
+    } else {
+        text.to_string()
+    }
+}
+
+pub fn bold(text: impl Display) -> String {
+    paint("1", text)
+}
+
+pub fn dim(text: impl Display) -> String {
+    paint("2", text)
+}
+
+pub fn red(text: impl Display) -> String {
+    paint("31", text)
+}
+
+pub fn green(text: impl Display) -> String {
+    paint("32", text)
+}
+
+pub fn yellow(text: impl Display) -> String {
+    paint("33", text)
+}
+
+pub fn cyan(text: impl Display) -> String {
+    paint("36", text)
+}
+
+// Contribution-graph shades for a day with `count` commits, darkest green
+// for the busiest days like GitHub's own calendar
+pub fn calendar_cell(count: usize) -> String {
+    let (symbol, code) = match count {
+        0 => ("░", "90"),
+        1..=3 => ("▓", "38;5;71"),
+        4..=10 => ("█", "38;5;34"),
+        _ => ("🔥", "38;5;22"),
+    };
+    paint(code, symbol)
+}
+
+// GitHub's five graph shades, from an empty day (0) to the darkest (4);
+// single-width so columns line up
+pub fn graph_cell(level: u8) -> String {
+    let (symbol, code) = match level {
+        0 => ("·", "90"),
+        1 => ("░", "38;5;151"),
+        2 => ("▒", "38;5;71"),
+        3 => ("▓", "38;5;34"),
+        _ => ("█", "38;5;22"),
+    };
+    paint(code, symbol)
+}
+
+// A day a design leaves empty that already has contributions
+pub fn conflict_cell() -> String {
+    paint("31", "×")
+}
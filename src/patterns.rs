@@ -1,29 +1,56 @@
-use chrono::{DateTime, Local, NaiveDate, NaiveTime, TimeZone, Weekday, Datelike};
-use rand::{rng, Rng, SeedableRng};
+use chrono::{DateTime, FixedOffset, Local, LocalResult, NaiveDate, NaiveDateTime, NaiveTime, TimeZone, Weekday, Datelike};
+use rand::{Rng, SeedableRng};
 use rand_chacha::ChaCha8Rng;
+use serde::{Deserialize, Serialize};
+use crate::design::Design;
+use crate::error::{GitHubGridError, Result};
+use crate::grid::Grid;
+use crate::holidays::HolidayCalendar;
+use crate::messages::{MessageHistory, MessagePool};
+use crate::timezone::{CommitOffset, GraphTimezone, TimezoneSchedule};
 
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct CommitInfo {
-    pub date: DateTime<Local>,
+    // Carries the UTC offset the commit is recorded with
+    pub date: DateTime<FixedOffset>,
     pub message: String,
 }
 
 pub trait Pattern {
-    fn generate(&self, start: NaiveDate, end: NaiveDate) -> Vec<CommitInfo>;
+    // The same seed always produces the same commits for the same range
+    fn generate(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo>;
 }
 
-// Deterministic RNG seeded by date for consistent results
-fn date_rng(date: NaiveDate) -> ChaCha8Rng {
-    // Add microsecond entropy to vary between runs while keeping dates consistent
-    let base_seed = date.num_days_from_ce() as u64;
-    let time_entropy = std::time::SystemTime::now()
-        .duration_since(std::time::UNIX_EPOCH)
-        .unwrap()
-        .subsec_micros() as u64;
-    
-    // Mix seeds so same dates still cluster similarly but with run variation
-    let seed = base_seed.wrapping_mul(1000000) + (time_entropy % 1000);
-    ChaCha8Rng::seed_from_u64(seed)
+// Salt separating the weekly project-phase stream from the daily streams
+const WEEK_SALT: u64 = 0x5745_454B_5048_4153;
+
+// SplitMix64 finalizer: spreads the run seed and a salt over all 64 bits
+fn mix_seed(seed: u64, salt: u64) -> u64 {
+    let mut z = seed ^ salt.wrapping_mul(0x9E37_79B9_7F4A_7C15);
+    z = (z ^ (z >> 30)).wrapping_mul(0xBF58_476D_1CE4_E5B9);
+    z = (z ^ (z >> 27)).wrapping_mul(0x94D0_49BB_1331_11EB);
+    z ^ (z >> 31)
+}
+
+// January 1st of the date's year
+fn year_start(date: NaiveDate) -> NaiveDate {
+    NaiveDate::from_ymd_opt(date.year(), 1, 1).unwrap_or(date)
+}
+
+// `start` to `end` cut at every January 1st
+pub fn split_years(start: NaiveDate, end: NaiveDate) -> Vec<(NaiveDate, NaiveDate)> {
+    (start.year()..=end.year())
+        .map(|year| {
+            let first = NaiveDate::from_ymd_opt(year, 1, 1).map_or(start, |date| date.max(start));
+            let last = NaiveDate::from_ymd_opt(year, 12, 31).map_or(end, |date| date.min(end));
+            (first, last)
+        })
+        .collect()
+}
+
+// Deterministic RNG derived from the run seed and the date
+fn date_rng(date: NaiveDate, seed: u64) -> ChaCha8Rng {
+    ChaCha8Rng::seed_from_u64(mix_seed(seed, date.num_days_from_ce() as u64))
 }
 
 // Base intensity levels with ranges
@@ -88,16 +115,18 @@ impl IntensityLevel {
     }
 }
 
-// Weekly rhythm multipliers (realistic work patterns with slight randomization)
-fn get_weekly_multiplier(weekday: Weekday, rng: &mut ChaCha8Rng) -> f64 {
-    let base = match weekday {
-        Weekday::Mon => 0.7,  // Monday blues
-        Weekday::Tue => 1.1,  // Peak productivity
-        Weekday::Wed => 1.1,  // Peak productivity
-        Weekday::Thu => 1.1,  // Peak productivity
-        Weekday::Fri => 0.8,  // Winding down
-        Weekday::Sat => 0.6,  // Lighter weekends
-        Weekday::Sun => 0.6,  // Lighter weekends
+// Weekly rhythm multipliers (realistic work patterns with slight randomization).
+// Positions are relative to the work week, so with a Friday/Saturday weekend
+// Sunday gets the first-day dip and Thursday the wind-down
+fn get_weekly_multiplier(weekday: Weekday, weekend: &[Weekday], rng: &mut ChaCha8Rng) -> f64 {
+    let base = if weekend.contains(&weekday) {
+        0.6 // Lighter weekends
+    } else if weekend.contains(&weekday.pred()) {
+        0.7 // First day back blues
+    } else if weekend.contains(&weekday.succ()) {
+        0.8 // Winding down
+    } else {
+        1.1 // Peak productivity
     };
     
     // Add ±5% randomization to avoid exact patterns
@@ -105,6 +134,125 @@ fn get_weekly_multiplier(weekday: Weekday, rng: &mut ChaCha8Rng) -> f64 {
     f64::max(base + variation, 0.1) // Ensure positive multiplier
 }
 
+// How activity is spread over the week and over the day
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ActivityCurve {
+    // Commit hours are uniform within the work hours; the pattern's weekly
+    // rhythm (if any) shapes the week
+    #[default]
+    Uniform,
+    // Typical developer week: Tuesday to Thursday heaviest, a slower first
+    // day, and a Friday that tapers off in the afternoon. Hours follow a
+    // morning ramp, a lunch dip and an afternoon peak
+    Developer,
+}
+
+impl std::str::FromStr for ActivityCurve {
+    type Err = GitHubGridError;
+
+    fn from_str(name: &str) -> Result<Self> {
+        match name.to_ascii_lowercase().as_str() {
+            "uniform" => Ok(Self::Uniform),
+            "developer" => Ok(Self::Developer),
+            _ => Err(GitHubGridError::Config(format!("Unknown activity curve '{}' (expected uniform or developer)", name))),
+        }
+    }
+}
+
+// Relative chance of a commit in each hour of the day for the developer curve
+const DEVELOPER_HOUR_WEIGHTS: [f64; 24] = [
+    1.0, 0.5, 0.3, 0.2, 0.2, 0.5,    // night
+    2.0, 4.0, 8.0, 12.0, 14.0, 13.0, // morning ramp
+    7.0, 9.0, 13.0, 14.0, 13.0, 10.0, // lunch dip, afternoon peak
+    6.0, 4.0, 4.0, 4.0, 3.0, 2.0,    // evening tail
+];
+
+// Scales the afternoon on the last day of the work week
+fn end_of_week_taper(hour: u32) -> f64 {
+    match hour {
+        0..=13 => 1.0,
+        14 => 0.8,
+        15 => 0.6,
+        16 => 0.45,
+        _ => 0.3,
+    }
+}
+
+// Day multiplier for the developer curve, by position in the work week
+fn developer_day_multiplier(weekday: Weekday, weekend: &[Weekday], rng: &mut ChaCha8Rng) -> f64 {
+    let base = if weekend.contains(&weekday) {
+        0.5
+    } else if weekend.contains(&weekday.pred()) {
+        0.85 // Catching up on the first day back
+    } else if weekend.contains(&weekday.succ()) {
+        0.75 // Fewer commits, most of them before lunch
+    } else {
+        1.2 // Mid-week focus
+    };
+    
+    let variation = rng.random_range(-0.05..=0.05);
+    f64::max(base + variation, 0.1)
+}
+
+// Commit-count range for individual weekdays, replacing the intensity's
+// weekday/weekend range (and the weekly rhythm) on the days that are set
+//
+//   [profiles.me.weekdays]
+//   mon = [0, 4]
+//   wed = [8, 20]
+#[derive(Debug, Clone, Default, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct WeekdayRanges {
+    pub mon: Option<(u32, u32)>,
+    pub tue: Option<(u32, u32)>,
+    pub wed: Option<(u32, u32)>,
+    pub thu: Option<(u32, u32)>,
+    pub fri: Option<(u32, u32)>,
+    pub sat: Option<(u32, u32)>,
+    pub sun: Option<(u32, u32)>,
+}
+
+impl WeekdayRanges {
+    pub fn get(&self, weekday: Weekday) -> Option<(u32, u32)> {
+        match weekday {
+            Weekday::Mon => self.mon,
+            Weekday::Tue => self.tue,
+            Weekday::Wed => self.wed,
+            Weekday::Thu => self.thu,
+            Weekday::Fri => self.fri,
+            Weekday::Sat => self.sat,
+            Weekday::Sun => self.sun,
+        }
+    }
+
+    pub fn set(&mut self, weekday: Weekday, range: (u32, u32)) {
+        let slot = match weekday {
+            Weekday::Mon => &mut self.mon,
+            Weekday::Tue => &mut self.tue,
+            Weekday::Wed => &mut self.wed,
+            Weekday::Thu => &mut self.thu,
+            Weekday::Fri => &mut self.fri,
+            Weekday::Sat => &mut self.sat,
+            Weekday::Sun => &mut self.sun,
+        };
+        *slot = Some(range);
+    }
+
+    pub fn validate(&self) -> Result<()> {
+        for weekday in [Weekday::Mon, Weekday::Tue, Weekday::Wed, Weekday::Thu, Weekday::Fri, Weekday::Sat, Weekday::Sun] {
+            if let Some((min, max)) = self.get(weekday) {
+                if min > max {
+                    return Err(GitHubGridError::Config(format!(
+                        "Invalid commit range for {}: {} is more than {}", weekday, min, max
+                    )));
+                }
+            }
+        }
+        Ok(())
+    }
+}
+
 // Configuration for pattern generation
 #[derive(Debug, Clone)]
 pub struct PatternConfig {
@@ -114,9 +262,125 @@ pub struct PatternConfig {
     pub vacation_duration: (u32, u32), // Min/max vacation days
     pub spike_probability: f64,     // Chance of high-activity days
     pub spike_multiplier: f64,      // Multiplier for spike days
+    pub work_hours: (u32, u32),     // First/last hour commits can land in
+    pub timezones: TimezoneSchedule, // Periods spent away from the local timezone
+    pub commit_offset: Option<CommitOffset>, // Offset used outside those periods
+    pub weekend: Vec<Weekday>,      // Days treated as the weekend
+    pub holidays: HolidayCalendar,  // Days with much lower commit odds
+    pub messages: MessagePool,      // Weighted commit subjects
+    pub weekday_ranges: WeekdayRanges, // Per-weekday commit counts
+    pub curve: ActivityCurve,       // Shape of the week and the day
+    pub big_day_probability: f64,   // Chance per working day of an outlier day
+    pub day_correlation: f64,       // Weight of yesterday's count in today's
+    pub design: Option<Design>,     // Drawn onto the graph instead of simulated
+    pub graph_timezone: Option<GraphTimezone>, // Timezone GitHub counts days in
+    pub design_background: f64,     // Chance of a few commits on a design's empty cells
+    pub days_per_week: Option<(u32, u32)>, // Exactly this many random days worked each week
+    pub skip_weekday_chance: Option<f64>, // Chance a weekday is skipped, instead of the intensity's
+    pub skip_weekend_chance: Option<f64>, // Same for weekend days
+}
+
+impl Default for PatternConfig {
+    fn default() -> Self {
+        Self {
+            intensity: IntensityLevel::Active,
+            use_weekly_rhythm: true,
+            vacation_frequency: 0.03,
+            vacation_duration: (2, 7),
+            spike_probability: 0.20,
+            spike_multiplier: 2.5,
+            work_hours: (6, 23),
+            timezones: TimezoneSchedule::default(),
+            commit_offset: None,
+            weekend: vec![Weekday::Sat, Weekday::Sun],
+            holidays: HolidayCalendar::default(),
+            messages: MessagePool::default(),
+            weekday_ranges: WeekdayRanges::default(),
+            curve: ActivityCurve::default(),
+            big_day_probability: 0.0,
+            day_correlation: 0.0,
+            design: None,
+            graph_timezone: None,
+            design_background: 0.0,
+            days_per_week: None,
+            skip_weekday_chance: None,
+            skip_weekend_chance: None,
+        }
+    }
 }
 
 impl PatternConfig {
+    // Config behind a built-in pattern name
+    pub fn named(name: &str) -> Result<Self> {
+        match name {
+            "realistic" | "active" => Ok(Self::active()),
+            "steady" => Ok(Self::steady()),
+            "sporadic" => Ok(Self::sporadic()),
+            "contractor" => Ok(Self::contractor()),
+            "casual" => Ok(Self::casual()),
+            "maintainer" => Ok(Self::maintainer()),
+            "hyperactive" => Ok(Self::hyperactive()),
+            "extreme" => Ok(Self::extreme()),
+            "sparse" => Ok(Self::sparse()),
+            _ => match Design::named(name)? {
+                Some(design) => Ok(Self { design: Some(design), ..Self::active() }),
+                None => Err(GitHubGridError::Config(format!("Unknown pattern: {}", name))),
+            },
+        }
+    }
+    
+    pub fn steady() -> Self {
+        Self {
+            intensity: IntensityLevel::Active,
+            use_weekly_rhythm: false, // No weekly variation
+            vacation_frequency: 0.005, // Very rare breaks
+            vacation_duration: (1, 2),
+            spike_probability: 0.02,   // Minimal spikes
+            spike_multiplier: 1.2,     // Small spikes
+            ..Self::default()
+        }
+    }
+    
+    pub fn sporadic() -> Self {
+        Self {
+            intensity: IntensityLevel::Active,
+            use_weekly_rhythm: false,
+            vacation_frequency: 0.02,  // Frequent breaks
+            vacation_duration: (1, 5),
+            spike_probability: 0.15,   // High spike chance
+            spike_multiplier: 3.0,     // Big spikes
+            ..Self::default()
+        }
+    }
+    
+    pub fn contractor() -> Self {
+        Self {
+            intensity: IntensityLevel::Active,
+            use_weekly_rhythm: true,   // Strong weekday focus
+            vacation_frequency: 0.008, // Regular time off
+            vacation_duration: (2, 4),
+            spike_probability: 0.08,
+            spike_multiplier: 1.4,
+            ..Self::default()
+        }
+    }
+    
+    // A modestly active graph: 2-4 random days a week with a few commits each
+    pub fn sparse() -> Self {
+        let few = Some((1, 3));
+        Self {
+            intensity: IntensityLevel::Casual,
+            use_weekly_rhythm: false,
+            vacation_frequency: 0.01,
+            vacation_duration: (3, 10),
+            spike_probability: 0.05,
+            spike_multiplier: 1.5,
+            days_per_week: Some((2, 4)),
+            weekday_ranges: WeekdayRanges { mon: few, tue: few, wed: few, thu: few, fri: few, sat: few, sun: few },
+            ..Self::default()
+        }
+    }
+    
     pub fn casual() -> Self {
         Self {
             intensity: IntensityLevel::Casual,
@@ -125,6 +389,7 @@ impl PatternConfig {
             vacation_duration: (0, 0),
             spike_probability: 0.15,  // Regular burst days
             spike_multiplier: 3.0,
+            ..Self::default()
         }
     }
     
@@ -136,6 +401,7 @@ impl PatternConfig {
             vacation_duration: (2, 7),
             spike_probability: 0.20,  // Frequent feature days
             spike_multiplier: 2.5,
+            ..Self::default()
         }
     }
     
@@ -147,6 +413,7 @@ impl PatternConfig {
             vacation_duration: (3, 10),
             spike_probability: 0.25,   // Many busy days
             spike_multiplier: 2.2,
+            ..Self::default()
         }
     }
     
@@ -158,6 +425,7 @@ impl PatternConfig {
             vacation_duration: (2, 5),
             spike_probability: 0.30,   // Constant marathon sessions
             spike_multiplier: 2.8,
+            ..Self::default()
         }
     }
     
@@ -169,48 +437,111 @@ impl PatternConfig {
             vacation_duration: (1, 4),
             spike_probability: 0.35,   // Always in sprint mode
             spike_multiplier: 3.2,
+            ..Self::default()
+        }
+    }
+    
+    // Pick intensity, vacations and spikes so the generated range lands close
+    // to the number of commits still needed for a yearly target
+    pub fn for_target(commits_needed: u32, days_in_range: i64) -> Self {
+        let avg_per_day = commits_needed as f64 / days_in_range as f64;
+        
+        // Choose intensity level based on required daily average
+        // Calibrated for aggressive spike system: 0.7x accounts for frequent high spikes  
+        let target_avg = avg_per_day * 0.7;  // Conservative: two-tier spikes significantly boost output
+        
+        let intensity = if target_avg < 5.0 {
+            IntensityLevel::Casual
+        } else if target_avg < 15.0 {
+            IntensityLevel::Active  
+        } else if target_avg < 30.0 {
+            IntensityLevel::Maintainer
+        } else if target_avg < 50.0 {
+            IntensityLevel::Hyperactive
+        } else {
+            IntensityLevel::Extreme
+        };
+        
+        // Create pattern config with enhanced variance for target hitting
+        // More realistic vacation frequencies
+        let vacation_freq = match intensity {
+            IntensityLevel::Casual => 0.05,     // More time off
+            IntensityLevel::Active => 0.035,    // Regular breaks
+            IntensityLevel::Maintainer => 0.025, // Still needs breaks
+            IntensityLevel::Hyperactive => 0.02,  // Less but still important
+            IntensityLevel::Extreme => 0.015,    // Rare but necessary
+        };
+        
+        // More aggressive spike probability for dramatic variance
+        let spike_prob = match intensity {
+            IntensityLevel::Casual => 0.25,
+            IntensityLevel::Active => 0.32,
+            IntensityLevel::Maintainer => 0.38,
+            IntensityLevel::Hyperactive => 0.42,
+            IntensityLevel::Extreme => 0.48,
+        };
+        
+        Self {
+            intensity,
+            use_weekly_rhythm: true,
+            vacation_frequency: vacation_freq,
+            vacation_duration: (2, 8),  // Longer, more realistic breaks
+            spike_probability: spike_prob,
+            spike_multiplier: 3.5,  // Much more dramatic spikes for release/deadline days
+            ..Self::default()
         }
     }
 }
 
-const COMMIT_MESSAGES: &[&str] = &[
-    "[AutoGen] Add new feature implementation",
-    "[AutoGen] Fix critical bug in core logic", 
-    "[AutoGen] Refactor existing codebase",
-    "[AutoGen] Add comprehensive tests",
-    "[AutoGen] Update documentation",
-    "[AutoGen] Optimize performance bottleneck",
-    "[AutoGen] Implement user feedback",
-    "[AutoGen] Fix merge conflicts",
-    "[AutoGen] Add error handling",
-    "[AutoGen] Update dependencies",
-    "[AutoGen] Clean up code structure",
-    "[AutoGen] Add logging and monitoring",
-    "[AutoGen] Fix security vulnerability",
-    "[AutoGen] Improve user interface",
-    "[AutoGen] Add API endpoints",
-    "[AutoGen] Fix failing tests",
-    "[AutoGen] Add database migrations",
-    "[AutoGen] Improve code coverage",
-    "[AutoGen] Add configuration options",
-    "[AutoGen] Fix production issue",
-];
-
-fn get_random_message() -> String {
-    let mut rng = rng();
-    COMMIT_MESSAGES[rng.random_range(0..COMMIT_MESSAGES.len())].to_string()
+// Resolve a wall-clock time on DST-change days: a time repeated when clocks go
+// back is taken at its first occurrence, and a time skipped when they go
+// forward is read with the offset from before the jump, landing just past the
+// gap (02:30 in a 02:00-03:00 gap becomes 03:30)
+fn local_datetime(naive: NaiveDateTime) -> DateTime<Local> {
+    match Local.from_local_datetime(&naive) {
+        LocalResult::Single(datetime) => datetime,
+        LocalResult::Ambiguous(earliest, _) => earliest,
+        LocalResult::None => Local
+            .from_local_datetime(&(naive - chrono::Duration::days(1)))
+            .earliest()
+            .and_then(|before| before.offset().from_local_datetime(&naive).single())
+            .map(|datetime| datetime.with_timezone(&Local))
+            .unwrap_or_else(|| Local.from_utc_datetime(&naive)),
+    }
 }
 
-fn create_commit_at_time(date: NaiveDate, hour: u32, minute: u32) -> CommitInfo {
-    let time = NaiveTime::from_hms_opt(hour, minute, 0).unwrap();
-    let datetime = Local.from_local_datetime(&date.and_time(time)).unwrap();
+fn create_commit_at_time(date: NaiveDate, hour: u32, minute: u32, config: &PatternConfig, rng: &mut ChaCha8Rng) -> CommitInfo {
+    let naive = date.and_time(NaiveTime::from_hms_opt(hour, minute, 0).unwrap());
+    let mut datetime = config.timezones
+        .resolve(naive)
+        .or_else(|| config.commit_offset.and_then(|offset| offset.resolve(naive)))
+        .unwrap_or_else(|| local_datetime(naive).fixed_offset());
+    // Late or early commits away from the account's timezone would land on
+    // the next or previous day in the graph
+    if let Some(graph_timezone) = config.graph_timezone {
+        datetime = graph_timezone.bucket(datetime, date);
+    }
     
     CommitInfo {
         date: datetime,
-        message: get_random_message(),
+        message: config.messages.pick(rng),
     }
 }
 
+// Salt for the stream that picks the working days of a week
+const PICK_SALT: u64 = 0x5049_434B_4441_5953;
+
+// Whether `date` is one of the days picked for its week (Sunday to Saturday,
+// like a graph column) when a set number of days per week is worked
+fn is_picked_day(date: NaiveDate, seed: u64, (min, max): (u32, u32)) -> bool {
+    let week = ((date.num_days_from_ce() - date.weekday().num_days_from_sunday() as i32) / 7) as u64;
+    let mut week_rng = ChaCha8Rng::seed_from_u64(mix_seed(seed ^ PICK_SALT, week));
+    let count = week_rng.random_range(min.min(7)..=max.min(7)) as usize;
+    rand::seq::index::sample(&mut week_rng, 7, count)
+        .iter()
+        .any(|day| day as u32 == date.weekday().num_days_from_sunday())
+}
+
 // Generic pattern generator using configuration
 pub struct ConfigurablePattern {
     config: PatternConfig,
@@ -221,42 +552,33 @@ impl ConfigurablePattern {
         Self { config }
     }
     
-    fn is_holiday_period(&self, date: NaiveDate) -> bool {
-        let month = date.month();
-        let day = date.day();
-        
-        match (month, day) {
-            // New Year's period
-            (1, 1..=3) => true,
-            (12, 31) => true,
-            
-            // Christmas/Winter holidays (Dec 20 - Jan 5)  
-            (12, 20..=31) => true,
-            (1, 1..=5) => true,
-            
-            // US Thanksgiving week
-            (11, 22..=29) => true,
-            
-            // July 4th weekend
-            (7, 3..=5) => true,
-            
-            // Memorial Day weekend (last Monday of May)
-            (5, 25..=31) if matches!(date.weekday(), Weekday::Sat | Weekday::Sun | Weekday::Mon) => true,
-            
-            // Labor Day weekend (first Monday of September)
-            (9, 1..=7) if matches!(date.weekday(), Weekday::Sat | Weekday::Sun | Weekday::Mon) => true,
-            
-            _ => false,
-        }
+    fn is_weekend(&self, date: NaiveDate) -> bool {
+        self.config.weekend.contains(&date.weekday())
     }
     
-    fn should_work_today(&self, date: NaiveDate, rng: &mut ChaCha8Rng, worked_yesterday: bool, days_since_work: u32) -> bool {
+    fn should_work_today(&self, date: NaiveDate, seed: u64, rng: &mut ChaCha8Rng, worked_yesterday: bool, days_since_work: u32) -> bool {
+        if let Some(days) = self.config.days_per_week {
+            return is_picked_day(date, seed, days);
+        }
+        
         let base_probability = self.config.intensity.get_work_probability();
-        let is_weekend = matches!(date.weekday(), Weekday::Sat | Weekday::Sun);
-        let is_holiday = self.is_holiday_period(date);
+        let is_weekend = self.is_weekend(date);
+        let is_holiday = self.config.holidays.is_holiday(date);
+        
+        // A configured skip chance replaces the intensity's; one of 1 means
+        // those days are never worked, whatever the streak
+        let skip_chance = match is_weekend {
+            true => self.config.skip_weekend_chance,
+            false => self.config.skip_weekday_chance,
+        };
+        if skip_chance.is_some_and(|chance| chance >= 1.0) {
+            return false;
+        }
         
         // Base weekend/weekday probability
-        let mut probability = if is_weekend {
+        let mut probability = if let Some(chance) = skip_chance {
+            1.0 - chance
+        } else if is_weekend {
             match self.config.intensity {
                 IntensityLevel::Casual => 0.05,      // 5% chance
                 IntensityLevel::Active => 0.15,      // 15% chance
@@ -288,7 +610,7 @@ impl ConfigurablePattern {
         
         // Project phase simulation - some weeks are more intense
         let week_seed = (date.num_days_from_ce() / 7) as u64;
-        let mut week_rng = ChaCha8Rng::seed_from_u64(week_seed);
+        let mut week_rng = ChaCha8Rng::seed_from_u64(mix_seed(seed ^ WEEK_SALT, week_seed));
         let project_phase = week_rng.random::<f64>();
         
         if project_phase < 0.15 {
@@ -331,26 +653,121 @@ impl ConfigurablePattern {
         }
     }
     
+    // Outlier day (big refactor, release) drawn from a Pareto tail starting at
+    // twice the pattern's usual weekday maximum: most land just above it, a
+    // few reach far beyond, up to the super spike cap
+    fn draw_big_day(&self, rng: &mut ChaCha8Rng) -> u32 {
+        const TAIL_INDEX: f64 = 2.0;
+        let floor = (self.config.intensity.get_weekday_range().1 * 2).max(10) as f64;
+        let draw = floor / (1.0 - rng.random::<f64>()).powf(1.0 / TAIL_INDEX);
+        (draw as u32).min(self.config.intensity.get_super_spike_cap())
+    }
+    
+    // Every day gets commits in proportion to its cell's level, up to the
+    // intensity's usual weekday maximum. Vacations, holidays and weekends
+    // don't apply: a gap would break the design. The design is laid out on
+    // the graph as it looks on the range's last day, so days before that
+    // graph's first column get nothing
+    fn generate_design(&self, design: &Design, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
+        let peak = self.config.intensity.get_weekday_range().1;
+        let grid = Grid::rolling(end);
+        let mut history = MessageHistory::new(&self.config.messages);
+        let mut commits = Vec::new();
+        
+        for date in grid.days().filter(|date| *date >= start) {
+            let mut rng = date_rng(date, seed);
+            let Some((column, row)) = grid.cell(date) else { continue };
+            let level = design.level(column, row, &grid);
+            let mut count = (level * peak as f64).ceil() as u32;
+            // Light background activity on empty cells, kept in the graph's
+            // lightest shade so the design still stands out
+            if count == 0 && self.config.design_background > 0.0 && rng.random::<f64>() < self.config.design_background {
+                count = rng.random_range(1..=(peak / 4).max(1));
+            }
+            
+            let mut day: Vec<CommitInfo> = (0..count).map(|_| {
+                let hour = self.draw_hour(date, &mut rng);
+                let minute = rng.random_range(0..60);
+                create_commit_at_time(date, hour, minute, &self.config, &mut rng)
+            }).collect();
+            day.sort_by_key(|c| c.date);
+            history.start_day();
+            for commit in &mut day {
+                let message = std::mem::take(&mut commit.message);
+                commit.message = history.next(&self.config.messages, message, &mut rng);
+            }
+            commits.extend(day);
+        }
+        
+        commits.sort_by_key(|c| c.date);
+        commits
+    }
+    
+    fn draw_hour(&self, date: NaiveDate, rng: &mut ChaCha8Rng) -> u32 {
+        let (first, last) = self.config.work_hours;
+        if self.config.curve == ActivityCurve::Uniform {
+            return rng.random_range(first..=last);
+        }
+        
+        let end_of_week = !self.is_weekend(date) && self.config.weekend.contains(&date.weekday().succ());
+        let weight = |hour: u32| {
+            let taper = if end_of_week { end_of_week_taper(hour) } else { 1.0 };
+            DEVELOPER_HOUR_WEIGHTS[hour as usize] * taper
+        };
+        
+        let total: f64 = (first..=last).map(weight).sum();
+        let mut remaining = rng.random::<f64>() * total;
+        for hour in first..=last {
+            remaining -= weight(hour);
+            if remaining < 0.0 {
+                return hour;
+            }
+        }
+        last
+    }
+    
     fn get_base_commits(&self, date: NaiveDate, rng: &mut ChaCha8Rng) -> u32 {
-        let is_weekend = matches!(date.weekday(), Weekday::Sat | Weekday::Sun);
+        let is_weekend = self.is_weekend(date);
+        let day_range = self.config.weekday_ranges.get(date.weekday());
         
-        let range = if is_weekend {
+        let range = if let Some(range) = day_range {
+            range
+        } else if is_weekend {
             self.config.intensity.get_weekend_range()
         } else {
             self.config.intensity.get_weekday_range()
         };
         
+        // A day configured as [0, 0] never gets commits
+        if range.1 == 0 && day_range.is_some() {
+            return 0;
+        }
+        
         let mut commits = rng.random_range(range.0..=range.1);
         
-        // Apply weekly rhythm if enabled
-        if self.config.use_weekly_rhythm {
-            let multiplier = get_weekly_multiplier(date.weekday(), rng);
-            commits = (commits as f64 * multiplier) as u32;
+        // Apply weekly rhythm if enabled; a configured range already says how
+        // busy that day is
+        if day_range.is_none() {
+            let multiplier = match self.config.curve {
+                ActivityCurve::Developer => Some(developer_day_multiplier(date.weekday(), &self.config.weekend, rng)),
+                ActivityCurve::Uniform if self.config.use_weekly_rhythm => {
+                    Some(get_weekly_multiplier(date.weekday(), &self.config.weekend, rng))
+                }
+                ActivityCurve::Uniform => None,
+            };
+            if let Some(multiplier) = multiplier {
+                commits = (commits as f64 * multiplier) as u32;
+            }
         }
         
         // Apply spikes: regular feature days + rare super spikes (releases/deadlines)
         commits = self.apply_spike_multiplier(commits, rng);
         
+        // Rare heavy-tailed outliers; no draw when disabled so seeds reproduce
+        if self.config.big_day_probability > 0.0 && rng.random::<f64>() < self.config.big_day_probability {
+            commits = commits.max(self.draw_big_day(rng));
+        }
+        
         // Allow zero commits sometimes even on "work" days
         if commits == 0 && rng.random::<f64>() < 0.3 {
             0  // 30% chance of zero commits even when "working"
@@ -358,19 +775,30 @@ impl ConfigurablePattern {
             commits.max(1)
         }
     }
-}
 
-impl Pattern for ConfigurablePattern {
-    fn generate(&self, start: NaiveDate, end: NaiveDate) -> Vec<CommitInfo> {
+    fn generate_range(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
         let mut commits = Vec::new();
         let mut in_vacation = false;
         let mut vacation_end = start;
         let mut worked_yesterday = false;
         let mut days_since_work = 0u32;
+        let mut yesterday_commits = 0u32;
+        let mut history = MessageHistory::new(&self.config.messages);
         
-        let mut current = start;
+        // Streaks, vacations and message history carry over from day to day,
+        // so they're played out from the start of the year and reset on every
+        // January 1st. A day's schedule then depends only on the seed and the
+        // date, and overlapping ranges agree on every day they share
+        let mut current = year_start(start);
         while current <= end {
-            let mut rng = date_rng(current);
+            if current.ordinal() == 1 {
+                in_vacation = false;
+                worked_yesterday = false;
+                days_since_work = 0;
+                yesterday_commits = 0;
+                history = MessageHistory::new(&self.config.messages);
+            }
+            let mut rng = date_rng(current, seed);
             
             // Check for vacation start
             if !in_vacation && rng.random::<f64>() < self.config.vacation_frequency {
@@ -388,32 +816,56 @@ impl Pattern for ConfigurablePattern {
                 }
                 worked_yesterday = false;
                 days_since_work += 1;
+                yesterday_commits = 0;
                 current = current.succ_opt().unwrap();
                 continue;
             }
             
             // Check if working today (with streak tracking)
-            let working_today = self.should_work_today(current, &mut rng, worked_yesterday, days_since_work);
+            let working_today = self.should_work_today(current, seed, &mut rng, worked_yesterday, days_since_work);
             
             if !working_today {
                 worked_yesterday = false;
                 days_since_work += 1;
+                yesterday_commits = 0;
                 current = current.succ_opt().unwrap();
                 continue;
             }
             
             // Generate commits for the day
-            let day_commits = self.get_base_commits(current, &mut rng);
+            let mut day_commits = self.get_base_commits(current, &mut rng);
+            
+            // Carry part of yesterday's intensity over so busy and quiet
+            // stretches last a few days (an AR(1) process on the counts)
+            let correlation = self.config.day_correlation;
+            if correlation > 0.0 && worked_yesterday && day_commits > 0 {
+                let blended = (1.0 - correlation) * day_commits as f64 + correlation * yesterday_commits as f64;
+                day_commits = (blended.round() as u32).max(1);
+            }
             
+            let day_start = commits.len();
             for _ in 0..day_commits {
-                let hour = rng.random_range(6..=23);
+                let hour = self.draw_hour(current, &mut rng);
                 let minute = rng.random_range(0..60);
-                commits.push(create_commit_at_time(current, hour, minute));
+                commits.push(create_commit_at_time(current, hour, minute, &self.config, &mut rng));
+            }
+            
+            // Walk the day in commit order so repeats are judged as they'll appear
+            let day = &mut commits[day_start..];
+            day.sort_by_key(|c| c.date);
+            history.start_day();
+            for commit in day {
+                let message = std::mem::take(&mut commit.message);
+                commit.message = history.next(&self.config.messages, message, &mut rng);
+            }
+            if current < start {
+                commits.truncate(day_start);
             }
             
             // Update streak tracking
             worked_yesterday = true;
             days_since_work = 0;
+            yesterday_commits = day_commits;
             current = current.succ_opt().unwrap();
         }
         
@@ -422,6 +874,34 @@ impl Pattern for ConfigurablePattern {
     }
 }
 
+impl Pattern for ConfigurablePattern {
+    fn generate(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
+        if let Some(design) = &self.config.design {
+            return self.generate_design(design, start, end, seed);
+        }
+        
+        // Nothing carries over from one year to the next, so each year of
+        // a long range is planned on its own thread
+        let years = split_years(start, end);
+        if years.len() <= 1 {
+            return self.generate_range(start, end, seed);
+        }
+        
+        let mut commits: Vec<CommitInfo> = std::thread::scope(|scope| {
+            let workers: Vec<_> = years
+                .iter()
+                .map(|&(first, last)| scope.spawn(move || self.generate_range(first, last, seed)))
+                .collect();
+            workers
+                .into_iter()
+                .flat_map(|worker| worker.join().unwrap_or_else(|panic| std::panic::resume_unwind(panic)))
+                .collect()
+        });
+        commits.sort_by_key(|c| c.date);
+        commits
+    }
+}
+
 // Wrapper patterns using the new configurable system
 pub struct RealisticPattern {
     inner: ConfigurablePattern,
@@ -470,77 +950,50 @@ impl RealisticPattern {
 }
 
 impl Pattern for RealisticPattern {
-    fn generate(&self, start: NaiveDate, end: NaiveDate) -> Vec<CommitInfo> {
-        self.inner.generate(start, end)
+    fn generate(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
+        self.inner.generate(start, end, seed)
     }
 }
 
 impl SteadyPattern {
     pub fn new() -> Self {
-        // Custom config for steady pattern
-        let config = PatternConfig {
-            intensity: IntensityLevel::Active,
-            use_weekly_rhythm: false, // No weekly variation
-            vacation_frequency: 0.005, // Very rare breaks
-            vacation_duration: (1, 2),
-            spike_probability: 0.02,   // Minimal spikes
-            spike_multiplier: 1.2,     // Small spikes
-        };
         Self {
-            inner: ConfigurablePattern::new(config),
+            inner: ConfigurablePattern::new(PatternConfig::steady()),
         }
     }
 }
 
 impl Pattern for SteadyPattern {
-    fn generate(&self, start: NaiveDate, end: NaiveDate) -> Vec<CommitInfo> {
-        self.inner.generate(start, end)
+    fn generate(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
+        self.inner.generate(start, end, seed)
     }
 }
 
 impl SporadicPattern {
     pub fn new() -> Self {
-        // Custom config for sporadic pattern
-        let config = PatternConfig {
-            intensity: IntensityLevel::Active,
-            use_weekly_rhythm: false,
-            vacation_frequency: 0.02,  // Frequent breaks
-            vacation_duration: (1, 5),
-            spike_probability: 0.15,   // High spike chance
-            spike_multiplier: 3.0,     // Big spikes
-        };
         Self {
-            inner: ConfigurablePattern::new(config),
+            inner: ConfigurablePattern::new(PatternConfig::sporadic()),
         }
     }
 }
 
 impl Pattern for SporadicPattern {
-    fn generate(&self, start: NaiveDate, end: NaiveDate) -> Vec<CommitInfo> {
-        self.inner.generate(start, end)
+    fn generate(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
+        self.inner.generate(start, end, seed)
     }
 }
 
 impl ContractorPattern {
     pub fn new() -> Self {
-        // Custom config for contractor pattern
-        let config = PatternConfig {
-            intensity: IntensityLevel::Active,
-            use_weekly_rhythm: true,   // Strong weekday focus
-            vacation_frequency: 0.008, // Regular time off
-            vacation_duration: (2, 4),
-            spike_probability: 0.08,
-            spike_multiplier: 1.4,
-        };
         Self {
-            inner: ConfigurablePattern::new(config),
+            inner: ConfigurablePattern::new(PatternConfig::contractor()),
         }
     }
 }
 
 impl Pattern for ContractorPattern {
-    fn generate(&self, start: NaiveDate, end: NaiveDate) -> Vec<CommitInfo> {
-        self.inner.generate(start, end)
+    fn generate(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
+        self.inner.generate(start, end, seed)
     }
 }
 
@@ -553,8 +1006,8 @@ impl CasualPattern {
 }
 
 impl Pattern for CasualPattern {
-    fn generate(&self, start: NaiveDate, end: NaiveDate) -> Vec<CommitInfo> {
-        self.inner.generate(start, end)
+    fn generate(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
+        self.inner.generate(start, end, seed)
     }
 }
 
@@ -567,8 +1020,8 @@ impl ActivePattern {
 }
 
 impl Pattern for ActivePattern {
-    fn generate(&self, start: NaiveDate, end: NaiveDate) -> Vec<CommitInfo> {
-        self.inner.generate(start, end)
+    fn generate(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
+        self.inner.generate(start, end, seed)
     }
 }
 
@@ -581,8 +1034,8 @@ impl MaintainerPattern {
 }
 
 impl Pattern for MaintainerPattern {
-    fn generate(&self, start: NaiveDate, end: NaiveDate) -> Vec<CommitInfo> {
-        self.inner.generate(start, end)
+    fn generate(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
+        self.inner.generate(start, end, seed)
     }
 }
 
@@ -595,8 +1048,8 @@ impl HyperactivePattern {
 }
 
 impl Pattern for HyperactivePattern {
-    fn generate(&self, start: NaiveDate, end: NaiveDate) -> Vec<CommitInfo> {
-        self.inner.generate(start, end)
+    fn generate(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
+        self.inner.generate(start, end, seed)
     }
 }
 
@@ -609,7 +1062,81 @@ impl ExtremePattern {
 }
 
 impl Pattern for ExtremePattern {
-    fn generate(&self, start: NaiveDate, end: NaiveDate) -> Vec<CommitInfo> {
-        self.inner.generate(start, end)
+    fn generate(&self, start: NaiveDate, end: NaiveDate, seed: u64) -> Vec<CommitInfo> {
+        self.inner.generate(start, end, seed)
+    }
+}
+
+// Look up a built-in pattern by the name used on the command line
+pub fn create_pattern(name: &str) -> Result<Box<dyn Pattern>> {
+    Ok(Box::new(ConfigurablePattern::new(PatternConfig::named(name)?)))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn pattern(config: PatternConfig) -> ConfigurablePattern {
+        ConfigurablePattern::new(config)
+    }
+
+    fn days(start: NaiveDate, count: u64) -> impl Iterator<Item = NaiveDate> {
+        start.iter_days().take(count as usize)
     }
-}
\ No newline at end of file
+
+    #[test]
+    fn days_per_week_works_exactly_that_many_days() {
+        let pattern = pattern(PatternConfig { days_per_week: Some((3, 3)), ..PatternConfig::default() });
+        let mut rng = ChaCha8Rng::seed_from_u64(1);
+        // 2024-01-07 is a Sunday, the first day of a graph column
+        let sunday = NaiveDate::from_ymd_opt(2024, 1, 7).unwrap();
+        for week in 0..52 {
+            let worked = days(sunday + chrono::Duration::weeks(week), 7)
+                .filter(|&date| pattern.should_work_today(date, 42, &mut rng, false, 0))
+                .count();
+            assert_eq!(worked, 3, "week {}", week);
+        }
+    }
+
+    #[test]
+    fn days_per_week_follows_the_seed() {
+        let pattern = pattern(PatternConfig { days_per_week: Some((1, 5)), ..PatternConfig::default() });
+        let mut rng = ChaCha8Rng::seed_from_u64(1);
+        let start = NaiveDate::from_ymd_opt(2024, 1, 1).unwrap();
+        let worked = |seed: u64, rng: &mut ChaCha8Rng| -> Vec<bool> {
+            days(start, 366).map(|date| pattern.should_work_today(date, seed, rng, false, 0)).collect()
+        };
+        assert_eq!(worked(7, &mut rng), worked(7, &mut rng));
+        assert_ne!(worked(7, &mut rng), worked(8, &mut rng));
+    }
+
+    #[test]
+    fn sparse_works_two_to_four_days_a_week() {
+        let pattern = pattern(PatternConfig::sparse());
+        let mut rng = ChaCha8Rng::seed_from_u64(1);
+        let sunday = NaiveDate::from_ymd_opt(2024, 1, 7).unwrap();
+        let weeks: Vec<usize> = (0..52)
+            .map(|week| days(sunday + chrono::Duration::weeks(week), 7)
+                .filter(|&date| pattern.should_work_today(date, 42, &mut rng, false, 0))
+                .count())
+            .collect();
+        assert!(weeks.iter().all(|worked| (2..=4).contains(worked)), "{:?}", weeks);
+        assert!(weeks.contains(&2) && weeks.contains(&4), "{:?}", weeks);
+    }
+
+    #[test]
+    fn certain_skip_chance_never_works() {
+        let pattern = pattern(PatternConfig {
+            intensity: IntensityLevel::Extreme,
+            skip_weekday_chance: Some(1.0),
+            ..PatternConfig::default()
+        });
+        let mut rng = ChaCha8Rng::seed_from_u64(1);
+        let start = NaiveDate::from_ymd_opt(2024, 1, 1).unwrap();
+        for date in days(start, 366).filter(|&date| !pattern.is_weekend(date)) {
+            // Not even a streak or a long gap brings a weekday back
+            assert!(!pattern.should_work_today(date, 42, &mut rng, true, 10), "{}", date);
+        }
+        assert!(days(start, 366).any(|date| pattern.is_weekend(date) && pattern.should_work_today(date, 42, &mut rng, true, 0)));
+    }
+}
@@ -0,0 +1,584 @@
+use chrono::{DateTime, Datelike, FixedOffset, Local, NaiveDate, TimeZone, Weekday};
+use rand::{Rng, SeedableRng};
+use rand_chacha::ChaCha8Rng;
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+use std::fs;
+use std::path::Path;
+use crate::design::Design;
+use crate::error::{GitHubGridError, Result};
+use crate::grid::{self, Grid};
+use crate::holidays::HolidayCalendar;
+use crate::messages::{AUTOGEN_MARKER, MessagePool};
+use crate::patterns::{ActivityCurve, CommitInfo, ConfigurablePattern, Pattern, PatternConfig, WeekdayRanges, split_years};
+use crate::timezone::{CommitOffset, GraphTimezone, TimezoneSchedule};
+
+// How the commits for a range are chosen
+#[derive(Debug, Clone)]
+pub enum Strategy {
+    // A built-in pattern by name (realistic, active, ...)
+    Pattern(String),
+    // Calibrate toward a yearly total, given the commits that already exist
+    Target { total: u32, existing: u32 },
+}
+
+#[derive(Debug, Clone)]
+pub struct PlanOptions {
+    pub start: NaiveDate,
+    pub end: NaiveDate,
+    pub strategy: Strategy,
+    // Fixed seed for reproducible plans; a random one is drawn when unset
+    pub seed: Option<u64>,
+    // First/last hour of the day commits may land in, overriding the pattern
+    pub work_hours: Option<(u32, u32)>,
+    // UTC offsets for days spent away from the local timezone
+    pub timezones: TimezoneSchedule,
+    // Days treated as the weekend, overriding Saturday/Sunday
+    pub weekend: Option<Vec<Weekday>>,
+    // Public holidays and personal days off; the built-in calendar when unset
+    pub holidays: Option<HolidayCalendar>,
+    // Commit subjects to draw from; the built-in list when unset
+    pub messages: Option<MessagePool>,
+    // Commit-count ranges for individual weekdays
+    pub weekday_ranges: Option<WeekdayRanges>,
+    // Shape of the week and the day; the pattern's weekly rhythm when unset
+    pub curve: Option<ActivityCurve>,
+    // Chance per working day of a heavy-tailed outlier day
+    pub big_days: Option<f64>,
+    // Chances a weekday or weekend day is skipped, replacing the pattern's
+    pub skip_weekday_chance: Option<f64>,
+    pub skip_weekend_chance: Option<f64>,
+    // How much each day's count follows the previous day's (0 to below 1)
+    pub day_correlation: Option<f64>,
+    // Offset commits are recorded with outside `timezones`, instead of this
+    // machine's
+    pub commit_offset: Option<CommitOffset>,
+    // Timezone set on the GitHub account; commits are kept on their planned
+    // day as GitHub counts it
+    pub graph_timezone: Option<GraphTimezone>,
+    // Chance per empty design cell of a few background commits
+    pub design_background: Option<f64>,
+    // Strategies for individual years of the range, e.g. ramping up over
+    // time; other years use `strategy`
+    pub years: BTreeMap<i32, Strategy>,
+}
+
+impl PlanOptions {
+    pub fn new(start: NaiveDate, end: NaiveDate, strategy: Strategy) -> Self {
+        Self {
+            start,
+            end,
+            strategy,
+            seed: None,
+            work_hours: None,
+            timezones: TimezoneSchedule::default(),
+            weekend: None,
+            holidays: None,
+            messages: None,
+            weekday_ranges: None,
+            curve: None,
+            big_days: None,
+            skip_weekday_chance: None,
+            skip_weekend_chance: None,
+            day_correlation: None,
+            commit_offset: None,
+            graph_timezone: None,
+            design_background: None,
+            years: BTreeMap::new(),
+        }
+    }
+}
+
+// Every commit a run will create, sorted by timestamp. Saved as JSON with
+// --save-plan so it can be inspected before anything is applied
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct Plan {
+    pub start: NaiveDate,
+    pub end: NaiveDate,
+    // Seed the plan was generated with; reusing it reproduces the plan
+    pub seed: u64,
+    pub commits: Vec<CommitInfo>,
+}
+
+impl Plan {
+    pub fn len(&self) -> usize {
+        self.commits.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.commits.is_empty()
+    }
+
+    pub fn days(&self) -> i64 {
+        (self.end - self.start).num_days() + 1
+    }
+
+    pub fn save(&self, path: &Path) -> Result<()> {
+        let json = serde_json::to_string_pretty(self)
+            .map_err(|e| GitHubGridError::Parse(format!("Failed to serialize plan: {}", e)))?;
+        fs::write(path, json)?;
+        Ok(())
+    }
+
+    pub fn load(path: &Path) -> Result<Self> {
+        let json = fs::read_to_string(path)?;
+        serde_json::from_str(&json)
+            .map_err(|e| GitHubGridError::Parse(format!("Invalid plan file {}: {}", path.display(), e)))
+    }
+
+    // Problems that would make the plan unsafe to apply: commits out of
+    // order, outside the range or without the marker later runs look for
+    pub fn problems(&self) -> Vec<String> {
+        let mut problems = Vec::new();
+        if self.start > self.end {
+            problems.push(format!("Range starts after it ends ({} to {})", self.start, self.end));
+        }
+        for (index, commit) in self.commits.iter().enumerate() {
+            let day = commit.date.date_naive();
+            if index > 0 && commit.date < self.commits[index - 1].date {
+                problems.push(format!("Commit {} ({}) is earlier than the one before it", index + 1, commit.date));
+            }
+            if day < self.start || day > self.end {
+                problems.push(format!("Commit {} ({}) is outside {} to {}", index + 1, commit.date, self.start, self.end));
+            }
+            if !commit.message.starts_with(AUTOGEN_MARKER) {
+                problems.push(format!("Commit {} ({}) has no {} marker: {}", index + 1, commit.date, AUTOGEN_MARKER, commit.message));
+            }
+        }
+        problems
+    }
+
+    // Commits per day, on the day each is recorded in
+    pub fn per_day(&self) -> BTreeMap<NaiveDate, usize> {
+        let mut days = BTreeMap::new();
+        for commit in &self.commits {
+            *days.entry(commit.date.date_naive()).or_insert(0) += 1;
+        }
+        days
+    }
+
+    // Drop the commits earlier runs already made, given the generated commits
+    // each day has: a day is topped up to its planned count, never past it.
+    // Returns how many commits were dropped
+    pub fn skip_existing(&mut self, existing: &BTreeMap<NaiveDate, u32>) -> usize {
+        let before = self.commits.len();
+        let mut seen: BTreeMap<NaiveDate, u32> = BTreeMap::new();
+        // Commits are sorted, so the ones an interrupted run made come first
+        self.commits.retain(|commit| {
+            let day = commit.date.date_naive();
+            let count = seen.entry(day).or_insert(0);
+            *count += 1;
+            *count > existing.get(&day).copied().unwrap_or(0)
+        });
+        before - self.commits.len()
+    }
+
+    // Drop every commit on the given days, e.g. days with real activity.
+    // Returns how many commits were dropped
+    pub fn skip_days(&mut self, days: &BTreeMap<NaiveDate, u32>) -> usize {
+        let before = self.commits.len();
+        self.commits.retain(|commit| !days.contains_key(&commit.date.date_naive()));
+        before - self.commits.len()
+    }
+
+    // Give `day` exactly `count` commits: its last ones are dropped, or new
+    // ones added a few minutes apart after its last (from noon on an empty
+    // day) with subjects from `messages`. Used by the plan editor
+    pub fn set_day(&mut self, day: NaiveDate, count: usize, messages: &MessagePool) {
+        let on_day: Vec<usize> = self.commits.iter()
+            .enumerate()
+            .filter(|(_, commit)| commit.date.date_naive() == day)
+            .map(|(index, _)| index)
+            .collect();
+        if on_day.len() >= count {
+            for &index in on_day[count..].iter().rev() {
+                self.commits.remove(index);
+            }
+            return;
+        }
+
+        let mut rng = ChaCha8Rng::seed_from_u64(self.seed ^ day.num_days_from_ce() as u64);
+        let mut date = match on_day.last() {
+            Some(&index) => self.commits[index].date,
+            None => self.noon(day),
+        };
+        let last = day.and_hms_opt(23, 59, 0).unwrap()
+            .and_local_timezone(*date.offset())
+            .single()
+            .unwrap_or(date);
+        for added in 0..count - on_day.len() {
+            if added > 0 || !on_day.is_empty() {
+                date = (date + chrono::Duration::minutes(rng.random_range(3..=25))).min(last);
+            }
+            self.commits.push(CommitInfo { date, message: messages.pick(&mut rng) });
+        }
+        // Stable, so commits sharing a timestamp keep their order
+        self.commits.sort_by_key(|commit| commit.date);
+    }
+
+    // Noon on `day`, in the offset of the nearest planned commit so edits
+    // follow any timezone or fixed offset the plan was made with
+    fn noon(&self, day: NaiveDate) -> DateTime<FixedOffset> {
+        let noon = day.and_hms_opt(12, 0, 0).unwrap();
+        let offset = self.commits.iter()
+            .min_by_key(|commit| (commit.date.date_naive() - day).num_days().abs())
+            .map(|commit| *commit.date.offset())
+            .or_else(|| Local.from_local_datetime(&noon).earliest().map(|date| *date.offset()))
+            .unwrap_or_else(|| FixedOffset::east_opt(0).unwrap());
+        noon.and_local_timezone(offset).single().unwrap()
+    }
+
+    // Commits per calendar year
+    pub fn per_year(&self) -> BTreeMap<i32, usize> {
+        let mut years = BTreeMap::new();
+        for commit in &self.commits {
+            *years.entry(commit.date.year()).or_insert(0) += 1;
+        }
+        years
+    }
+
+    pub fn outline(&self) -> PlanOutline {
+        let mut outline = PlanOutline::new(self.start, self.end, self.seed);
+        outline.add(self);
+        outline
+    }
+
+    // The plan cut into one plan per calendar year with commits, in order
+    pub fn years(&self) -> impl Iterator<Item = Plan> + '_ {
+        self.commits
+            .chunk_by(|a, b| a.date.year() == b.date.year())
+            .map(|commits| Plan {
+                start: commits[0].date.date_naive().max(self.start),
+                end: commits[commits.len() - 1].date.date_naive().min(self.end),
+                seed: self.seed,
+                commits: commits.to_vec(),
+            })
+    }
+}
+
+// A plan's commits counted by day, without the commits themselves: all the
+// summaries, checks and hooks need, for plans streamed a year at a time
+#[derive(Debug, Clone)]
+pub struct PlanOutline {
+    pub start: NaiveDate,
+    pub end: NaiveDate,
+    pub seed: u64,
+    pub per_day: BTreeMap<NaiveDate, usize>,
+}
+
+impl PlanOutline {
+    pub fn new(start: NaiveDate, end: NaiveDate, seed: u64) -> Self {
+        Self { start, end, seed, per_day: BTreeMap::new() }
+    }
+
+    // Count in the commits of `plan`, a later part of the same plan
+    pub fn add(&mut self, plan: &Plan) {
+        for (day, count) in plan.per_day() {
+            *self.per_day.entry(day).or_insert(0) += count;
+        }
+    }
+
+    // Leave out the days before `from`
+    pub fn clip(&mut self, from: NaiveDate) {
+        self.per_day = self.per_day.split_off(&from);
+        self.start = self.start.max(from);
+    }
+
+    pub fn len(&self) -> usize {
+        self.per_day.values().sum()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.per_day.is_empty()
+    }
+
+    pub fn days(&self) -> i64 {
+        (self.end - self.start).num_days() + 1
+    }
+
+    // Commits per calendar year
+    pub fn per_year(&self) -> BTreeMap<i32, usize> {
+        let mut years = BTreeMap::new();
+        for (day, count) in &self.per_day {
+            *years.entry(day.year()).or_insert(0) += count;
+        }
+        years
+    }
+}
+
+// Turns options into a plan without touching any repository, so plans can be
+// built, inspected and executed separately
+pub struct Planner {
+    options: PlanOptions,
+    seed: u64,
+}
+
+impl Planner {
+    pub fn new(options: PlanOptions) -> Self {
+        // Drawn once, so building and streaming give the same plan
+        let seed = options.seed.unwrap_or_else(rand::random);
+        Self { options, seed }
+    }
+
+    pub fn options(&self) -> &PlanOptions {
+        &self.options
+    }
+
+    pub fn seed(&self) -> u64 {
+        self.seed
+    }
+
+    pub fn build(&self) -> Result<Plan> {
+        let PlanOptions { start, end, .. } = self.options;
+
+        let mut commits = Vec::new();
+        for (segment_start, segment_end, strategy) in self.segments() {
+            if let Some(config) = self.config_for(strategy, segment_start, segment_end)? {
+                commits.extend(ConfigurablePattern::new(config).generate(segment_start, segment_end, self.seed));
+            }
+        }
+        commits.sort_by_key(|c| c.date);
+
+        Ok(Plan { start, end, seed: self.seed, commits })
+    }
+
+    // The plan generated lazily, one part at a time, for ranges too long to
+    // hold in memory at once. Pattern-based parts are cut into calendar
+    // years, which come out exactly as they would in `build` since nothing
+    // carries over from one year to the next; targets and designs depend on
+    // their whole part and stay in one. Generating again gives the same parts
+    pub fn stream(&self) -> impl Iterator<Item = Result<Plan>> + '_ {
+        self.segments()
+            .into_iter()
+            .flat_map(|(start, end, strategy)| {
+                let yearly = match strategy {
+                    Strategy::Pattern(name) => matches!(Design::named(name), Ok(None)),
+                    Strategy::Target { .. } => false,
+                };
+                let parts = if yearly { split_years(start, end) } else { vec![(start, end)] };
+                parts.into_iter().map(move |(start, end)| (start, end, strategy))
+            })
+            .filter_map(move |(start, end, strategy)| match self.config_for(strategy, start, end) {
+                Ok(Some(config)) => {
+                    let mut commits = ConfigurablePattern::new(config).generate(start, end, self.seed);
+                    commits.sort_by_key(|c| c.date);
+                    Some(Ok(Plan { start, end, seed: self.seed, commits }))
+                }
+                Ok(None) => None,
+                Err(e) => Some(Err(e)),
+            })
+    }
+
+    // The range split at each new year that has its own strategy, so every
+    // part is generated with the settings for its year
+    fn segments(&self) -> Vec<(NaiveDate, NaiveDate, &Strategy)> {
+        let PlanOptions { start, end, .. } = self.options;
+        if self.options.years.is_empty() {
+            return vec![(start, end, &self.options.strategy)];
+        }
+
+        split_years(start, end)
+            .into_iter()
+            .map(|(year_start, year_end)| {
+                let strategy = self.options.years.get(&year_start.year()).unwrap_or(&self.options.strategy);
+                (year_start, year_end, strategy)
+            })
+            .collect()
+    }
+
+    // Pattern settings for one part of the range with the options' overrides
+    // applied, or None when a target is already reached
+    fn config_for(&self, strategy: &Strategy, start: NaiveDate, end: NaiveDate) -> Result<Option<PatternConfig>> {
+        let mut config = match strategy {
+            Strategy::Pattern(name) => PatternConfig::named(name)?,
+            Strategy::Target { total, existing } => {
+                let commits_needed = total.saturating_sub(*existing);
+                if commits_needed == 0 {
+                    return Ok(None);
+                }
+                let days_in_range = (end - start).num_days() + 1;
+                PatternConfig::for_target(commits_needed, days_in_range)
+            }
+        };
+
+        if let Some((first, last)) = self.options.work_hours {
+            if first > last || last > 23 {
+                return Err(GitHubGridError::Config(format!(
+                    "Invalid work hours {}-{}: expected 0 <= start <= end <= 23", first, last
+                )));
+            }
+            config.work_hours = (first, last);
+        }
+
+        config.timezones = self.options.timezones.clone();
+        if let Some(weekend) = &self.options.weekend {
+            config.weekend = weekend.clone();
+        }
+        if let Some(holidays) = &self.options.holidays {
+            config.holidays = holidays.clone();
+        }
+        if let Some(messages) = &self.options.messages {
+            config.messages = messages.clone();
+        }
+        if let Some(ranges) = &self.options.weekday_ranges {
+            ranges.validate()?;
+            config.weekday_ranges = ranges.clone();
+        }
+        if let Some(curve) = self.options.curve {
+            config.curve = curve;
+        }
+        if let Some(probability) = self.options.big_days {
+            if !(0.0..=1.0).contains(&probability) {
+                return Err(GitHubGridError::Config(format!(
+                    "Invalid big day chance {}: expected a probability between 0 and 1", probability
+                )));
+            }
+            config.big_day_probability = probability;
+        }
+        for (what, chance) in [("weekday", self.options.skip_weekday_chance), ("weekend", self.options.skip_weekend_chance)] {
+            if let Some(chance) = chance.filter(|chance| !(0.0..=1.0).contains(chance)) {
+                return Err(GitHubGridError::Config(format!(
+                    "Invalid {} skip chance {}: expected a probability between 0 and 1", what, chance
+                )));
+            }
+        }
+        config.skip_weekday_chance = self.options.skip_weekday_chance.or(config.skip_weekday_chance);
+        config.skip_weekend_chance = self.options.skip_weekend_chance.or(config.skip_weekend_chance);
+        if let Some(correlation) = self.options.day_correlation {
+            if !(0.0..1.0).contains(&correlation) {
+                return Err(GitHubGridError::Config(format!(
+                    "Invalid day correlation {}: expected at least 0 and below 1", correlation
+                )));
+            }
+            config.day_correlation = correlation;
+        }
+
+        config.commit_offset = self.options.commit_offset;
+        config.graph_timezone = self.options.graph_timezone;
+        if let Some(chance) = self.options.design_background {
+            if !(0.0..=1.0).contains(&chance) {
+                return Err(GitHubGridError::Config(format!(
+                    "Invalid design background {}: expected a probability between 0 and 1", chance
+                )));
+            }
+            config.design_background = chance;
+        }
+        if let Some(design) = &config.design {
+            check_design_fits(design, start, end)?;
+        }
+
+        Ok(Some(config))
+    }
+}
+
+// Fixed-size designs (initials, hearts) must fit the graph, and every cell
+// they fill must be inside the range, or they'd be drawn cut off
+fn check_design_fits(design: &Design, start: NaiveDate, end: NaiveDate) -> Result<()> {
+    let grid = Grid::rolling(end);
+    let Some(span) = design.span(&grid) else { return Ok(()) };
+    if span.end > grid.full_columns().end {
+        return Err(GitHubGridError::Config(format!(
+            "The design is {} weeks wide but the graph only has {} full columns", span.len(), grid.full_columns().len()
+        )));
+    }
+
+    let missed: Vec<NaiveDate> = span
+        .flat_map(|column| (0..grid::ROWS).map(move |row| (column, row)))
+        .filter(|&(column, row)| design.level(column, row, &grid) > 0.0)
+        .filter_map(|(column, row)| grid.date(column, row))
+        .filter(|date| *date < start)
+        .collect();
+    match missed.first() {
+        Some(first) => Err(GitHubGridError::Config(format!(
+            "The design starts on {}; move the range start to that day or earlier", first
+        ))),
+        None => Ok(()),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn day(day: u32) -> NaiveDate {
+        NaiveDate::from_ymd_opt(2024, 6, day).unwrap()
+    }
+
+    // A plan with `count` commits an hour apart from 9:00 on each day
+    fn plan(days: &[(NaiveDate, u32)]) -> Plan {
+        let offset = FixedOffset::east_opt(0).unwrap();
+        let commits = days.iter()
+            .flat_map(|&(day, count)| (0..count).map(move |hour| CommitInfo {
+                date: offset.from_local_datetime(&day.and_hms_opt(9 + hour, 0, 0).unwrap()).unwrap(),
+                message: format!("{} commit", AUTOGEN_MARKER),
+            }))
+            .collect();
+        Plan { start: days[0].0, end: days[days.len() - 1].0, seed: 42, commits }
+    }
+
+    #[test]
+    fn skip_existing_tops_days_up() {
+        let (yesterday, today) = (day(11), day(12));
+        let mut plan = plan(&[(yesterday, 2), (today, 3)]);
+        let existing = BTreeMap::from([(today, 1)]);
+        
+        assert_eq!(plan.skip_existing(&existing), 1);
+        assert_eq!(plan.per_day(), BTreeMap::from([(yesterday, 2), (today, 2)]));
+        // The earliest commits of the day are the ones already made
+        assert_eq!(plan.commits[2].date.format("%H:%M").to_string(), "10:00");
+    }
+
+    #[test]
+    fn skip_existing_never_goes_past_the_plan() {
+        let (yesterday, today) = (day(11), day(12));
+        let mut plan = plan(&[(yesterday, 2), (today, 3)]);
+        let existing = BTreeMap::from([(yesterday, 5), (today, 3)]);
+        
+        assert_eq!(plan.skip_existing(&existing), 5);
+        assert!(plan.is_empty());
+    }
+
+    #[test]
+    fn skip_existing_ignores_days_outside_the_plan() {
+        let mut plan = plan(&[(day(12), 3)]);
+        let existing = BTreeMap::from([(day(11), 4), (day(13), 1)]);
+        
+        assert_eq!(plan.skip_existing(&existing), 0);
+        assert_eq!(plan.len(), 3);
+    }
+
+    fn commits(plan: &Plan) -> Vec<(DateTime<FixedOffset>, String)> {
+        plan.commits.iter().map(|commit| (commit.date, commit.message.clone())).collect()
+    }
+
+    #[test]
+    fn stream_gives_the_built_plan_a_year_at_a_time() {
+        let start = NaiveDate::from_ymd_opt(2021, 3, 1).unwrap();
+        let end = NaiveDate::from_ymd_opt(2024, 2, 29).unwrap();
+        let planner = Planner::new(PlanOptions {
+            seed: Some(42),
+            years: BTreeMap::from([(2022, Strategy::Target { total: 500, existing: 100 })]),
+            ..PlanOptions::new(start, end, Strategy::Pattern("active".to_string()))
+        });
+        let built = planner.build().unwrap();
+        let parts: Vec<Plan> = planner.stream().collect::<Result<_>>().unwrap();
+        
+        assert_eq!(parts.iter().map(|part| part.start.year()).collect::<Vec<_>>(), vec![2021, 2022, 2023, 2024]);
+        let streamed: Vec<_> = parts.iter().flat_map(commits).collect();
+        assert_eq!(streamed, commits(&built));
+        let mut outline = PlanOutline::new(start, end, planner.seed());
+        parts.iter().for_each(|part| outline.add(part));
+        assert_eq!(outline.per_day, built.per_day());
+        assert_eq!(outline.per_year(), built.per_year());
+    }
+
+    #[test]
+    fn years_cut_a_plan_at_new_year() {
+        let plan = plan(&[(NaiveDate::from_ymd_opt(2023, 12, 31).unwrap(), 2), (NaiveDate::from_ymd_opt(2024, 1, 1).unwrap(), 3)]);
+        let years: Vec<Plan> = plan.years().collect();
+        
+        assert_eq!(years.iter().map(Plan::len).collect::<Vec<_>>(), vec![2, 3]);
+        assert_eq!(years.iter().flat_map(commits).collect::<Vec<_>>(), commits(&plan));
+        assert_eq!(plan.outline().per_day, plan.per_day());
+    }
+}
@@ -0,0 +1,61 @@
+use chrono::{DateTime, FixedOffset};
+use crate::error::{GitHubGridError, Result};
+use crate::git_ops::{GitOperations, MAIN_BRANCH};
+use crate::github::GitHubClient;
+use crate::status;
+
+// Before a long run, push one backdated probe commit to a scratch branch and
+// ask GitHub how it sees it. A commit only lands on the graph when its author
+// email belongs to the account and it is on the default branch of a non-fork
+// repository, so any of those being off fails here, before thousands of
+// commits are made that would never show up. Returns warnings that don't
+// stop the run
+pub fn check(git_ops: &mut GitOperations, github: &GitHubClient, date: DateTime<FixedOffset>) -> Result<Vec<String>> {
+    let slug = git_ops.remote_slug().ok_or_else(|| GitHubGridError::Config(
+        "origin is not a GitHub repository, so nothing pushed there can appear on the graph".to_string()
+    ))?;
+
+    let settings = github.repo_settings(&slug)?;
+    if settings.fork {
+        return Err(GitHubGridError::Config(format!(
+            "{} is a fork; commits in forks don't count as contributions. Use a repository of your own",
+            slug
+        )));
+    }
+    if settings.default_branch != MAIN_BRANCH {
+        return Err(GitHubGridError::Config(format!(
+            "The default branch of {} is '{}', but commits are made on '{}' and only the default branch counts. \
+             Change it under Settings → General → Default branch",
+            slug, settings.default_branch, MAIN_BRANCH
+        )));
+    }
+
+    status!("🔎 Pushing a probe commit dated {} to {}...", date.date_naive(), slug);
+    let sha = git_ops.push_probe(date)?.to_string();
+    let author = github.commit_author(&slug, &sha);
+    // The branch goes either way; failing to delete it isn't worth stopping for
+    if let Err(e) = git_ops.delete_probe() {
+        status!("⚠️  {}", e);
+    }
+
+    match author? {
+        Some(login) if login.eq_ignore_ascii_case(github.username()) => {}
+        Some(login) => return Err(GitHubGridError::Config(format!(
+            "The probe commit was attributed to {}, not {}. Set the profile's email to one of your addresses",
+            login, github.username()
+        ))),
+        None => return Err(GitHubGridError::Config(
+            "The probe commit's author email isn't linked to any GitHub account. Add it under \
+             https://github.com/settings/emails or set the profile's email to a verified address".to_string()
+        )),
+    }
+
+    let mut warnings = Vec::new();
+    if settings.private {
+        warnings.push(format!(
+            "{} is private: its commits only show on your graph with \"Private contributions\" enabled in your profile settings",
+            slug
+        ));
+    }
+    Ok(warnings)
+}
@@ -0,0 +1,18 @@
+use std::io::{self, BufRead, IsTerminal, Write};
+use crate::error::Result;
+
+// Ask a yes/no question on the terminal; anything but "y"/"yes" is a no.
+// Without an interactive terminal nobody can answer, so the answer is no
+pub fn confirm(question: &str) -> Result<bool> {
+    if !io::stdin().is_terminal() {
+        println!("{} [y/N] (no terminal, assuming no)", question);
+        return Ok(false);
+    }
+
+    print!("{} [y/N] ", question);
+    io::stdout().flush()?;
+
+    let mut answer = String::new();
+    io::stdin().lock().read_line(&mut answer)?;
+    Ok(matches!(answer.trim().to_lowercase().as_str(), "y" | "yes"))
+}
@@ -0,0 +1,48 @@
+use rand::Rng;
+use rand::seq::IndexedRandom;
+use crate::error::{GitHubGridError, Result};
+use crate::git_ops::GitOperations;
+use crate::github::GitHubClient;
+use crate::messages::AUTOGEN_MARKER;
+use crate::status;
+
+// Pull requests branch off as github-grid/pr-<timestamp>
+const BRANCH_PREFIX: &str = "github-grid/pr-";
+// Keeps the draws for pull requests and issues apart from other uses of a
+// run's seed
+pub const ROLL_SALT: u64 = 0x5055_4c4c_524f_4c4c;
+const TITLES: [&str; 8] = [
+    "Update notes",
+    "Tidy up formatting",
+    "Refresh documentation",
+    "Small cleanup",
+    "Fix typos",
+    "Update changelog",
+    "Adjust configuration",
+    "Minor maintenance",
+];
+
+// GitHub counts opening a pull request as a contribution of its own, on the
+// day it is opened, so unlike commits it can't be backdated. Opens one from
+// a fresh branch with a single empty commit and squash-merges it right away.
+// The squashed commit carries the marker like any generated commit, so
+// clean and regenerate treat it the same. Returns the pull request number
+pub fn open_and_merge(git_ops: &mut GitOperations, github: &GitHubClient, rng: &mut impl Rng) -> Result<u64> {
+    let slug = git_ops.remote_slug().ok_or_else(|| GitHubGridError::Config(
+        "origin is not a GitHub repository, so no pull request can be opened".to_string()
+    ))?;
+    let title = TITLES.choose(rng).copied().unwrap_or(TITLES[0]);
+    let branch = format!("{}{}", BRANCH_PREFIX, chrono::Local::now().format("%Y%m%d-%H%M%S"));
+
+    git_ops.push_branch(&branch, &format!("{} {}", AUTOGEN_MARKER, title))?;
+    let number = github.open_pull_request(&slug, &branch, title, "Routine maintenance.");
+    // Only the local branch is left to clean up either way
+    git_ops.delete_branch(&branch)?;
+    let number = number?;
+    status!("🔀 Opened pull request #{} in {}", number, slug);
+
+    github.merge_pull_request(&slug, number, &format!("{} {} (#{})", AUTOGEN_MARKER, title, number))?;
+    git_ops.pull_main()?;
+    status!("✅ Merged pull request #{}", number);
+    Ok(number)
+}
@@ -0,0 +1,110 @@
+use git2::Oid;
+use crate::error::{GitHubGridError, Result};
+use crate::git_ops::GitOperations;
+use crate::output::yellow;
+use crate::prompt::confirm;
+
+// A remote ref that a force push would overwrite
+#[derive(Debug, Clone)]
+pub struct RefUpdate {
+    pub name: String,
+    pub local: Oid,
+    pub remote: Option<Oid>,
+    // Commits on the remote that are not in the local history and would be lost
+    pub discarded: usize,
+}
+
+// Every history-rewriting operation (regenerate, clean, rollback) goes through
+// this gate: it needs explicit --force-push permission and an interactive
+// confirmation listing exactly which refs will be overwritten
+pub struct ForcePushGate {
+    allowed: bool,
+}
+
+impl ForcePushGate {
+    // `allowed` comes from the --force-push flag
+    pub fn new(allowed: bool) -> Self {
+        Self { allowed }
+    }
+
+    // Fail early, before anything is rewritten locally
+    pub fn ensure_allowed(&self) -> Result<()> {
+        if !self.allowed {
+            return Err(GitHubGridError::Config(
+                "This operation rewrites published history; rerun with --force-push".to_string()
+            ));
+        }
+        Ok(())
+    }
+
+    pub fn authorize(&self, updates: &[RefUpdate]) -> Result<()> {
+        self.ensure_allowed()?;
+
+        println!("{}", yellow("⚠️  Force push will overwrite these refs on origin:"));
+        for update in updates {
+            let remote = update.remote
+                .map(short_id)
+                .unwrap_or_else(|| "(none)".to_string());
+            println!("  {}: {} -> {} ({} remote commits discarded)",
+                update.name, remote, short_id(update.local), update.discarded);
+        }
+
+        if !confirm("Overwrite these refs?")? {
+            return Err(GitHubGridError::Cancelled);
+        }
+
+        Ok(())
+    }
+}
+
+fn short_id(oid: Oid) -> String {
+    oid.to_string().chars().take(8).collect()
+}
+
+// Most commits made by hand, and source files, a repository dedicated to the
+// graph is expected to have; the grid directory generated commits write to
+// doesn't count
+pub const MAX_REAL_COMMITS: usize = 25;
+pub const MAX_SOURCE_FILES: usize = 3;
+// Files at the root of a software project
+const PROJECT_FILES: &[&str] = &[
+    "Cargo.toml", "package.json", "go.mod", "pyproject.toml", "setup.py", "requirements.txt", "pom.xml",
+    "build.gradle", "build.gradle.kts", "Gemfile", "composer.json", "CMakeLists.txt", "Makefile", "mix.exs",
+    "Package.swift", "pubspec.yaml", "deno.json",
+];
+const SOURCE_EXTENSIONS: &[&str] = &[
+    "rs", "py", "js", "jsx", "ts", "tsx", "go", "java", "kt", "c", "h", "cpp", "cc", "hpp", "cs", "rb",
+    "php", "swift", "scala", "ex", "exs", "hs", "dart", "lua", "zig",
+];
+
+// Why the repository looks like a real project rather than one made for the
+// graph, or None when it doesn't: lots of commits made by hand, a project
+// manifest, or source files. Runs refuse such repositories without --force,
+// so a wrong --repo or working directory doesn't get thousands of filler
+// commits
+pub fn project_lookalike(git_ops: &GitOperations) -> Result<Option<String>> {
+    let mut reasons = Vec::new();
+    if git_ops.count_real_commits(MAX_REAL_COMMITS)? > MAX_REAL_COMMITS {
+        reasons.push(format!("more than {} commits not made by github-grid", MAX_REAL_COMMITS));
+    }
+
+    let files = git_ops.tracked_files()?;
+    if let Some(manifest) = files.iter().find(|path| PROJECT_FILES.contains(&path.as_str())) {
+        reasons.push(format!("a {}", manifest));
+    }
+    let sources = files.iter()
+        .filter(|path| {
+            path.rsplit_once('.')
+                .is_some_and(|(_, extension)| SOURCE_EXTENSIONS.contains(&extension))
+                && !path.starts_with(&format!("{}/", git_ops.grid_dir()))
+        })
+        .count();
+    if sources > MAX_SOURCE_FILES {
+        reasons.push(format!("{} source files", sources));
+    }
+
+    Ok((!reasons.is_empty()).then(|| format!(
+        "This looks like a real project, not a repository for the graph: it has {}",
+        reasons.join(", ")
+    )))
+}
@@ -0,0 +1,180 @@
+use chrono::{DateTime, Datelike, Local, NaiveDate, TimeZone};
+use std::fmt;
+use std::str::FromStr;
+use std::time::Duration;
+use crate::error::{GitHubGridError, Result};
+
+// Furthest ahead a cron expression is searched before it is treated as never
+// matching (covers Feb 29 only expressions)
+const SEARCH_DAYS: i64 = 366 * 5;
+
+const MONTH_NAMES: &[&str] = &["jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"];
+const DAY_NAMES: &[&str] = &["sun", "mon", "tue", "wed", "thu", "fri", "sat"];
+
+// When the daemon wakes up
+#[derive(Debug, Clone)]
+pub enum Schedule {
+    Every(Duration),
+    Cron(CronSchedule),
+}
+
+impl Schedule {
+    pub fn next_after(&self, after: DateTime<Local>) -> Option<DateTime<Local>> {
+        match self {
+            Schedule::Every(interval) => {
+                chrono::Duration::from_std(*interval).ok().and_then(|interval| after.checked_add_signed(interval))
+            }
+            Schedule::Cron(cron) => cron.next_after(after),
+        }
+    }
+}
+
+impl fmt::Display for Schedule {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Schedule::Every(interval) => write!(f, "every {}", humantime::format_duration(*interval)),
+            Schedule::Cron(cron) => write!(f, "cron '{}'", cron.expression),
+        }
+    }
+}
+
+// Standard five-field cron expression: minute hour day-of-month month
+// day-of-week. Fields take `*`, lists, ranges and steps (`*/15`, `9-17`,
+// `1,15`, `mon-fri`); @hourly, @daily, @weekly, @monthly and @yearly are
+// accepted too. As in Vixie cron, when both day fields are restricted a day
+// matches if either does
+#[derive(Debug, Clone)]
+pub struct CronSchedule {
+    expression: String,
+    minutes: u64,
+    hours: u64,
+    days_of_month: u64,
+    months: u64,
+    days_of_week: u64,
+    dom_restricted: bool,
+    dow_restricted: bool,
+}
+
+impl FromStr for CronSchedule {
+    type Err = GitHubGridError;
+
+    fn from_str(expression: &str) -> Result<Self> {
+        let expanded = match expression.trim() {
+            "@hourly" => "0 * * * *",
+            "@daily" | "@midnight" => "0 0 * * *",
+            "@weekly" => "0 0 * * 0",
+            "@monthly" => "0 0 1 * *",
+            "@yearly" | "@annually" => "0 0 1 1 *",
+            other => other,
+        };
+
+        let fields: Vec<&str> = expanded.split_whitespace().collect();
+        if fields.len() != 5 {
+            return Err(invalid(expression, "expected 5 fields: minute hour day-of-month month day-of-week"));
+        }
+
+        // 7 is an alias for Sunday
+        let mut days_of_week = parse_field(fields[4], 0, 7, DAY_NAMES).map_err(|e| invalid(expression, &e))?;
+        if days_of_week & (1 << 7) != 0 {
+            days_of_week = (days_of_week | 1) & !(1 << 7);
+        }
+
+        Ok(Self {
+            expression: expression.trim().to_string(),
+            minutes: parse_field(fields[0], 0, 59, &[]).map_err(|e| invalid(expression, &e))?,
+            hours: parse_field(fields[1], 0, 23, &[]).map_err(|e| invalid(expression, &e))?,
+            days_of_month: parse_field(fields[2], 1, 31, &[]).map_err(|e| invalid(expression, &e))?,
+            months: parse_field(fields[3], 1, 12, MONTH_NAMES).map_err(|e| invalid(expression, &e))?,
+            days_of_week,
+            dom_restricted: !fields[2].starts_with('*'),
+            dow_restricted: !fields[4].starts_with('*'),
+        })
+    }
+}
+
+impl CronSchedule {
+    // First matching minute strictly after `after`
+    pub fn next_after(&self, after: DateTime<Local>) -> Option<DateTime<Local>> {
+        let first_day = after.date_naive();
+        for offset in 0..SEARCH_DAYS {
+            let date = first_day + chrono::Duration::days(offset);
+            if !self.matches_day(date) {
+                continue;
+            }
+
+            for hour in (0..24u32).filter(|hour| self.hours & (1 << hour) != 0) {
+                for minute in (0..60u32).filter(|minute| self.minutes & (1 << minute) != 0) {
+                    // Times skipped by a DST change don't exist; ambiguous ones
+                    // fire on their first occurrence
+                    let Some(naive) = date.and_hms_opt(hour, minute, 0) else { continue };
+                    let Some(candidate) = Local.from_local_datetime(&naive).earliest() else { continue };
+                    if candidate > after {
+                        return Some(candidate);
+                    }
+                }
+            }
+        }
+        None
+    }
+
+    fn matches_day(&self, date: NaiveDate) -> bool {
+        if self.months & (1 << date.month()) == 0 {
+            return false;
+        }
+        let dom = self.days_of_month & (1 << date.day()) != 0;
+        let dow = self.days_of_week & (1 << date.weekday().num_days_from_sunday()) != 0;
+        match (self.dom_restricted, self.dow_restricted) {
+            (true, true) => dom || dow,
+            (true, false) => dom,
+            (false, true) => dow,
+            (false, false) => true,
+        }
+    }
+}
+
+fn invalid(expression: &str, reason: &str) -> GitHubGridError {
+    GitHubGridError::Config(format!("Invalid cron expression '{}': {}", expression, reason))
+}
+
+// Bitmask of the values a field allows
+fn parse_field(field: &str, min: u32, max: u32, names: &[&str]) -> std::result::Result<u64, String> {
+    let mut mask = 0u64;
+    for part in field.split(',') {
+        let (range, step) = match part.split_once('/') {
+            Some((range, step)) => {
+                let step: u32 = step.parse().map_err(|_| format!("bad step in '{}'", part))?;
+                if step == 0 {
+                    return Err(format!("step must be positive in '{}'", part));
+                }
+                (range, step)
+            }
+            None => (part, 1),
+        };
+
+        let (start, end) = if range == "*" {
+            (min, max)
+        } else if let Some((start, end)) = range.split_once('-') {
+            (parse_value(start, min, names)?, parse_value(end, min, names)?)
+        } else {
+            let value = parse_value(range, min, names)?;
+            // "5/15" means every 15 starting at 5
+            (value, if part.contains('/') { max } else { value })
+        };
+
+        if start < min || end > max || start > end {
+            return Err(format!("'{}' is outside {}-{}", part, min, max));
+        }
+        for value in (start..=end).step_by(step as usize) {
+            mask |= 1 << value;
+        }
+    }
+    Ok(mask)
+}
+
+fn parse_value(value: &str, min: u32, names: &[&str]) -> std::result::Result<u32, String> {
+    let lower = value.to_ascii_lowercase();
+    if let Some(index) = names.iter().position(|name| *name == lower) {
+        return Ok(index as u32 + min);
+    }
+    value.parse().map_err(|_| format!("'{}' is not a number", value))
+}
@@ -0,0 +1,294 @@
+use chrono::{Datelike, NaiveDate};
+use std::collections::{BTreeMap, HashMap};
+use std::fmt::Write as _;
+use std::io::{BufRead, BufReader, Write};
+use std::net::{TcpListener, TcpStream};
+use std::time::Duration;
+use crate::error::Result;
+use crate::github::GitHubClient;
+use crate::grid::{self, Grid};
+use crate::plan::{Plan, PlanOptions, Planner, Strategy};
+use crate::status;
+
+const HTTP_TIMEOUT: Duration = Duration::from_secs(5);
+// Offered in the pattern field; anything `--pattern` takes works
+const PATTERNS: [&str; 16] = [
+    "casual", "realistic", "active", "maintainer", "hyperactive", "extreme", "sparse",
+    "steady", "sporadic", "contractor",
+    "gradient", "wave", "checkerboard", "stripes", "heart", "initials:JD",
+];
+// GitHub's light theme, from no contributions to the most
+const LEVELS: [&str; 5] = ["#ebedf0", "#9be9a8", "#40c463", "#30a14e", "#216e39"];
+
+// What a page shows: the form fields, and the defaults for a bare `/`
+#[derive(Debug, Clone)]
+pub struct PreviewQuery {
+    pub start: NaiveDate,
+    pub end: NaiveDate,
+    pub pattern: String,
+    pub seed: Option<u64>,
+    pub background: Option<f64>,
+}
+
+impl PreviewQuery {
+    // Fields in a query string override the defaults; a bad value is an error
+    // shown on the page
+    fn parse(&self, query: &str) -> std::result::Result<Self, String> {
+        let mut parsed = self.clone();
+        for pair in query.split('&').filter(|pair| !pair.is_empty()) {
+            let (key, value) = pair.split_once('=').unwrap_or((pair, ""));
+            let value = decode(value);
+            let value = value.trim();
+            let date = |value: &str| NaiveDate::parse_from_str(value, "%Y-%m-%d")
+                .map_err(|_| format!("{} is not a date (YYYY-MM-DD): {}", key, value));
+            match key {
+                "start" => parsed.start = date(value)?,
+                "end" => parsed.end = date(value)?,
+                "pattern" if !value.is_empty() => parsed.pattern = value.to_string(),
+                "seed" => parsed.seed = match value {
+                    "" => None,
+                    _ => Some(value.parse().map_err(|_| format!("seed is not a number: {}", value))?),
+                },
+                "background" => parsed.background = match value {
+                    "" => None,
+                    _ => Some(value.parse().map_err(|_| format!("background is not a number: {}", value))?),
+                },
+                _ => {}
+            }
+        }
+        if parsed.start > parsed.end {
+            return Err(format!("The range starts after it ends ({} to {})", parsed.start, parsed.end));
+        }
+        Ok(parsed)
+    }
+}
+
+// `github-grid serve`: a local page with the planned graph next to the one on
+// GitHub, re-planned from the form on every load. A saved plan replaces the
+// pattern form. Requests are answered one at a time; it's a single-user tool
+pub struct PreviewServer {
+    defaults: PreviewQuery,
+    saved: Option<Plan>,
+    github: Option<GitHubClient>,
+    // Contribution calendars already fetched, by range
+    actual: HashMap<(NaiveDate, NaiveDate), std::result::Result<BTreeMap<NaiveDate, u32>, String>>,
+}
+
+impl PreviewServer {
+    pub fn new(defaults: PreviewQuery, saved: Option<Plan>, github: Option<GitHubClient>) -> Self {
+        Self { defaults, saved, github, actual: HashMap::new() }
+    }
+
+    pub fn run(&mut self, listener: TcpListener) -> Result<()> {
+        status!("🌐 Preview on http://{}/ (Ctrl+C to stop)", listener.local_addr()?);
+        for stream in listener.incoming().flatten() {
+            // A misbehaving client only loses its own response
+            let _ = self.respond(stream);
+        }
+        Ok(())
+    }
+
+    fn respond(&mut self, mut stream: TcpStream) -> std::io::Result<()> {
+        stream.set_read_timeout(Some(HTTP_TIMEOUT))?;
+
+        let mut reader = BufReader::new(&stream);
+        let mut request_line = String::new();
+        reader.read_line(&mut request_line)?;
+        // Drain the headers so the client sees a clean close
+        let mut header = String::new();
+        while reader.read_line(&mut header)? > 0 && !header.trim_end().is_empty() {
+            header.clear();
+        }
+
+        let mut parts = request_line.split_whitespace();
+        let (method, target) = (parts.next().unwrap_or(""), parts.next().unwrap_or(""));
+        let (path, query) = target.split_once('?').unwrap_or((target, ""));
+        let (status, content_type, body) = match (method, path) {
+            ("GET", "/") => match self.defaults.parse(query) {
+                Ok(query) => ("200 OK", "text/html; charset=utf-8", self.page(&query)),
+                Err(e) => ("400 Bad Request", "text/html; charset=utf-8", self.error_page(&self.defaults, &e)),
+            },
+            ("GET", _) => ("404 Not Found", "text/plain", "not found\n".to_string()),
+            _ => ("405 Method Not Allowed", "text/plain", "method not allowed\n".to_string()),
+        };
+
+        write!(
+            stream,
+            "HTTP/1.1 {}\r\nContent-Type: {}\r\nContent-Length: {}\r\nConnection: close\r\n\r\n{}",
+            status,
+            content_type,
+            body.len(),
+            body
+        )
+    }
+
+    fn page(&mut self, query: &PreviewQuery) -> String {
+        let plan = match &self.saved {
+            Some(plan) => Ok(plan.clone()),
+            None => Planner::new(PlanOptions {
+                seed: query.seed,
+                design_background: query.background,
+                ..PlanOptions::new(query.start, query.end, Strategy::Pattern(query.pattern.clone()))
+            }).build(),
+        };
+        let plan = match plan {
+            Ok(plan) => plan,
+            Err(e) => return self.error_page(query, &e.to_string()),
+        };
+        let planned: BTreeMap<NaiveDate, u32> = plan.per_day()
+            .into_iter()
+            .map(|(day, count)| (day, count as u32))
+            .collect();
+        let actual = self.actual(plan.start, plan.end);
+
+        let mut html = self.header(query);
+        match &self.saved {
+            Some(_) => {
+                let _ = write!(html, "<p>Saved plan, seed {}: {} commits on {} days</p>", plan.seed, plan.len(), planned.len());
+            }
+            None => {
+                let _ = write!(html, "<p>Seed {} (enter it to keep this plan): {} commits on {} days</p>", plan.seed, plan.len(), planned.len());
+            }
+        }
+
+        let empty = BTreeMap::new();
+        let (actual, note) = match &actual {
+            Ok(actual) => (actual, None),
+            Err(e) => (&empty, Some(e.as_str())),
+        };
+        html.push_str("<h2>Planned</h2>");
+        html.push_str(&heatmap(plan.start, plan.end, &planned, &planned, actual));
+        html.push_str("<h2>On GitHub now</h2>");
+        match note {
+            Some(note) => {
+                let _ = write!(html, "<p class=\"note\">{}</p>", escape(note));
+            }
+            None => html.push_str(&heatmap(plan.start, plan.end, actual, &planned, actual)),
+        }
+        html.push_str("</body></html>");
+        html
+    }
+
+    fn error_page(&self, query: &PreviewQuery, message: &str) -> String {
+        let mut html = self.header(query);
+        let _ = write!(html, "<p class=\"error\">{}</p></body></html>", escape(message));
+        html
+    }
+
+    fn header(&self, query: &PreviewQuery) -> String {
+        let mut html = String::from(
+            "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>github-grid preview</title><style>\
+             body{font-family:-apple-system,sans-serif;margin:2em;color:#24292f}\
+             form{display:flex;gap:1em;flex-wrap:wrap;align-items:end}\
+             label{display:flex;flex-direction:column;font-size:.85em}\
+             .graph{display:flex;gap:3px;overflow-x:auto;padding:4px 0}\
+             .week{display:flex;flex-direction:column;gap:3px}\
+             .day{width:11px;height:11px;border-radius:2px}\
+             .day[title]:hover{outline:1px solid #24292f}\
+             .note{color:#57606a}.error{color:#cf222e}\
+             </style></head><body><h1>github-grid preview</h1>"
+        );
+        if self.saved.is_none() {
+            let _ = write!(
+                html,
+                "<form><label>Pattern<input name=\"pattern\" list=\"patterns\" value=\"{}\"></label>\
+                 <label>Start<input name=\"start\" type=\"date\" value=\"{}\"></label>\
+                 <label>End<input name=\"end\" type=\"date\" value=\"{}\"></label>\
+                 <label>Seed<input name=\"seed\" value=\"{}\" placeholder=\"random\"></label>\
+                 <label>Background<input name=\"background\" value=\"{}\" placeholder=\"0-1\"></label>\
+                 <button>Plan</button></form><datalist id=\"patterns\">",
+                escape(&query.pattern),
+                query.start,
+                query.end,
+                query.seed.map(|seed| seed.to_string()).unwrap_or_default(),
+                query.background.map(|background| background.to_string()).unwrap_or_default(),
+            );
+            for pattern in PATTERNS {
+                let _ = write!(html, "<option value=\"{}\">", pattern);
+            }
+            html.push_str("</datalist>");
+        }
+        html
+    }
+
+    // The user's contribution calendar for a range, fetched once per range.
+    // Without gh the page still shows the plan
+    fn actual(&mut self, start: NaiveDate, end: NaiveDate) -> std::result::Result<BTreeMap<NaiveDate, u32>, String> {
+        let Some(github) = &self.github else {
+            return Err("Not signed in to GitHub (gh auth login), so only the plan is shown".to_string());
+        };
+        self.actual.entry((start, end))
+            .or_insert_with(|| {
+                status!("📥 Fetching the contribution graph of {} for {} to {}", github.username(), start, end);
+                github.contribution_calendar(start, end).map_err(|e| e.to_string())
+            })
+            .clone()
+    }
+}
+
+// One graph in GitHub's layout, shaded by `shown` relative to its busiest day.
+// Hovering a day gives both counts
+fn heatmap(
+    start: NaiveDate,
+    end: NaiveDate,
+    shown: &BTreeMap<NaiveDate, u32>,
+    planned: &BTreeMap<NaiveDate, u32>,
+    actual: &BTreeMap<NaiveDate, u32>,
+) -> String {
+    let grid = Grid::new(start, end);
+    let busiest = shown.values().copied().max().unwrap_or(0).max(1);
+    let mut html = String::from("<div class=\"graph\">");
+    for column in 0..grid.columns {
+        html.push_str("<div class=\"week\">");
+        for row in 0..grid::ROWS {
+            match grid.date(column, row) {
+                Some(day) => {
+                    let count = shown.get(&day).copied().unwrap_or(0);
+                    let level = (count * 4).div_ceil(busiest) as usize;
+                    let _ = write!(
+                        html,
+                        "<div class=\"day\" style=\"background:{}\" title=\"{} {}: {} planned, {} on GitHub\"></div>",
+                        LEVELS[level],
+                        day.weekday(),
+                        day,
+                        planned.get(&day).copied().unwrap_or(0),
+                        actual.get(&day).copied().unwrap_or(0),
+                    );
+                }
+                None => html.push_str("<div class=\"day\"></div>"),
+            }
+        }
+        html.push_str("</div>");
+    }
+    html.push_str("</div>");
+    html
+}
+
+// Form values as browsers send them: `+` for spaces and %XX escapes
+fn decode(value: &str) -> String {
+    let bytes = value.as_bytes();
+    let mut decoded = Vec::with_capacity(bytes.len());
+    let mut index = 0;
+    while index < bytes.len() {
+        let escaped = bytes.get(index + 1..index + 3)
+            .and_then(|hex| std::str::from_utf8(hex).ok())
+            .and_then(|hex| u8::from_str_radix(hex, 16).ok());
+        match bytes[index] {
+            b'+' => decoded.push(b' '),
+            b'%' if escaped.is_some() => {
+                decoded.extend(escaped);
+                index += 2;
+            }
+            byte => decoded.push(byte),
+        }
+        index += 1;
+    }
+    String::from_utf8_lossy(&decoded).into_owned()
+}
+
+fn escape(text: &str) -> String {
+    text.replace('&', "&amp;")
+        .replace('<', "&lt;")
+        .replace('>', "&gt;")
+        .replace('"', "&quot;")
+}
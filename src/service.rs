@@ -0,0 +1,237 @@
+use std::env;
+use std::fs;
+use std::path::PathBuf;
+use std::process::Command;
+use std::time::Duration;
+use crate::error::{GitHubGridError, Result};
+use crate::status;
+
+// What the installed service runs
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ServiceMode {
+    // Long-running `daemon`, restarted if it dies
+    Daemon,
+    // One-shot `--today` top-up, started every interval by a timer
+    Timer,
+}
+
+// A systemd user unit (Linux) or launchd agent (macOS) that keeps the grid
+// topped up without manual cron setup
+#[derive(Debug, Clone)]
+pub struct ServiceSpec {
+    // Unit/agent name, e.g. "github-grid" or "github-grid-work"
+    pub name: String,
+    pub mode: ServiceMode,
+    // Timer period, or the daemon's --interval
+    pub interval: Duration,
+    // Global options passed before the mode (repo, profile, ...)
+    pub args: Vec<String>,
+}
+
+impl ServiceSpec {
+    // Full command line, starting with this executable
+    fn command(&self) -> Result<Vec<String>> {
+        let exe = env::current_exe()?;
+        let mut command = vec![exe.display().to_string()];
+        command.extend(self.args.iter().cloned());
+        command.push("--no-color".to_string());
+        match self.mode {
+            ServiceMode::Daemon => {
+                command.push("daemon".to_string());
+                command.push("--interval".to_string());
+                command.push(humantime::format_duration(self.interval).to_string());
+            }
+            ServiceMode::Timer => {
+                command.push("--today".to_string());
+                command.push("--yes".to_string());
+            }
+        }
+        Ok(command)
+    }
+
+    pub fn install(&self) -> Result<Vec<PathBuf>> {
+        if cfg!(target_os = "macos") {
+            self.install_launchd()
+        } else if cfg!(target_os = "linux") {
+            self.install_systemd()
+        } else {
+            Err(unsupported())
+        }
+    }
+
+    fn install_systemd(&self) -> Result<Vec<PathBuf>> {
+        let dir = systemd_dir();
+        fs::create_dir_all(&dir)?;
+
+        let command = self.command()?.iter().map(|arg| systemd_quote(arg)).collect::<Vec<_>>().join(" ");
+        let mut written = Vec::new();
+
+        let service = match self.mode {
+            ServiceMode::Daemon => format!(
+                "[Unit]\nDescription=github-grid daemon ({name})\nAfter=network-online.target\n\n\
+                 [Service]\nExecStart={command}\nRestart=on-failure\nRestartSec=60\n\n\
+                 [Install]\nWantedBy=default.target\n",
+                name = self.name, command = command
+            ),
+            ServiceMode::Timer => format!(
+                "[Unit]\nDescription=github-grid top-up ({name})\nAfter=network-online.target\n\n\
+                 [Service]\nType=oneshot\nExecStart={command}\n",
+                name = self.name, command = command
+            ),
+        };
+        let service_path = dir.join(format!("{}.service", self.name));
+        fs::write(&service_path, service)?;
+        written.push(service_path);
+
+        let enable = match self.mode {
+            ServiceMode::Daemon => format!("{}.service", self.name),
+            ServiceMode::Timer => {
+                let timer = format!(
+                    "[Unit]\nDescription=Run github-grid top-up ({name}) every {interval}\n\n\
+                     [Timer]\nOnBootSec=5min\nOnUnitActiveSec={secs}s\n\n\
+                     [Install]\nWantedBy=timers.target\n",
+                    name = self.name,
+                    interval = humantime::format_duration(self.interval),
+                    secs = self.interval.as_secs().max(60)
+                );
+                let timer_path = dir.join(format!("{}.timer", self.name));
+                fs::write(&timer_path, timer)?;
+                written.push(timer_path);
+                format!("{}.timer", self.name)
+            }
+        };
+
+        run("systemctl", &["--user", "daemon-reload"])?;
+        run("systemctl", &["--user", "enable", "--now", &enable])?;
+        Ok(written)
+    }
+
+    fn install_launchd(&self) -> Result<Vec<PathBuf>> {
+        let dir = launchd_dir();
+        fs::create_dir_all(&dir)?;
+
+        let arguments = self.command()?
+            .iter()
+            .map(|arg| format!("        <string>{}</string>\n", xml_escape(arg)))
+            .collect::<String>();
+        let schedule = match self.mode {
+            ServiceMode::Daemon => "    <key>KeepAlive</key>\n    <true/>\n".to_string(),
+            ServiceMode::Timer => format!(
+                "    <key>StartInterval</key>\n    <integer>{}</integer>\n",
+                self.interval.as_secs().max(60)
+            ),
+        };
+        let log = dir.join(format!("{}.log", launchd_label(&self.name)));
+        let plist = format!(
+            "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n\
+             <!DOCTYPE plist PUBLIC \"-//Apple//DTD PLIST 1.0//EN\" \"http://www.apple.com/DTDs/PropertyList-1.0.dtd\">\n\
+             <plist version=\"1.0\">\n<dict>\n\
+             \x20   <key>Label</key>\n    <string>{label}</string>\n\
+             \x20   <key>ProgramArguments</key>\n    <array>\n{arguments}    </array>\n\
+             {schedule}\
+             \x20   <key>RunAtLoad</key>\n    <true/>\n\
+             \x20   <key>StandardOutPath</key>\n    <string>{log}</string>\n\
+             \x20   <key>StandardErrorPath</key>\n    <string>{log}</string>\n\
+             </dict>\n</plist>\n",
+            label = launchd_label(&self.name),
+            arguments = arguments,
+            schedule = schedule,
+            log = xml_escape(&log.display().to_string()),
+        );
+
+        let path = dir.join(format!("{}.plist", launchd_label(&self.name)));
+        // Reinstalling replaces a loaded agent
+        if path.exists() {
+            let _ = run("launchctl", &["unload", &path.display().to_string()]);
+        }
+        fs::write(&path, plist)?;
+        run("launchctl", &["load", "-w", &path.display().to_string()])?;
+        Ok(vec![path])
+    }
+}
+
+// Stop the service and remove everything install wrote
+pub fn uninstall(name: &str) -> Result<Vec<PathBuf>> {
+    let mut removed = Vec::new();
+
+    if cfg!(target_os = "macos") {
+        let path = launchd_dir().join(format!("{}.plist", launchd_label(name)));
+        if path.exists() {
+            let _ = run("launchctl", &["unload", "-w", &path.display().to_string()]);
+            fs::remove_file(&path)?;
+            removed.push(path);
+        }
+    } else if cfg!(target_os = "linux") {
+        for unit in [format!("{}.timer", name), format!("{}.service", name)] {
+            let path = systemd_dir().join(&unit);
+            if path.exists() {
+                // Fails harmlessly when the unit was never enabled
+                let _ = run("systemctl", &["--user", "disable", "--now", &unit]);
+                fs::remove_file(&path)?;
+                removed.push(path);
+            }
+        }
+        if !removed.is_empty() {
+            run("systemctl", &["--user", "daemon-reload"])?;
+        }
+    } else {
+        return Err(unsupported());
+    }
+
+    Ok(removed)
+}
+
+fn run(program: &str, args: &[&str]) -> Result<()> {
+    status!("   $ {} {}", program, args.join(" "));
+    let output = Command::new(program).args(args).output().map_err(|e| {
+        GitHubGridError::Config(format!("Failed to run {}: {}", program, e))
+    })?;
+
+    if !output.status.success() {
+        return Err(GitHubGridError::Config(format!(
+            "{} {} failed: {}",
+            program,
+            args.join(" "),
+            String::from_utf8_lossy(&output.stderr).trim()
+        )));
+    }
+    Ok(())
+}
+
+fn unsupported() -> GitHubGridError {
+    GitHubGridError::Config("install-service supports Linux (systemd) and macOS (launchd) only".to_string())
+}
+
+fn home_dir() -> PathBuf {
+    PathBuf::from(env::var("HOME").unwrap_or_else(|_| ".".to_string()))
+}
+
+fn systemd_dir() -> PathBuf {
+    env::var("XDG_CONFIG_HOME")
+        .map(PathBuf::from)
+        .unwrap_or_else(|_| home_dir().join(".config"))
+        .join("systemd")
+        .join("user")
+}
+
+fn launchd_dir() -> PathBuf {
+    home_dir().join("Library").join("LaunchAgents")
+}
+
+fn launchd_label(name: &str) -> String {
+    format!("com.{}", name)
+}
+
+// systemd splits ExecStart on whitespace and treats % and \ specially
+fn systemd_quote(arg: &str) -> String {
+    let escaped = arg.replace('\\', "\\\\").replace('"', "\\\"").replace('%', "%%");
+    if escaped.contains(char::is_whitespace) || escaped.is_empty() {
+        format!("\"{}\"", escaped)
+    } else {
+        escaped
+    }
+}
+
+fn xml_escape(text: &str) -> String {
+    text.replace('&', "&amp;").replace('<', "&lt;").replace('>', "&gt;").replace('"', "&quot;")
+}
@@ -0,0 +1,97 @@
+use chrono::{DateTime, FixedOffset, Local, NaiveDate};
+use git2::Repository;
+use serde::{Deserialize, Serialize};
+use std::fs;
+use std::path::PathBuf;
+use crate::error::{GitHubGridError, Result};
+
+const STATE_FILE: &str = "github-grid-state.toml";
+
+// Bumped whenever the state file layout changes incompatibly
+pub const STATE_FORMAT_VERSION: u32 = 1;
+
+// Run state persisted inside the repository's .git directory so it never
+// shows up in the working tree or in generated commits
+#[derive(Debug, Serialize, Deserialize)]
+pub struct RunState {
+    // Files written before versioning are version 1
+    #[serde(default = "current_format_version")]
+    pub format_version: u32,
+    // Local commits exist that could not be pushed (network was down)
+    #[serde(default)]
+    pub pending_push: bool,
+    pub pending_since: Option<DateTime<Local>>,
+    // Where the last run stopped, so a time-boxed run can be resumed
+    pub checkpoint: Option<Checkpoint>,
+    // Seed --today and the daemon plan with when none is configured, drawn
+    // on the first top-up so later ones lay each day out the same way
+    pub top_up_seed: Option<u64>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct Checkpoint {
+    // Newest commit that was created (and pushed, unless a push is pending)
+    pub last_commit: DateTime<FixedOffset>,
+    pub range_end: NaiveDate,
+    pub complete: bool,
+    // Seed of the interrupted plan, so resuming plans the same commits
+    #[serde(default)]
+    pub seed: Option<u64>,
+}
+
+impl Default for RunState {
+    fn default() -> Self {
+        Self {
+            format_version: STATE_FORMAT_VERSION,
+            pending_push: false,
+            pending_since: None,
+            checkpoint: None,
+            top_up_seed: None,
+        }
+    }
+}
+
+fn current_format_version() -> u32 {
+    STATE_FORMAT_VERSION
+}
+
+impl RunState {
+    pub fn load(repo: &Repository) -> Result<Self> {
+        let path = Self::path(repo);
+        if !path.exists() {
+            return Ok(Self::default());
+        }
+
+        let content = fs::read_to_string(&path)?;
+        let state: Self = toml::from_str(&content)?;
+        if state.format_version > STATE_FORMAT_VERSION {
+            return Err(GitHubGridError::Config(format!(
+                "{} uses state format {}, this build only understands up to {}; upgrade github-grid",
+                path.display(), state.format_version, STATE_FORMAT_VERSION
+            )));
+        }
+        Ok(state)
+    }
+
+    pub fn save(&self, repo: &Repository) -> Result<()> {
+        let content = toml::to_string(self)?;
+        fs::write(Self::path(repo), content)?;
+        Ok(())
+    }
+
+    pub fn mark_pending_push(&mut self, now: DateTime<Local>) {
+        if !self.pending_push {
+            self.pending_push = true;
+            self.pending_since = Some(now);
+        }
+    }
+
+    pub fn clear_pending_push(&mut self) {
+        self.pending_push = false;
+        self.pending_since = None;
+    }
+
+    fn path(repo: &Repository) -> PathBuf {
+        repo.path().join(STATE_FILE)
+    }
+}
@@ -0,0 +1,205 @@
+use chrono::{DateTime, FixedOffset, Local, NaiveDate, NaiveDateTime};
+use serde::Deserialize;
+use std::str::FromStr;
+use crate::error::{GitHubGridError, Result};
+
+// A stretch of days spent at another UTC offset, e.g. a trip or a season
+// working from another office. Written as START..END@OFFSET with both dates
+// inclusive: "2025-03-01..2025-03-14@+09:00"
+#[derive(Debug, Clone, PartialEq, Eq, Deserialize)]
+#[serde(try_from = "String")]
+pub struct TimezonePeriod {
+    pub start: NaiveDate,
+    pub end: NaiveDate,
+    pub offset: FixedOffset,
+}
+
+impl TimezonePeriod {
+    pub fn contains(&self, date: NaiveDate) -> bool {
+        self.start <= date && date <= self.end
+    }
+}
+
+impl FromStr for TimezonePeriod {
+    type Err = GitHubGridError;
+
+    fn from_str(spec: &str) -> Result<Self> {
+        let invalid = |reason: &str| {
+            GitHubGridError::Config(format!(
+                "Invalid timezone period '{}': {} (expected START..END@OFFSET, e.g. 2025-03-01..2025-03-14@+09:00)",
+                spec, reason
+            ))
+        };
+
+        let (range, offset) = spec.trim().split_once('@').ok_or_else(|| invalid("missing @OFFSET"))?;
+        let (start, end) = range.split_once("..").ok_or_else(|| invalid("missing START..END"))?;
+        let start = NaiveDate::parse_from_str(start, "%Y-%m-%d").map_err(|_| invalid("bad start date"))?;
+        let end = NaiveDate::parse_from_str(end, "%Y-%m-%d").map_err(|_| invalid("bad end date"))?;
+        if start > end {
+            return Err(invalid("start is after end"));
+        }
+
+        Ok(Self { start, end, offset: parse_offset(offset).ok_or_else(|| invalid("bad offset"))? })
+    }
+}
+
+impl TryFrom<String> for TimezonePeriod {
+    type Error = GitHubGridError;
+
+    fn try_from(spec: String) -> Result<Self> {
+        spec.parse()
+    }
+}
+
+// Offsets commits take on outside the local timezone. Periods must not
+// overlap, so every day has exactly one offset
+#[derive(Debug, Clone, Default)]
+pub struct TimezoneSchedule {
+    periods: Vec<TimezonePeriod>,
+}
+
+impl TimezoneSchedule {
+    pub fn new(mut periods: Vec<TimezonePeriod>) -> Result<Self> {
+        periods.sort_by_key(|period| period.start);
+        for pair in periods.windows(2) {
+            if pair[1].start <= pair[0].end {
+                return Err(GitHubGridError::Config(format!(
+                    "Timezone periods overlap: {}..{} and {}..{}",
+                    pair[0].start, pair[0].end, pair[1].start, pair[1].end
+                )));
+            }
+        }
+        Ok(Self { periods })
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.periods.is_empty()
+    }
+
+    // Offset in effect on `date`, or None for the local timezone
+    pub fn offset_on(&self, date: NaiveDate) -> Option<FixedOffset> {
+        self.periods.iter().find(|period| period.contains(date)).map(|period| period.offset)
+    }
+
+    // Wall-clock time read in the period's offset; fixed offsets have no DST,
+    // so this always resolves
+    pub fn resolve(&self, naive: NaiveDateTime) -> Option<DateTime<FixedOffset>> {
+        let offset = self.offset_on(naive.date())?;
+        naive.and_local_timezone(offset).single()
+    }
+}
+
+// Timezone set on the GitHub account, which decides the day a contribution
+// is counted on regardless of the offset the commit was recorded with.
+// GitHub doesn't expose it through the API, so it is configured: "local"
+// for this machine's timezone (following DST) or a fixed offset
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Deserialize)]
+#[serde(try_from = "String")]
+pub enum GraphTimezone {
+    Local,
+    Fixed(FixedOffset),
+}
+
+impl GraphTimezone {
+    // Day `time` is counted on in the graph
+    pub fn date_of(&self, time: DateTime<FixedOffset>) -> NaiveDate {
+        match self {
+            Self::Local => time.with_timezone(&Local).date_naive(),
+            Self::Fixed(offset) => time.with_timezone(offset).date_naive(),
+        }
+    }
+
+    // Move `time` by whole days so it is counted on `date`. The wall-clock
+    // time it was recorded with stays the same, only the date changes
+    pub fn bucket(&self, time: DateTime<FixedOffset>, date: NaiveDate) -> DateTime<FixedOffset> {
+        time + (date - self.date_of(time))
+    }
+}
+
+impl FromStr for GraphTimezone {
+    type Err = GitHubGridError;
+
+    fn from_str(spec: &str) -> Result<Self> {
+        if spec.trim().eq_ignore_ascii_case("local") {
+            return Ok(Self::Local);
+        }
+        parse_offset(spec).map(Self::Fixed).ok_or_else(|| {
+            GitHubGridError::Config(format!("Invalid GitHub timezone '{}' (expected local or an offset like -05:00)", spec))
+        })
+    }
+}
+
+impl TryFrom<String> for GraphTimezone {
+    type Error = GitHubGridError;
+
+    fn try_from(spec: String) -> Result<Self> {
+        spec.parse()
+    }
+}
+
+// UTC offset commits are recorded with outside timezone periods: "local" for
+// this machine's timezone (following DST, and the TZ environment variable, so
+// TZ=Europe/Berlin gives Berlin's historical offset on each date) or a fixed
+// offset such as "+00:00", for identical timestamps from any machine
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Deserialize)]
+#[serde(try_from = "String")]
+pub enum CommitOffset {
+    Local,
+    Fixed(FixedOffset),
+}
+
+impl CommitOffset {
+    // Wall-clock time read in the fixed offset, or None for the local timezone
+    pub fn resolve(&self, naive: NaiveDateTime) -> Option<DateTime<FixedOffset>> {
+        match self {
+            Self::Local => None,
+            Self::Fixed(offset) => naive.and_local_timezone(*offset).single(),
+        }
+    }
+}
+
+impl FromStr for CommitOffset {
+    type Err = GitHubGridError;
+
+    fn from_str(spec: &str) -> Result<Self> {
+        if spec.trim().eq_ignore_ascii_case("local") {
+            return Ok(Self::Local);
+        }
+        parse_offset(spec).map(Self::Fixed).ok_or_else(|| {
+            GitHubGridError::Config(format!("Invalid commit offset '{}' (expected local or an offset like +00:00)", spec))
+        })
+    }
+}
+
+impl TryFrom<String> for CommitOffset {
+    type Error = GitHubGridError;
+
+    fn try_from(spec: String) -> Result<Self> {
+        spec.parse()
+    }
+}
+
+// "+09:00", "-0330", "+5" or "Z"
+fn parse_offset(offset: &str) -> Option<FixedOffset> {
+    let offset = offset.trim();
+    if offset.eq_ignore_ascii_case("z") || offset.eq_ignore_ascii_case("utc") {
+        return FixedOffset::east_opt(0);
+    }
+
+    let (sign, digits) = match offset.split_at_checked(1)? {
+        ("+", rest) => (1, rest),
+        ("-", rest) => (-1, rest),
+        _ => return None,
+    };
+    let (hours, minutes) = match digits.split_once(':') {
+        Some((hours, minutes)) => (hours, minutes),
+        None if digits.len() == 4 => digits.split_at(2),
+        None => (digits, "0"),
+    };
+    let hours: i32 = hours.parse().ok()?;
+    let minutes: i32 = minutes.parse().ok()?;
+    if hours > 14 || minutes > 59 {
+        return None;
+    }
+    FixedOffset::east_opt(sign * (hours * 3600 + minutes * 60))
+}
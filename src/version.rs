@@ -0,0 +1,14 @@
+// Build metadata embedded by build.rs
+pub const VERSION: &str = env!("CARGO_PKG_VERSION");
+pub const COMMIT: &str = env!("GITHUB_GRID_COMMIT");
+pub const BUILD_DATE: &str = env!("GITHUB_GRID_BUILD_DATE");
+
+// "0.1.0 (commit 1a2b3c4d5e6f, built 2025-01-31)"
+pub const LONG_VERSION: &str = concat!(
+    env!("CARGO_PKG_VERSION"),
+    " (commit ",
+    env!("GITHUB_GRID_COMMIT"),
+    ", built ",
+    env!("GITHUB_GRID_BUILD_DATE"),
+    ")"
+);
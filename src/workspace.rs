@@ -0,0 +1,190 @@
+use chrono::{Datelike, NaiveDate};
+use rand::{Rng, SeedableRng};
+use rand_chacha::ChaCha8Rng;
+use serde::Deserialize;
+use std::path::PathBuf;
+use std::str::FromStr;
+use crate::error::{GitHubGridError, Result};
+use crate::plan::Plan;
+
+// Keeps the split's draws apart from the plan's own
+const SPLIT_SALT: u64 = 0x5752_4b53_5041_4345;
+const LIFECYCLE_SALT: u64 = 0x4c49_4645_4359_434c;
+
+// Project lifecycles: focus periods are laid out from a fixed day, so the
+// same seed gives the same periods whatever range is planned
+const LIFECYCLE_EPOCH: NaiveDate = NaiveDate::from_ymd_opt(2000, 1, 3).unwrap();
+// A focus lasts a few weeks
+const FOCUS_DAYS: std::ops::RangeInclusive<i64> = 14..=42;
+// Days a new focus takes to reach full activity; the next one starts this
+// long before the current one ends, so they hand over
+const RAMP_DAYS: i64 = 5;
+// After its focus, a repository's activity halves every this many days
+const TAPER_HALF_LIFE: f64 = 7.0;
+// Activity of a repository out of focus, relative to full focus
+const BACKGROUND: f64 = 0.1;
+
+// One repository of a workspace: commits are shared out by `share`, so a
+// repository with share 3 gets about three times as many as one with 1
+#[derive(Debug, Clone, PartialEq, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct WorkspaceRepo {
+    pub repo: PathBuf,
+    #[serde(default = "default_share")]
+    pub share: u32,
+}
+
+fn default_share() -> u32 {
+    1
+}
+
+// PATH or PATH=SHARE, as in GITHUB_GRID_WORKSPACE
+impl FromStr for WorkspaceRepo {
+    type Err = GitHubGridError;
+
+    fn from_str(s: &str) -> Result<Self> {
+        let (repo, share) = match s.rsplit_once('=') {
+            Some((repo, share)) => {
+                let share = share.trim().parse().map_err(|_| {
+                    GitHubGridError::Config(format!("Invalid workspace share in '{}': expected a whole number", s))
+                })?;
+                (repo, share)
+            }
+            None => (s, default_share()),
+        };
+        Ok(Self { repo: PathBuf::from(repo.trim()), share })
+    }
+}
+
+// Problems with a workspace a run would reject
+pub fn problems(repos: &[WorkspaceRepo]) -> Vec<String> {
+    let mut problems = Vec::new();
+    if repos.is_empty() {
+        problems.push("workspace lists no repositories".to_string());
+    } else if repos.iter().all(|repo| repo.share == 0) {
+        problems.push("workspace shares are all 0: at least one repository needs a share above 0".to_string());
+    }
+    for (index, repo) in repos.iter().enumerate() {
+        if repos[..index].iter().any(|other| other.repo == repo.repo) {
+            problems.push(format!("workspace lists {} twice", repo.repo.display()));
+        }
+    }
+    problems
+}
+
+// A stretch of weeks one repository gets most of the work
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct Focus {
+    pub start: NaiveDate,
+    // Last day at full activity; it tapers off after
+    pub end: NaiveDate,
+    // Index into the workspace
+    pub repo: usize,
+}
+
+// Focus periods from the epoch up to `until`: each picks a repository by
+// share, never the one before it when there is a choice
+fn focus_periods(seed: u64, repos: &[WorkspaceRepo], until: NaiveDate) -> Vec<Focus> {
+    let mut rng = ChaCha8Rng::seed_from_u64(seed ^ LIFECYCLE_SALT);
+    let mut periods: Vec<Focus> = Vec::new();
+    let mut start = LIFECYCLE_EPOCH.min(until);
+    while start <= until {
+        let previous = periods.last().map(|focus| focus.repo);
+        let candidates: Vec<usize> = (0..repos.len())
+            .filter(|&index| repos[index].share > 0 && Some(index) != previous)
+            .collect();
+        let repo = match candidates.is_empty() {
+            true => previous.unwrap_or(0),
+            false => {
+                let total: u32 = candidates.iter().map(|&index| repos[index].share).sum();
+                let mut draw = rng.random_range(0..total);
+                *candidates.iter()
+                    .find(|&&index| {
+                        if draw < repos[index].share {
+                            return true;
+                        }
+                        draw -= repos[index].share;
+                        false
+                    })
+                    .unwrap_or(&candidates[0])
+            }
+        };
+        let days = rng.random_range(FOCUS_DAYS);
+        let end = start + chrono::Duration::days(days - 1);
+        periods.push(Focus { start, end, repo });
+        start = end + chrono::Duration::days(1 - RAMP_DAYS);
+    }
+    periods
+}
+
+// Relative activity of each repository on a day: ramping up into a focus,
+// full during it, tapering off after, and a little in the background
+fn activity(periods: &[Focus], repos: usize, day: NaiveDate) -> Vec<f64> {
+    let mut activity = vec![BACKGROUND; repos];
+    for focus in periods.iter().filter(|focus| focus.start <= day) {
+        let since_start = (day - focus.start).num_days();
+        let level = if since_start < RAMP_DAYS {
+            (since_start + 1) as f64 / RAMP_DAYS as f64
+        } else if day <= focus.end {
+            1.0
+        } else {
+            0.5f64.powf((day - focus.end).num_days() as f64 / TAPER_HALF_LIFE)
+        };
+        activity[focus.repo] += level;
+    }
+    activity
+}
+
+// The focus periods overlapping a plan, for showing how it was split
+pub fn lifecycles(plan: &Plan, repos: &[WorkspaceRepo]) -> Vec<Focus> {
+    focus_periods(plan.seed, repos, plan.end)
+        .into_iter()
+        .filter(|focus| focus.end >= plan.start)
+        .collect()
+}
+
+// Split a plan over the workspace's repositories, one plan each in the same
+// order. Every commit goes to one repository, drawn by share, or with
+// `lifecycles` by share times how active the repository is that day, so
+// each gets bursts of focus that taper off as another one ramps up. The
+// draws for a day only depend on the seed and the day, so planning the same
+// range again splits it the same way and each repository is only topped up
+pub fn split(plan: &Plan, repos: &[WorkspaceRepo], lifecycles: bool) -> Result<Vec<Plan>> {
+    if let Some(problem) = problems(repos).into_iter().next() {
+        return Err(GitHubGridError::Config(problem));
+    }
+    let periods = match lifecycles {
+        true => focus_periods(plan.seed, repos, plan.end),
+        false => Vec::new(),
+    };
+
+    let mut plans: Vec<Plan> = repos.iter()
+        .map(|_| Plan { start: plan.start, end: plan.end, seed: plan.seed, commits: Vec::new() })
+        .collect();
+    let mut day: Option<(NaiveDate, ChaCha8Rng, Vec<f64>)> = None;
+    for commit in &plan.commits {
+        let date = commit.date.date_naive();
+        if day.as_ref().is_none_or(|(current, _, _)| *current != date) {
+            let seed = plan.seed ^ SPLIT_SALT ^ date.num_days_from_ce() as u64;
+            let activity = match lifecycles {
+                true => activity(&periods, repos.len(), date),
+                false => vec![1.0; repos.len()],
+            };
+            let weights = repos.iter().zip(activity).map(|(repo, activity)| repo.share as f64 * activity).collect();
+            day = Some((date, ChaCha8Rng::seed_from_u64(seed), weights));
+        }
+        let (_, rng, weights) = day.as_mut().unwrap();
+        let mut draw = rng.random_range(0.0..weights.iter().sum::<f64>());
+        let index = weights.iter()
+            .position(|&weight| {
+                if draw < weight {
+                    return true;
+                }
+                draw -= weight;
+                false
+            })
+            .unwrap_or_else(|| weights.iter().rposition(|&weight| weight > 0.0).unwrap_or(0));
+        plans[index].commits.push(commit.clone());
+    }
+    Ok(plans)
+}